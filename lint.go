@@ -0,0 +1,241 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"emperror.dev/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/webhook"
+)
+
+const (
+	lintOutputJSON  = "json"
+	lintOutputSARIF = "sarif"
+
+	lintLevelError   = "error"
+	lintLevelWarning = "warning"
+	lintLevelNone    = "none"
+)
+
+// lintRules describes each kind of finding and its SARIF level.
+var lintRules = []struct {
+	id          string
+	level       string
+	description string
+}{
+	{webhook.LintUnknown, lintLevelError, "Unknown webhook annotation, probably a typo"},
+	{webhook.LintMalformed, lintLevelError, "Webhook annotation with a malformed value"},
+	{webhook.LintDeprecated, lintLevelWarning, "Deprecated webhook annotation"},
+	{webhook.LintIneffective, lintLevelWarning, "Webhook annotation without effect on the object"},
+}
+
+// lintObject identifies the object of a finding.
+type lintObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// lintResult is a finding of the lint report, located in the manifests.
+type lintResult struct {
+	File   string     `json:"file"`
+	Line   int        `json:"line"`
+	Level  string     `json:"level"`
+	Object lintObject `json:"object"`
+	webhook.AnnotationFinding
+}
+
+// runLint implements the lint subcommand, which reports the problems with the
+// webhook annotations of the objects of multi-document YAML manifests.
+func runLint(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var files []string
+
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: vault-secrets-webhook lint [flags]")
+		fmt.Fprintln(stderr, "")
+		fmt.Fprintln(stderr, "Reports unknown, deprecated, malformed and ineffective webhook annotations of Kubernetes manifests.")
+		fmt.Fprintln(stderr, "")
+		flags.PrintDefaults()
+	}
+	flags.Func("f", "manifest file to lint, - for the standard input (can be repeated, defaults to the standard input)", func(file string) error {
+		files = append(files, file)
+
+		return nil
+	})
+	output := flags.String("o", lintOutputJSON, "output format: "+lintOutputJSON+" or "+lintOutputSARIF)
+	failOn := flags.String("fail-on", lintLevelError, "exit with an error if there are findings of this level or above: "+lintLevelError+", "+lintLevelWarning+" or "+lintLevelNone)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		return errors.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	if *output != lintOutputJSON && *output != lintOutputSARIF {
+		return errors.Errorf("unknown output format %q", *output)
+	}
+
+	if *failOn != lintLevelError && *failOn != lintLevelWarning && *failOn != lintLevelNone {
+		return errors.Errorf("unknown level %q", *failOn)
+	}
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	results := []lintResult{}
+	for _, file := range files {
+		manifests, err := readManifests(file, stdin)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read manifests from %s", file)
+		}
+
+		for _, m := range manifests {
+			metaObject := m.object.(metav1.Object)
+			gvk := m.object.GetObjectKind().GroupVersionKind()
+
+			for _, finding := range webhook.LintAnnotations(metaObject) {
+				results = append(results, lintResult{
+					File:  m.file,
+					Line:  m.line + annotationLine(m.document, finding.Annotation),
+					Level: lintLevel(finding.Kind),
+					Object: lintObject{
+						APIVersion: gvk.GroupVersion().String(),
+						Kind:       gvk.Kind,
+						Namespace:  metaObject.GetNamespace(),
+						Name:       metaObject.GetName(),
+					},
+					AnnotationFinding: finding,
+				})
+			}
+		}
+	}
+
+	var err error
+	if *output == lintOutputSARIF {
+		err = writeSARIF(stdout, results)
+	} else {
+		err = writeJSON(stdout, map[string]any{"findings": results})
+	}
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if *failOn == lintLevelWarning || (*failOn == lintLevelError && result.Level == lintLevelError) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("found %d annotation problems", failed)
+	}
+
+	return nil
+}
+
+func lintLevel(kind string) string {
+	for _, rule := range lintRules {
+		if rule.id == kind {
+			return rule.level
+		}
+	}
+
+	return lintLevelWarning
+}
+
+// annotationLine returns the offset of the first line of a document with the given
+// annotation as key, or 0 if it is not found. Annotations prefixed by another one,
+// like vault-role and vault-rol, are told apart by matching whole keys.
+func annotationLine(document []byte, annotation string) int {
+	for i, line := range bytes.Split(document, []byte("\n")) {
+		key, _, found := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+		if found && string(bytes.Trim(key, `"'`)) == annotation {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// writeSARIF writes the findings as a SARIF 2.1.0 log, which code scanning tools can gate pull requests on.
+func writeSARIF(w io.Writer, results []lintResult) error {
+	rules := make([]map[string]any, 0, len(lintRules))
+	for _, rule := range lintRules {
+		rules = append(rules, map[string]any{
+			"id":                   rule.id,
+			"shortDescription":     map[string]any{"text": rule.description},
+			"defaultConfiguration": map[string]any{"level": rule.level},
+		})
+	}
+
+	sarifResults := make([]map[string]any, 0, len(results))
+	for _, result := range results {
+		location := map[string]any{
+			"logicalLocations": []map[string]any{{
+				"kind":               "object",
+				"fullyQualifiedName": fmt.Sprintf("%s/%s/%s/%s", result.Object.APIVersion, result.Object.Kind, result.Object.Namespace, result.Object.Name),
+			}},
+		}
+		if result.File != "-" {
+			location["physicalLocation"] = map[string]any{
+				"artifactLocation": map[string]any{"uri": result.File},
+				"region":           map[string]any{"startLine": result.Line},
+			}
+		}
+
+		sarifResults = append(sarifResults, map[string]any{
+			"ruleId":    result.Kind,
+			"level":     result.Level,
+			"message":   map[string]any{"text": fmt.Sprintf("%s %s: %s", result.Object.Kind, result.Object.Name, result.Message)},
+			"locations": []map[string]any{location},
+		})
+	}
+
+	return writeJSON(w, map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "vault-secrets-webhook",
+					"informationUri": "https://bank-vaults.dev/docs/mutating-webhook/annotations/",
+					"rules":          rules,
+				},
+			},
+			"results": sarifResults,
+		}},
+	})
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
@@ -0,0 +1,202 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/webhook"
+)
+
+const lintManifests = `apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+  namespace: payments
+  annotations:
+    vault.security.banzaicloud.io/vault-role: app
+    vault.security.banzaicloud.io/vault-rol: typo
+spec:
+  containers:
+    - name: app
+      image: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  annotations:
+    vault.security.banzaicloud.io/vault-agent-cpu: 250m
+`
+
+func TestRunLint(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantFindings int
+		wantErr      string
+	}{
+		{
+			name:         "fails on errors by default",
+			stdin:        lintManifests,
+			wantFindings: 3,
+			wantErr:      "found 1 annotation problems",
+		},
+		{
+			name:         "fails on warnings",
+			args:         []string{"-fail-on", "warning"},
+			stdin:        lintManifests,
+			wantFindings: 3,
+			wantErr:      "found 3 annotation problems",
+		},
+		{
+			name:         "never fails",
+			args:         []string{"-fail-on", "none"},
+			stdin:        lintManifests,
+			wantFindings: 3,
+		},
+		{
+			name:  "passes manifests without findings",
+			stdin: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: plain\n",
+		},
+		{
+			name:    "rejects an unknown level",
+			args:    []string{"-fail-on", "info"},
+			wantErr: `unknown level "info"`,
+		},
+		{
+			name:    "rejects an unknown output format",
+			args:    []string{"-o", "yaml"},
+			wantErr: `unknown output format "yaml"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := runLint(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if stdout.Len() == 0 {
+				assert.Zero(t, tt.wantFindings)
+
+				return
+			}
+
+			var report struct {
+				Findings []lintResult `json:"findings"`
+			}
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+			assert.Len(t, report.Findings, tt.wantFindings)
+		})
+	}
+}
+
+func TestRunLintJSON(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "manifests.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(lintManifests), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, runLint([]string{"-f", file, "-fail-on", "none"}, nil, &stdout, &stderr))
+
+	var report struct {
+		Findings []lintResult `json:"findings"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Len(t, report.Findings, 3)
+
+	// Findings are located on the line of their annotation
+	unknown := report.Findings[0]
+	assert.Equal(t, file, unknown.File)
+	assert.Equal(t, 8, unknown.Line)
+	assert.Equal(t, lintLevelError, unknown.Level)
+	assert.Equal(t, webhook.LintUnknown, unknown.Kind)
+	assert.Equal(t, "vault.security.banzaicloud.io/vault-rol", unknown.Annotation)
+	assert.Equal(t, lintObject{APIVersion: "v1", Kind: "Pod", Namespace: "payments", Name: "app"}, unknown.Object)
+
+	deprecated := report.Findings[1]
+	assert.Equal(t, 19, deprecated.Line)
+	assert.Equal(t, "ConfigMap", deprecated.Object.Kind)
+}
+
+func TestRunLintSARIF(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "manifests.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(lintManifests), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	err := runLint([]string{"-f", file, "-o", "sarif"}, nil, &stdout, &stderr)
+	require.ErrorContains(t, err, "found 1 annotation problems")
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &log))
+
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	assert.Len(t, log.Runs[0].Tool.Driver.Rules, len(lintRules))
+	require.Len(t, log.Runs[0].Results, 3)
+
+	unknown := log.Runs[0].Results[0]
+	assert.Equal(t, webhook.LintUnknown, unknown.RuleID)
+	assert.Equal(t, lintLevelError, unknown.Level)
+	require.Len(t, unknown.Locations, 1)
+	assert.Equal(t, file, unknown.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, 8, unknown.Locations[0].PhysicalLocation.Region.StartLine)
+}
+
+func TestAnnotationLine(t *testing.T) {
+	document := []byte("kind: Pod\nmetadata:\n  annotations:\n    vault.security.banzaicloud.io/vault-role: app\n    \"vault.security.banzaicloud.io/vault-rol\": typo\n")
+
+	assert.Equal(t, 3, annotationLine(document, "vault.security.banzaicloud.io/vault-role"))
+	assert.Equal(t, 4, annotationLine(document, "vault.security.banzaicloud.io/vault-rol"))
+	assert.Equal(t, 0, annotationLine(document, "vault.security.banzaicloud.io/vault-path"))
+}
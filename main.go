@@ -17,7 +17,10 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -33,6 +36,7 @@ import (
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	kubernetesConfig "sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -53,6 +57,15 @@ func newK8SClient() (kubernetes.Interface, error) {
 	return kubernetes.NewForConfig(kubeConfig)
 }
 
+func newDynamicClient() (dynamic.Interface, error) {
+	kubeConfig, err := kubernetesConfig.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamic.NewForConfig(kubeConfig)
+}
+
 func healthzHandler(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -84,6 +97,27 @@ func newHTTPServer(tlsCertFile string, tlsPrivateKeyFile string, listenAddress s
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		var command func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error
+		switch os.Args[1] {
+		case "render":
+			command = runRender
+		case "lint":
+			command = runLint
+		}
+
+		if command != nil {
+			if err := command(os.Args[2:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+				if !errors.Is(err, flag.ErrHelp) {
+					fmt.Fprintln(os.Stderr, "error: "+err.Error())
+					os.Exit(1)
+				}
+			}
+
+			return
+		}
+	}
+
 	var logger *slog.Logger
 	{
 		var level slog.Level
@@ -146,9 +180,70 @@ func main() {
 		os.Exit(1)
 	}
 
+	if viper.GetBool("enable_injection_policies") || viper.GetBool("enable_access_policies") {
+		dynamicClient, err := newDynamicClient()
+		if err != nil {
+			logger.Error(fmt.Errorf("error creating dynamic k8s client: %w", err).Error())
+			os.Exit(1)
+		}
+
+		if viper.GetBool("enable_injection_policies") {
+			if err := mutatingWebhook.WatchInjectionPolicies(context.Background(), dynamicClient); err != nil {
+				logger.Error(fmt.Errorf("error watching vault injection policies: %w", err).Error())
+				os.Exit(1)
+			}
+		}
+
+		if viper.GetBool("enable_access_policies") {
+			if err := mutatingWebhook.WatchAccessPolicies(context.Background(), dynamicClient); err != nil {
+				logger.Error(fmt.Errorf("error watching vault access policies: %w", err).Error())
+				os.Exit(1)
+			}
+		}
+	}
+
+	if viper.GetBool("enable_namespace_defaults") {
+		if err := mutatingWebhook.WatchNamespaceDefaults(context.Background()); err != nil {
+			logger.Error(fmt.Errorf("error watching namespaces: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	if viper.GetBool("enable_namespace_failure_policies") {
+		if err := mutatingWebhook.WatchNamespaceFailurePolicies(context.Background()); err != nil {
+			logger.Error(fmt.Errorf("error watching namespaces: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	if viper.GetBool("enable_events") {
+		mutatingWebhook.RecordEvents(context.Background())
+	}
+
+	if viper.GetBool("enable_secret_rollouts") {
+		if err := mutatingWebhook.StartSecretRollouts(context.Background()); err != nil {
+			logger.Error(fmt.Errorf("error watching workloads: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	if viper.GetBool("enable_secret_resync") {
+		if err := mutatingWebhook.StartSecretResync(context.Background()); err != nil {
+			logger.Error(fmt.Errorf("error watching resynced secrets: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
+	if viper.GetBool("enable_dynamic_secrets") {
+		if err := mutatingWebhook.StartLeaseRenewal(context.Background()); err != nil {
+			logger.Error(fmt.Errorf("error watching Vault leases: %w", err).Error())
+			os.Exit(1)
+		}
+	}
+
 	whLogger := webhook.NewWhLogger(logger)
 
-	mutator := webhook.ErrorLoggerMutator(mutatingWebhook.VaultSecretsMutator, whLogger)
+	mutator := webhook.ErrorLoggerMutator(mutatingWebhook.EventRecorderMutator(mutatingWebhook.VaultSecretsMutator), whLogger)
 
 	promRegistry := prometheus.NewRegistry()
 	webhook.RegisterMetrics(promRegistry)
@@ -163,12 +258,15 @@ func main() {
 	secretHandler := handlerFor(mutating.WebhookConfig{ID: "vault-secrets-secret", Obj: &corev1.Secret{}, Logger: whLogger, Mutator: mutator}, metricsRecorder)
 	configMapHandler := handlerFor(mutating.WebhookConfig{ID: "vault-secrets-configmap", Obj: &corev1.ConfigMap{}, Logger: whLogger, Mutator: mutator}, metricsRecorder)
 	objectHandler := handlerFor(mutating.WebhookConfig{ID: "vault-secrets-object", Obj: &unstructured.Unstructured{}, Logger: whLogger, Mutator: mutator}, metricsRecorder)
+	// Workloads of different kinds are decoded to their typed objects
+	workloadHandler := handlerFor(mutating.WebhookConfig{ID: "vault-secrets-workload", Logger: whLogger, Mutator: mutator}, metricsRecorder)
 
 	mux := http.NewServeMux()
 	mux.Handle("/pods", podHandler)
 	mux.Handle("/secrets", secretHandler)
 	mux.Handle("/configmaps", configMapHandler)
 	mux.Handle("/objects", objectHandler)
+	mux.Handle("/workloads", workloadHandler)
 	mux.Handle("/healthz", http.HandlerFunc(healthzHandler))
 
 	telemetryAddress := viper.GetString("telemetry_listen_address")
@@ -19,16 +19,54 @@ import (
 )
 
 const (
+	// AnnotationPrefix is the common prefix of the webhook annotations
+	AnnotationPrefix = "vault.security.banzaicloud.io/"
+
 	// Webhook annotations
 	// ref: https://bank-vaults.dev/docs/mutating-webhook/annotations/
-	PSPAllowPrivilegeEscalationAnnotation = "vault.security.banzaicloud.io/psp-allow-privilege-escalation"
-	RunAsNonRootAnnotation                = "vault.security.banzaicloud.io/run-as-non-root"
-	RunAsUserAnnotation                   = "vault.security.banzaicloud.io/run-as-user"
-	RunAsGroupAnnotation                  = "vault.security.banzaicloud.io/run-as-group"
-	ReadOnlyRootFsAnnotation              = "vault.security.banzaicloud.io/readonly-root-fs"
-	RegistrySkipVerifyAnnotation          = "vault.security.banzaicloud.io/registry-skip-verify"
-	MutateAnnotation                      = "vault.security.banzaicloud.io/mutate"
-	MutateProbesAnnotation                = "vault.security.banzaicloud.io/mutate-probes"
+	PSPAllowPrivilegeEscalationAnnotation   = "vault.security.banzaicloud.io/psp-allow-privilege-escalation"
+	RunAsNonRootAnnotation                  = "vault.security.banzaicloud.io/run-as-non-root"
+	RunAsUserAnnotation                     = "vault.security.banzaicloud.io/run-as-user"
+	RunAsGroupAnnotation                    = "vault.security.banzaicloud.io/run-as-group"
+	ReadOnlyRootFsAnnotation                = "vault.security.banzaicloud.io/readonly-root-fs"
+	RegistrySkipVerifyAnnotation            = "vault.security.banzaicloud.io/registry-skip-verify"
+	MutateAnnotation                        = "vault.security.banzaicloud.io/mutate"
+	MutateProbesAnnotation                  = "vault.security.banzaicloud.io/mutate-probes"
+	NativeSidecarsAnnotation                = "vault.security.banzaicloud.io/native-sidecars"
+	NativeSidecarsStartupProbeAnnotation    = "vault.security.banzaicloud.io/native-sidecars-startup-probe"
+	MutatedAnnotation                       = "vault.security.banzaicloud.io/mutated"
+	MutationErrorAnnotation                 = "vault.security.banzaicloud.io/mutation-error"
+	MutationFailurePolicyAnnotation         = "vault.security.banzaicloud.io/mutation-failure-policy"
+	VaultSecretPathsAnnotation              = "vault.security.banzaicloud.io/vault-secret-paths"
+	VaultSecretVersionsAnnotation           = "vault.security.banzaicloud.io/vault-secret-versions"
+	VaultSecretsRotatedAtAnnotation         = "vault.security.banzaicloud.io/vault-secrets-rotated-at"
+	VaultSecretsHashAnnotation              = "vault.security.banzaicloud.io/vault-secrets-hash"
+	VaultSecretTemplatesAnnotation          = "vault.security.banzaicloud.io/vault-secret-templates"
+	VaultSecretTemplatesSignatureAnnotation = "vault.security.banzaicloud.io/vault-secret-templates-signature"
+	VaultLeaseIDsAnnotation                 = "vault.security.banzaicloud.io/vault-lease-ids"
+	VaultLeaseIDsSignatureAnnotation        = "vault.security.banzaicloud.io/vault-lease-ids-signature"
+	VaultLeaseTokenAccessorAnnotation       = "vault.security.banzaicloud.io/vault-lease-token-accessor"
+	VaultSupersededLeaseTokensAnnotation    = "vault.security.banzaicloud.io/vault-superseded-lease-tokens"
+	VaultLeaseTTLAnnotation                 = "vault.security.banzaicloud.io/vault-lease-ttl"
+	VaultLeaseRenewedAtAnnotation           = "vault.security.banzaicloud.io/vault-lease-renewed-at"
+
+	// Secret source annotations
+	VaultSecretSourceAnnotation           = "vault.security.banzaicloud.io/vault-secret-source"
+	VaultSecretSourceTTLAnnotation        = "vault.security.banzaicloud.io/vault-secret-source-ttl"
+	VaultSecretSourceCommonNameAnnotation = "vault.security.banzaicloud.io/vault-secret-source-common-name"
+
+	// SecretResyncLabel marks the Secrets and ConfigMaps resynced with Vault
+	SecretResyncLabel = "vault.security.banzaicloud.io/resync"
+
+	// VaultLeasesLabel marks the Secrets holding Vault leases renewed by the webhook
+	VaultLeasesLabel = "vault.security.banzaicloud.io/vault-leases"
+
+	// ContainerEntrypointAnnotationPrefix prefixes the name of the container whose
+	// entrypoint and cmd an annotation supplies as JSON
+	ContainerEntrypointAnnotationPrefix = "vault.security.banzaicloud.io/container-entrypoint."
+
+	// VaultLeasesFinalizer keeps Secrets holding Vault leases until the leases are revoked
+	VaultLeasesFinalizer = "vault.security.banzaicloud.io/revoke-leases"
 
 	// Vault-env/Secret-init annotations
 	// NOTE: Change these once vault-env has been replaced with secret-init
@@ -51,6 +89,7 @@ const (
 	VaultPathAnnotation                     = "vault.security.banzaicloud.io/vault-path"
 	VaultSkipVerifyAnnotation               = "vault.security.banzaicloud.io/vault-skip-verify"
 	VaultTLSSecretAnnotation                = "vault.security.banzaicloud.io/vault-tls-secret"
+	VaultTLSClientCertAnnotation            = "vault.security.banzaicloud.io/vault-tls-client-cert"
 	VaultIgnoreMissingSecretsAnnotation     = "vault.security.banzaicloud.io/vault-ignore-missing-secrets"
 	VaultClientTimeoutAnnotation            = "vault.security.banzaicloud.io/vault-client-timeout"
 	TransitKeyIDAnnotation                  = "vault.security.banzaicloud.io/transit-key-id"
@@ -62,6 +101,10 @@ const (
 	VaultNamespaceAnnotation                = "vault.security.banzaicloud.io/vault-namespace"
 	ServiceAccountTokenVolumeNameAnnotation = "vault.security.banzaicloud.io/service-account-token-volume-name"
 	LogLevelAnnotation                      = "vault.security.banzaicloud.io/log-level"
+	VaultAppRoleSecretAnnotation            = "vault.security.banzaicloud.io/vault-approle-secret"
+	VaultAppRolePathAnnotation              = "vault.security.banzaicloud.io/vault-approle-path"
+	VaultCertAuthSecretAnnotation           = "vault.security.banzaicloud.io/vault-cert-auth-secret"
+	VaultCertAuthPathAnnotation             = "vault.security.banzaicloud.io/vault-cert-auth-path"
 	// NOTE: Change these once vault-env has been replaced with secret-init
 	VaultEnvPassthroughAnnotation = "vault.security.banzaicloud.io/vault-env-passthrough"
 	// VaultPasstroughAnnotation = "vault.security.banzaicloud.io/vault-passthrough"
@@ -96,6 +139,96 @@ const (
 	VaultConsuleTemplateInjectInInitcontainersAnnotation = "vault.security.banzaicloud.io/vault-ct-inject-in-initcontainers"
 )
 
+// Annotations lists every annotation understood by the webhook.
+var Annotations = []string{
+	PSPAllowPrivilegeEscalationAnnotation,
+	RunAsNonRootAnnotation,
+	RunAsUserAnnotation,
+	RunAsGroupAnnotation,
+	ReadOnlyRootFsAnnotation,
+	RegistrySkipVerifyAnnotation,
+	MutateAnnotation,
+	MutateProbesAnnotation,
+	NativeSidecarsAnnotation,
+	NativeSidecarsStartupProbeAnnotation,
+	MutatedAnnotation,
+	MutationErrorAnnotation,
+	MutationFailurePolicyAnnotation,
+	VaultSecretPathsAnnotation,
+	VaultSecretVersionsAnnotation,
+	VaultSecretsRotatedAtAnnotation,
+	VaultSecretsHashAnnotation,
+	VaultSecretTemplatesAnnotation,
+	VaultSecretTemplatesSignatureAnnotation,
+	VaultLeaseIDsAnnotation,
+	VaultLeaseIDsSignatureAnnotation,
+	VaultLeaseTokenAccessorAnnotation,
+	VaultSupersededLeaseTokensAnnotation,
+	VaultLeaseTTLAnnotation,
+	VaultLeaseRenewedAtAnnotation,
+	VaultSecretSourceAnnotation,
+	VaultSecretSourceTTLAnnotation,
+	VaultSecretSourceCommonNameAnnotation,
+	VaultEnvDaemonAnnotation,
+	VaultEnvDelayAnnotation,
+	EnableJSONLogAnnotation,
+	VaultEnvImageAnnotation,
+	VaultEnvImagePullPolicyAnnotation,
+	VaultAddrAnnotation,
+	VaultImageAnnotation,
+	VaultImagePullPolicyAnnotation,
+	VaultRoleAnnotation,
+	VaultPathAnnotation,
+	VaultSkipVerifyAnnotation,
+	VaultTLSSecretAnnotation,
+	VaultTLSClientCertAnnotation,
+	VaultIgnoreMissingSecretsAnnotation,
+	VaultClientTimeoutAnnotation,
+	TransitKeyIDAnnotation,
+	TransitPathAnnotation,
+	VaultAuthMethodAnnotation,
+	TransitBatchSizeAnnotation,
+	TokenAuthMountAnnotation,
+	VaultServiceaccountAnnotation,
+	VaultNamespaceAnnotation,
+	ServiceAccountTokenVolumeNameAnnotation,
+	LogLevelAnnotation,
+	VaultAppRoleSecretAnnotation,
+	VaultAppRolePathAnnotation,
+	VaultCertAuthSecretAnnotation,
+	VaultCertAuthPathAnnotation,
+	VaultEnvPassthroughAnnotation,
+	VaultEnvFromPathAnnotation,
+	VaultAgentAnnotation,
+	VaultAgentConfigmapAnnotation,
+	VaultAgentOnceAnnotation,
+	VaultAgentShareProcessNamespaceAnnotation,
+	VaultAgentCPUAnnotation,
+	VaultAgentCPULimitAnnotation,
+	VaultAgentCPURequestAnnotation,
+	VaultAgentMemoryAnnotation,
+	VaultAgentMemoryLimitAnnotation,
+	VaultAgentMemoryRequestAnnotation,
+	VaultConfigfilePathAnnotation,
+	VaultAgentEnvVariablesAnnotation,
+	VaultConsulTemplateConfigmapAnnotation,
+	VaultConsulTemplateImageAnnotation,
+	VaultConsulTemplateOnceAnnotation,
+	VaultConsulTemplatePullPolicyAnnotation,
+	VaultConsulTemplateShareProcessNamespaceAnnotation,
+	VaultConsulTemplateCPUAnnotation,
+	VaultConsulTemplateMemoryAnnotation,
+	VaultConsuleTemplateSecretsMountPathAnnotation,
+	VaultConsuleTemplateInjectInInitcontainersAnnotation,
+}
+
+// DeprecatedAnnotations maps deprecated annotation aliases to their replacements.
+var DeprecatedAnnotations = map[string]string{
+	VaultAgentCPUAnnotation:                        VaultAgentCPULimitAnnotation,
+	VaultAgentMemoryAnnotation:                     VaultAgentMemoryLimitAnnotation,
+	VaultConsuleTemplateSecretsMountPathAnnotation: VaultConfigfilePathAnnotation,
+}
+
 func HasVaultPrefix(value string) bool {
 	return strings.HasPrefix(value, "vault:") || strings.HasPrefix(value, ">>vault:")
 }
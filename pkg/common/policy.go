@@ -41,10 +41,11 @@ func SplitAndTrim(csv string) []string {
 
 // ResolveObjectSkipVerify honors an object-supplied skip-verify annotation only
 // when the operator opted in (allowEnvVar); otherwise it returns the operator default.
-func ResolveObjectSkipVerify(annotationValue, allowEnvVar, defaultEnvVar string) bool {
+// ignored reports whether a request to skip verification was dropped.
+func ResolveObjectSkipVerify(annotationValue, allowEnvVar, defaultEnvVar string) (skipVerify bool, ignored bool) {
 	requested, _ := strconv.ParseBool(annotationValue)
 	if viper.GetBool(allowEnvVar) {
-		return requested
+		return requested, false
 	}
 
 	if requested {
@@ -52,5 +53,5 @@ func ResolveObjectSkipVerify(annotationValue, allowEnvVar, defaultEnvVar string)
 			slog.String("allow_env", allowEnvVar))
 	}
 
-	return viper.GetBool(defaultEnvVar)
+	return viper.GetBool(defaultEnvVar), requested
 }
@@ -39,9 +39,10 @@ func TestResolveObjectSkipVerify(t *testing.T) {
 		allow           bool
 		operatorDefault bool
 		want            bool
+		wantIgnored     bool
 	}{
-		{name: "annotation true ignored by default falls back to operator default false", annotationValue: "true", allow: false, operatorDefault: false, want: false},
-		{name: "annotation true ignored by default falls back to operator default true", annotationValue: "true", allow: false, operatorDefault: true, want: true},
+		{name: "annotation true ignored by default falls back to operator default false", annotationValue: "true", allow: false, operatorDefault: false, want: false, wantIgnored: true},
+		{name: "annotation true ignored by default falls back to operator default true", annotationValue: "true", allow: false, operatorDefault: true, want: true, wantIgnored: true},
 		{name: "annotation true honored when opted in", annotationValue: "true", allow: true, operatorDefault: false, want: true},
 		{name: "annotation false honored when opted in", annotationValue: "false", allow: true, operatorDefault: true, want: false},
 		{name: "annotation false ignored by default falls back to operator default", annotationValue: "false", allow: false, operatorDefault: true, want: true},
@@ -53,7 +54,9 @@ func TestResolveObjectSkipVerify(t *testing.T) {
 			viper.Set(defaultEnv, tt.operatorDefault)
 			t.Cleanup(viper.Reset)
 
-			assert.Equal(t, tt.want, ResolveObjectSkipVerify(tt.annotationValue, allowEnv, defaultEnv))
+			skipVerify, ignored := ResolveObjectSkipVerify(tt.annotationValue, allowEnv, defaultEnv)
+			assert.Equal(t, tt.want, skipVerify)
+			assert.Equal(t, tt.wantIgnored, ignored)
 		})
 	}
 }
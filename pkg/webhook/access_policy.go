@@ -0,0 +1,510 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// VaultAccessPolicyResource is the resource of the cluster-scoped
+// VaultAccessPolicy custom resource.
+var VaultAccessPolicyResource = schema.GroupVersionResource{
+	Group:    "vault.security.banzaicloud.io",
+	Version:  "v1alpha1",
+	Resource: "vaultaccesspolicies",
+}
+
+// VaultAccessPolicy allows the objects in the namespaces selected by its
+// namespace selector to use the listed Vault roles, auth paths and secret
+// paths. Once access policies are enabled, every Vault role, auth path and
+// secret path an object uses must be allowed by at least one matching policy,
+// and so must the AppRole, certificate auth and TLS Secrets of the webhook
+// namespace it uses and the Vault address it sets with an annotation.
+type VaultAccessPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VaultAccessPolicySpec `json:"spec"`
+}
+
+// VaultAccessPolicySpec lists what a VaultAccessPolicy allows. Roles, auth paths,
+// service accounts, Secrets and addresses are glob patterns, secret paths are path prefixes.
+type VaultAccessPolicySpec struct {
+	// NamespaceSelector selects the namespaces the policy applies to.
+	// A missing selector selects every namespace.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// ServiceAccounts limits the policy to pods running with these service
+	// accounts. Without service accounts the policy applies to every object.
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+
+	Roles                []string `json:"roles,omitempty"`
+	AuthPaths            []string `json:"authPaths,omitempty"`
+	VaultServiceAccounts []string `json:"vaultServiceAccounts,omitempty"`
+	SecretPaths          []string `json:"secretPaths,omitempty"`
+
+	// AppRoleSecrets and CertAuthSecrets are the Secrets of the webhook namespace
+	// the webhook may log in to Vault with on behalf of the objects.
+	AppRoleSecrets  []string `json:"appRoleSecrets,omitempty"`
+	CertAuthSecrets []string `json:"certAuthSecrets,omitempty"`
+	// TLSSecrets are the Secrets of the webhook namespace holding the Vault CA,
+	// TLSClientCert allows presenting their client certificate to Vault.
+	TLSSecrets    []string `json:"tlsSecrets,omitempty"`
+	TLSClientCert bool     `json:"tlsClientCert,omitempty"`
+	// VaultAddrs are the Vault addresses objects may set with the vault-addr annotation.
+	VaultAddrs []string `json:"vaultAddrs,omitempty"`
+	// CommonNames are the common names Secrets may request certificates for with the
+	// vault-secret-source-common-name annotation, besides the names under <namespace>.svc.
+	CommonNames []string `json:"commonNames,omitempty"`
+}
+
+// vaultReference is a Vault secret path referenced by an object.
+type vaultReference struct {
+	// Source names where the path is referenced, e.g. "env var DB_PASSWORD of container app".
+	Source string
+	Path   string
+	// Version is the KV version pinned with vault:path#key#version, empty for the latest version.
+	Version string
+}
+
+// transitCiphertextRegex matches the values decrypted with the transit secrets
+// engine, they don't reference a secret path.
+var transitCiphertextRegex = regexp.MustCompile(`^vault:v\d+:`)
+
+// WatchAccessPolicies starts watching VaultAccessPolicy resources and namespaces,
+// and blocks until their caches are synced. Once it returns, every admission
+// request is authorized against the matching policies.
+func (mw *MutatingWebhook) WatchAccessPolicies(ctx context.Context, dynamicClient dynamic.Interface) error {
+	store, err := mw.watchPolicies(ctx, dynamicClient, VaultAccessPolicyResource, toVaultAccessPolicy)
+	if err != nil {
+		return errors.WrapIf(err, "failed to watch VaultAccessPolicies")
+	}
+
+	mw.accessPolicies = store
+
+	return nil
+}
+
+// authorizeObject authorizes the Vault config and the Vault references of an
+// object before anything is read from Vault. Objects that don't use Vault are
+// always allowed.
+func (mw *MutatingWebhook) authorizeObject(obj metav1.Object, vaultConfig VaultConfig) error {
+	if mw.accessPolicies == nil {
+		return nil
+	}
+
+	references := objectVaultReferences(obj, vaultConfig)
+
+	usesVault := len(references) > 0
+	switch obj.(type) {
+	case *corev1.Pod:
+		usesVault = usesVault || vaultConfig.CtConfigMap != "" || vaultConfig.AgentConfigMap != ""
+	case *unstructured.Unstructured:
+		usesVault = true
+	}
+
+	if !usesVault {
+		return nil
+	}
+
+	return mw.authorizeVaultAccess(vaultConfig.ObjectNamespace, objectServiceAccount(obj), vaultConfig, references)
+}
+
+// authorizeVaultAccess checks that the policies matching the namespace and
+// service account allow the Vault role, auth paths, service account, the Secrets
+// of the webhook namespace, the Vault address set by the object and the referenced
+// secret paths. The returned error names the first rejected one.
+func (mw *MutatingWebhook) authorizeVaultAccess(namespace, serviceAccount string, vaultConfig VaultConfig, references []vaultReference) error {
+	if mw.accessPolicies == nil {
+		return nil
+	}
+
+	policies, err := mw.accessPoliciesFor(namespace, serviceAccount)
+	if err != nil {
+		return err
+	}
+
+	subject := "namespace " + namespace
+	if serviceAccount != "" {
+		subject = "service account " + namespace + "/" + serviceAccount
+	}
+
+	allowed := func(match func(VaultAccessPolicySpec) bool) bool {
+		return slices.ContainsFunc(policies, func(policy *VaultAccessPolicy) bool {
+			return match(policy.Spec)
+		})
+	}
+
+	if !allowed(func(spec VaultAccessPolicySpec) bool { return matchesAny(spec.Roles, vaultConfig.Role) }) {
+		return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault role %q is not allowed for %s by any VaultAccessPolicy", vaultConfig.Role, subject))
+	}
+
+	authPaths := []string{vaultConfig.Path}
+	if vaultConfig.AppRoleSecret != "" {
+		authPaths = append(authPaths, vaultConfig.AppRolePath)
+	}
+	if vaultConfig.CertAuthSecret != "" {
+		authPaths = append(authPaths, vaultConfig.CertAuthPath)
+	}
+	for _, mountPath := range authPaths {
+		authPath := strings.TrimPrefix(strings.Trim(mountPath, "/"), "auth/")
+		if !allowed(func(spec VaultAccessPolicySpec) bool { return matchesAny(spec.AuthPaths, authPath) }) {
+			return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault auth path %q is not allowed for %s by any VaultAccessPolicy", mountPath, subject))
+		}
+	}
+
+	if vaultConfig.AppRoleSecret != "" &&
+		!allowed(func(spec VaultAccessPolicySpec) bool {
+			return matchesAny(spec.AppRoleSecrets, vaultConfig.AppRoleSecret)
+		}) {
+		return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault AppRole Secret %q is not allowed for %s by any VaultAccessPolicy", vaultConfig.AppRoleSecret, subject))
+	}
+
+	if vaultConfig.CertAuthSecret != "" &&
+		!allowed(func(spec VaultAccessPolicySpec) bool {
+			return matchesAny(spec.CertAuthSecrets, vaultConfig.CertAuthSecret)
+		}) {
+		return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault certificate auth Secret %q is not allowed for %s by any VaultAccessPolicy", vaultConfig.CertAuthSecret, subject))
+	}
+
+	if vaultConfig.TLSSecret != "" &&
+		!allowed(func(spec VaultAccessPolicySpec) bool { return matchesAny(spec.TLSSecrets, vaultConfig.TLSSecret) }) {
+		return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault TLS Secret %q is not allowed for %s by any VaultAccessPolicy", vaultConfig.TLSSecret, subject))
+	}
+
+	if vaultConfig.TLSClientCert &&
+		!allowed(func(spec VaultAccessPolicySpec) bool {
+			return spec.TLSClientCert && matchesAny(spec.TLSSecrets, vaultConfig.TLSSecret)
+		}) {
+		return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault TLS client certificate of Secret %q is not allowed for %s by any VaultAccessPolicy", vaultConfig.TLSSecret, subject))
+	}
+
+	// Addresses set by the operator are trusted, the address allowlist only vets the annotation
+	if vaultConfig.AddrFromObject &&
+		!allowed(func(spec VaultAccessPolicySpec) bool { return matchesAny(spec.VaultAddrs, vaultConfig.Addr) }) {
+		return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault address %q is not allowed for %s by any VaultAccessPolicy", vaultConfig.Addr, subject))
+	}
+
+	if vaultConfig.VaultServiceAccount != "" &&
+		!allowed(func(spec VaultAccessPolicySpec) bool {
+			return matchesAny(spec.VaultServiceAccounts, vaultConfig.VaultServiceAccount)
+		}) {
+		return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault service account %q is not allowed for %s by any VaultAccessPolicy", vaultConfig.VaultServiceAccount, subject))
+	}
+
+	for _, reference := range references {
+		if !isCleanSecretPath(reference.Path) {
+			return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault secret path %q referenced by %s has empty, . or .. segments", reference.Path, reference.Source))
+		}
+		if !allowed(func(spec VaultAccessPolicySpec) bool { return hasPathPrefix(spec.SecretPaths, reference.Path) }) {
+			return withReason(ReasonVaultAccessDenied, errors.Errorf("Vault secret path %q referenced by %s is not allowed for %s by any VaultAccessPolicy", reference.Path, reference.Source, subject))
+		}
+	}
+
+	return nil
+}
+
+// authorizeCommonName checks that the common name of the certificates issued to a Secret
+// is a name under <namespace>.svc, or is allowed for its namespace by a policy, so that
+// Secrets cannot request certificates for the names of other namespaces or services.
+// Names under <namespace>.svc are allowed even if access policies are disabled.
+func (mw *MutatingWebhook) authorizeCommonName(namespace, commonName string) error {
+	if strings.HasSuffix(commonName, "."+namespace+".svc") {
+		return nil
+	}
+
+	if mw.accessPolicies != nil {
+		policies, err := mw.accessPoliciesFor(namespace, "")
+		if err != nil {
+			return err
+		}
+
+		if slices.ContainsFunc(policies, func(policy *VaultAccessPolicy) bool {
+			return matchesAny(policy.Spec.CommonNames, commonName)
+		}) {
+			return nil
+		}
+	}
+
+	return withReason(ReasonVaultAccessDenied, errors.Errorf("common name %q is not under %s.svc nor allowed for namespace %s by any VaultAccessPolicy", commonName, namespace, namespace))
+}
+
+// accessPoliciesFor returns the VaultAccessPolicies matching the given namespace and service account.
+func (mw *MutatingWebhook) accessPoliciesFor(namespace, serviceAccount string) ([]*VaultAccessPolicy, error) {
+	namespaceLabels, err := mw.namespaceLabels(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []*VaultAccessPolicy
+	for _, obj := range mw.accessPolicies.List() {
+		policy, ok := obj.(*VaultAccessPolicy)
+		if !ok {
+			continue
+		}
+
+		selector, err := namespaceSelector(policy.Spec.NamespaceSelector)
+		if err != nil {
+			mw.logger.Warn("ignoring VaultAccessPolicy with invalid namespace selector",
+				slog.String("policy", policy.Name), slog.Any("error", err))
+
+			continue
+		}
+
+		if !selector.Matches(namespaceLabels) {
+			continue
+		}
+
+		if len(policy.Spec.ServiceAccounts) > 0 && !matchesAny(policy.Spec.ServiceAccounts, serviceAccount) {
+			continue
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// matchesAny reports whether the value matches any of the glob patterns.
+func matchesAny(patterns []string, value string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, err := path.Match(pattern, value)
+
+		return err == nil && matched
+	})
+}
+
+// hasPathPrefix reports whether the secret path is one of the prefixes or below one of them.
+// Paths with empty, . or .. segments are below none, as Vault may resolve them elsewhere.
+func hasPathPrefix(prefixes []string, secretPath string) bool {
+	if !isCleanSecretPath(secretPath) {
+		return false
+	}
+	secretPath = strings.TrimPrefix(secretPath, "/")
+
+	return slices.ContainsFunc(prefixes, func(prefix string) bool {
+		prefix = strings.Trim(prefix, "/")
+		if prefix == "" {
+			return false
+		}
+
+		return secretPath == prefix || strings.HasPrefix(secretPath, prefix+"/")
+	})
+}
+
+// isCleanSecretPath reports whether the secret path has no empty, . or .. segments,
+// apart from a leading slash.
+func isCleanSecretPath(secretPath string) bool {
+	for _, segment := range strings.Split(strings.TrimPrefix(secretPath, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// objectServiceAccount returns the service account a pod runs with,
+// other objects are not bound to a service account.
+func objectServiceAccount(obj metav1.Object) string {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return ""
+	}
+
+	return podServiceAccount(&pod.Spec)
+}
+
+func podServiceAccount(podSpec *corev1.PodSpec) string {
+	if podSpec.ServiceAccountName == "" {
+		return "default"
+	}
+
+	return podSpec.ServiceAccountName
+}
+
+// objectVaultReferences returns the Vault secret paths referenced by an object.
+// Pod env vars coming from ConfigMaps and Secrets are resolved during the
+// mutation of the containers and authorized there.
+func objectVaultReferences(obj metav1.Object, vaultConfig VaultConfig) []vaultReference {
+	var references []vaultReference
+
+	if vaultConfig.VaultEnvFromPath != "" {
+		for _, secretPath := range strings.Split(vaultConfig.VaultEnvFromPath, ",") {
+			references = append(references, vaultReference{
+				Source: "annotation " + common.VaultEnvFromPathAnnotation,
+				Path:   strings.TrimSpace(secretPath),
+			})
+		}
+	}
+
+	switch v := obj.(type) {
+	case *corev1.Pod:
+		for _, container := range append(slices.Clone(v.Spec.InitContainers), v.Spec.Containers...) {
+			for _, env := range container.Env {
+				source := fmt.Sprintf("env var %s of container %s", env.Name, container.Name)
+				references = append(references, vaultReferences(source, env.Value)...)
+			}
+		}
+
+	case *corev1.Secret:
+		if source, ok := v.Annotations[common.VaultSecretSourceAnnotation]; ok {
+			references = append(references, vaultReference{
+				Source: "annotation " + common.VaultSecretSourceAnnotation,
+				Path:   source,
+			})
+		}
+
+		data := secretData(v)
+		for _, key := range slices.Sorted(maps.Keys(data)) {
+			value := data[key]
+			source := "key " + key
+			if key == corev1.DockerConfigJsonKey {
+				references = append(references, dockerConfigVaultReferences(source, value)...)
+
+				continue
+			}
+
+			references = append(references, vaultReferences(source, string(value))...)
+		}
+
+	case *corev1.ConfigMap:
+		for _, key := range slices.Sorted(maps.Keys(v.Data)) {
+			references = append(references, vaultReferences("key "+key, v.Data[key])...)
+		}
+		for _, key := range slices.Sorted(maps.Keys(v.BinaryData)) {
+			references = append(references, vaultReferences("key "+key, string(v.BinaryData[key]))...)
+		}
+
+	case *unstructured.Unstructured:
+		references = append(references, unstructuredVaultReferences("", v.Object)...)
+	}
+
+	return references
+}
+
+// vaultReferences returns the secret paths referenced by a value, either with
+// the vault: prefix or inline with ${vault:...} delimiters.
+func vaultReferences(source, value string) []vaultReference {
+	var values []string
+	if matches := injector.FindInlineVaultDelimiters(value); len(matches) > 0 {
+		for _, match := range matches {
+			values = append(values, match[1])
+		}
+	} else if common.HasVaultPrefix(value) {
+		values = append(values, value)
+	}
+
+	var references []vaultReference
+	for _, value := range values {
+		value = strings.TrimPrefix(value, ">>")
+		if transitCiphertextRegex.MatchString(value) || value == "vault:login" {
+			continue
+		}
+
+		secretPath, key, _ := strings.Cut(strings.TrimPrefix(value, "vault:"), "#")
+		_, version, _ := strings.Cut(key, "#")
+		references = append(references, vaultReference{Source: source, Path: secretPath, Version: version})
+	}
+
+	return references
+}
+
+// dockerConfigVaultReferences returns the secret paths referenced by the
+// vault:path#username:vault:path#password auths of a docker config.
+func dockerConfigVaultReferences(source string, value []byte) []vaultReference {
+	var dc dockerCredentials
+	if err := json.Unmarshal(value, &dc); err != nil {
+		return nil
+	}
+
+	var references []vaultReference
+	for registry, creds := range dc.Auths {
+		auth, err := base64.StdEncoding.DecodeString(creds.Auth)
+		if err != nil || !common.HasVaultPrefix(string(auth)) {
+			continue
+		}
+
+		split := strings.Split(string(auth), ":")
+		if len(split) != 4 {
+			continue
+		}
+
+		authSource := fmt.Sprintf("%s auth of %s", source, registry)
+		references = append(references, vaultReferences(authSource, split[0]+":"+split[1])...)
+		references = append(references, vaultReferences(authSource, split[2]+":"+split[3])...)
+	}
+
+	return references
+}
+
+// unstructuredVaultReferences returns the secret paths referenced by the string
+// fields of an object, with the source naming the field path.
+func unstructuredVaultReferences(fieldPath string, o any) []vaultReference {
+	var references []vaultReference
+
+	switch value := o.(type) {
+	case map[string]any:
+		for _, key := range slices.Sorted(maps.Keys(value)) {
+			references = append(references, unstructuredVaultReferences(strings.TrimPrefix(fieldPath+"."+key, "."), value[key])...)
+		}
+	case []any:
+		for i, item := range value {
+			references = append(references, unstructuredVaultReferences(fmt.Sprintf("%s[%d]", fieldPath, i), item)...)
+		}
+	case string:
+		references = append(references, vaultReferences("field "+fieldPath, value)...)
+	}
+
+	return references
+}
+
+// toVaultAccessPolicy converts the unstructured objects of the informer to
+// VaultAccessPolicies, so that requests don't have to convert them.
+func toVaultAccessPolicy(obj any) (any, error) { //nolint:unparam // implements cache.TransformFunc
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+
+	policy := &VaultAccessPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), policy); err != nil {
+		// Keep the unstructured object, it doesn't allow anything.
+		logger.Warn("ignoring malformed VaultAccessPolicy", slog.String("policy", u.GetName()), slog.Any("error", err))
+
+		return obj, nil
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,373 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func newAccessPolicy(t *testing.T, name string, selector *metav1.LabelSelector, spec VaultAccessPolicySpec) runtime.Object {
+	t.Helper()
+
+	spec.NamespaceSelector = selector
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&VaultAccessPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: VaultAccessPolicyResource.GroupVersion().String(),
+			Kind:       "VaultAccessPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec,
+	})
+	require.NoError(t, err)
+
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestAccessPolicies(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	k8sClient := fake.NewClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"team": "web"}}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-env", Namespace: "payments"},
+			Data:       map[string]string{"WEB_PASSWORD": "vault:secret/data/web/db#password"},
+		},
+	)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{VaultAccessPolicyResource: "VaultAccessPolicyList"},
+		newAccessPolicy(t, "payments", &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}, VaultAccessPolicySpec{
+			ServiceAccounts:      []string{"payments-*"},
+			Roles:                []string{"payments-*"},
+			AuthPaths:            []string{"kubernetes"},
+			VaultServiceAccounts: []string{"payments-reader"},
+			SecretPaths:          []string{"secret/data/payments"},
+			AppRoleSecrets:       []string{"payments-approle"},
+			TLSSecrets:           []string{"vault-tls"},
+			VaultAddrs:           []string{"https://vault.payments.svc:8200"},
+		}),
+		newAccessPolicy(t, "shared", nil, VaultAccessPolicySpec{
+			Roles:       []string{"default"},
+			AuthPaths:   []string{"kubernetes"},
+			SecretPaths: []string{"secret/data/shared/"},
+		}),
+	)
+
+	mw := &MutatingWebhook{
+		k8sClient: k8sClient,
+		registry:  &MockRegistry{Image: v1.Config{}},
+		logger:    slog.New(slog.DiscardHandler),
+	}
+	require.NoError(t, mw.WatchAccessPolicies(context.Background(), dynamicClient))
+
+	newPod := func(serviceAccount string, annotations map[string]string, container corev1.Container) *corev1.Pod {
+		container.Name = "app"
+		container.Image = "myimage"
+		container.Command = []string{"/bin/app"}
+
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "payments", Annotations: annotations},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: serviceAccount,
+				Containers:         []corev1.Container{container},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		obj       metav1.Object
+		// vaultConfig adjusts the Vault config of the object, for settings objects can't choose
+		vaultConfig func(*VaultConfig)
+		wantErr     string
+	}{
+		{
+			name:      "pod using allowed role and secret paths",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{
+					{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"},
+					{Name: "API_KEY", Value: "key=${vault:secret/data/shared/api#key}"},
+				},
+			}),
+		},
+		{
+			name:      "pod not using Vault",
+			namespace: "web",
+			obj:       newPod("", nil, corev1.Container{Env: []corev1.EnvVar{{Name: "PLAIN", Value: "value"}}}),
+		},
+		{
+			name:      "pod referencing a secret path of another team",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments-archive/db#password"}},
+			}),
+			wantErr: `Vault secret path "secret/data/payments-archive/db" referenced by env var DB_PASSWORD of container app is not allowed for service account payments/payments-api`,
+		},
+		{
+			name:      "pod traversing out of an allowed secret path",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/../web/db#password"}},
+			}),
+			wantErr: `Vault secret path "secret/data/payments/../web/db" referenced by env var DB_PASSWORD of container app has empty, . or .. segments`,
+		},
+		{
+			name:      "pod referencing an allowed secret path with empty segments",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments//db#password"}},
+			}),
+			wantErr: `Vault secret path "secret/data/payments//db" referenced by env var DB_PASSWORD of container app has empty, . or .. segments`,
+		},
+		{
+			name:      "pod referencing a secret path of another team through a ConfigMap",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "web-env"},
+				}}},
+			}),
+			wantErr: `Vault secret path "secret/data/web/db" referenced by env var WEB_PASSWORD of container app`,
+		},
+		{
+			name:      "pod overriding the role",
+			namespace: "payments",
+			obj: newPod("payments-api", map[string]string{common.VaultRoleAnnotation: "admin"}, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			wantErr: `Vault role "admin" is not allowed for service account payments/payments-api`,
+		},
+		{
+			name:      "pod overriding the auth path",
+			namespace: "payments",
+			obj: newPod("payments-api", map[string]string{common.VaultPathAnnotation: "admin-k8s"}, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			wantErr: `Vault auth path "admin-k8s" is not allowed`,
+		},
+		{
+			name:      "pod using an allowed Vault service account",
+			namespace: "payments",
+			obj: newPod("payments-api", map[string]string{common.VaultServiceaccountAnnotation: "payments-reader"}, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+		},
+		{
+			name:      "pod impersonating another service account",
+			namespace: "payments",
+			obj: newPod("payments-api", map[string]string{common.VaultServiceaccountAnnotation: "payments-admin"}, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			wantErr: `Vault service account "payments-admin" is not allowed`,
+		},
+		{
+			name:      "pod logging in with an allowed AppRole Secret",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			vaultConfig: func(vaultConfig *VaultConfig) {
+				vaultConfig.AppRoleSecret = "payments-approle"
+				vaultConfig.AppRolePath = "kubernetes"
+			},
+		},
+		{
+			name:      "pod logging in with the AppRole Secret of another team",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			vaultConfig: func(vaultConfig *VaultConfig) {
+				vaultConfig.AppRoleSecret = "operator-approle"
+				vaultConfig.AppRolePath = "kubernetes"
+			},
+			wantErr: `Vault AppRole Secret "operator-approle" is not allowed for service account payments/payments-api`,
+		},
+		{
+			name:      "pod logging in at an AppRole mount that is not allowed",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			vaultConfig: func(vaultConfig *VaultConfig) {
+				vaultConfig.AppRoleSecret = "payments-approle"
+				vaultConfig.AppRolePath = "approle"
+			},
+			wantErr: `Vault auth path "approle" is not allowed`,
+		},
+		{
+			name:      "pod logging in with a certificate auth Secret",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			vaultConfig: func(vaultConfig *VaultConfig) {
+				vaultConfig.CertAuthSecret = "vault-client-cert"
+				vaultConfig.CertAuthPath = "kubernetes"
+			},
+			wantErr: `Vault certificate auth Secret "vault-client-cert" is not allowed`,
+		},
+		{
+			name:      "pod presenting the client certificate of the TLS Secret",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			vaultConfig: func(vaultConfig *VaultConfig) {
+				vaultConfig.TLSSecret = "vault-tls"
+				vaultConfig.TLSClientCert = true
+			},
+			wantErr: `Vault TLS client certificate of Secret "vault-tls" is not allowed`,
+		},
+		{
+			name:      "pod setting a Vault address that is not allowed",
+			namespace: "payments",
+			obj: newPod("payments-api", nil, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			vaultConfig: func(vaultConfig *VaultConfig) {
+				vaultConfig.Addr = "https://vault.web.svc:8200"
+				vaultConfig.AddrFromObject = true
+			},
+			wantErr: `Vault address "https://vault.web.svc:8200" is not allowed`,
+		},
+		{
+			name:      "pod running with a service account not covered by the team policy",
+			namespace: "payments",
+			obj: newPod("batch", map[string]string{common.VaultRoleAnnotation: "default"}, corev1.Container{
+				Env: []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/payments/db#password"}},
+			}),
+			wantErr: `Vault secret path "secret/data/payments/db" referenced by env var DB_PASSWORD of container app is not allowed for service account payments/batch`,
+		},
+		{
+			name:      "secret referencing a secret path of another team is rejected before reading Vault",
+			namespace: "web",
+			obj: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "web"},
+				Data: map[string][]byte{
+					"password": []byte("vault:secret/data/payments/db#password"),
+				},
+			},
+			wantErr: `Vault secret path "secret/data/payments/db" referenced by key password is not allowed for namespace web`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &model.AdmissionReview{Namespace: tt.namespace}
+
+			var err error
+			if tt.vaultConfig != nil {
+				vaultConfig, _, configErr := mw.vaultConfigFor(ar, tt.obj)
+				require.NoError(t, configErr)
+				tt.vaultConfig(&vaultConfig)
+
+				err = mw.authorizeObject(tt.obj, vaultConfig)
+			} else {
+				_, err = mw.VaultSecretsMutator(context.Background(), ar, tt.obj)
+			}
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVaultReferences(t *testing.T) {
+	dockerAuth := base64.StdEncoding.EncodeToString([]byte("vault:secret/data/registry#username:vault:secret/data/registry#password"))
+
+	tests := []struct {
+		name string
+		obj  metav1.Object
+		want []vaultReference
+	}{
+		{
+			name: "prefixed, inline and update references",
+			obj: &corev1.ConfigMap{Data: map[string]string{
+				"a": "vault:secret/data/a#key#2",
+				"b": "user=${vault:secret/data/b#user} password=${>>vault:secret/data/c#password}",
+				"c": ">>vault:database/creds/app#password",
+			}},
+			want: []vaultReference{
+				{Source: "key a", Path: "secret/data/a", Version: "2"},
+				{Source: "key b", Path: "secret/data/b"},
+				{Source: "key b", Path: "secret/data/c"},
+				{Source: "key c", Path: "database/creds/app"},
+			},
+		},
+		{
+			name: "transit ciphertexts and plain values",
+			obj: &corev1.ConfigMap{Data: map[string]string{
+				"a": "vault:v1:8SDd3WHDOjf7mq69CyCqYjBXAiQQAVZRkFM13ok481zoCmHnSeDX9vyf7w==",
+				"b": "plain",
+			}},
+		},
+		{
+			name: "docker config auths",
+			obj: &corev1.Secret{Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.example.com":{"auth":"` + dockerAuth + `"}}}`),
+			}},
+			want: []vaultReference{
+				{Source: "key .dockerconfigjson auth of registry.example.com", Path: "secret/data/registry"},
+				{Source: "key .dockerconfigjson auth of registry.example.com", Path: "secret/data/registry"},
+			},
+		},
+		{
+			name: "fields of arbitrary objects",
+			obj: &unstructured.Unstructured{Object: map[string]any{
+				"spec": map[string]any{
+					"passwords": []any{"vault:secret/data/a#password"},
+				},
+			}},
+			want: []vaultReference{
+				{Source: "field spec.passwords[0]", Path: "secret/data/a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, objectVaultReferences(tt.obj, VaultConfig{}))
+		})
+	}
+}
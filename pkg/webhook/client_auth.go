@@ -0,0 +1,120 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+
+	"emperror.dev/errors"
+	"github.com/bank-vaults/vault-sdk/vault"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AppRoleRoleIDKey is the key of the AppRole role ID in the AppRole Secret.
+	AppRoleRoleIDKey = "role-id"
+	// AppRoleSecretIDKey is the key of the AppRole secret ID in the AppRole Secret.
+	AppRoleSecretIDKey = "secret-id"
+)
+
+// newVaultClientWithLogin logs in with the AppRole or TLS certificate auth method
+// configured in the VaultConfig, instead of letting the Vault client log in with
+// the service account of the webhook or of the object.
+func (mw *MutatingWebhook) newVaultClientWithLogin(ctx context.Context, clientConfig *vaultapi.Config, vaultConfig VaultConfig) (*vault.Client, error) {
+	rawClient, err := vaultapi.NewClient(clientConfig)
+	if err != nil {
+		vaultAuthAttemptsErrorsCount.WithLabelValues("config_error").Inc()
+		return nil, err
+	}
+
+	// Don't send a token picked up from the environment with the login request
+	rawClient.ClearToken()
+
+	if vaultConfig.VaultNamespace != "" {
+		rawClient.SetNamespace(vaultConfig.VaultNamespace)
+	}
+
+	var loginPath string
+	var loginData map[string]any
+
+	if vaultConfig.AppRoleSecret != "" {
+		secret, err := mw.k8sClient.CoreV1().Secrets(mw.namespace).Get(ctx, vaultConfig.AppRoleSecret, metav1.GetOptions{})
+		if err != nil {
+			vaultAuthAttemptsErrorsCount.WithLabelValues("kubernetes_error").Inc()
+			return nil, errors.Wrap(err, "failed to read Vault AppRole Secret")
+		}
+
+		roleID, secretID := secret.Data[AppRoleRoleIDKey], secret.Data[AppRoleSecretIDKey]
+		if len(roleID) == 0 || len(secretID) == 0 {
+			vaultAuthAttemptsErrorsCount.WithLabelValues("config_error").Inc()
+			return nil, errors.Errorf("Vault AppRole Secret %s must contain %s and %s", secret.Name, AppRoleRoleIDKey, AppRoleSecretIDKey)
+		}
+
+		loginPath = "auth/" + vaultConfig.AppRolePath + "/login"
+		loginData = map[string]any{
+			"role_id":   string(roleID),
+			"secret_id": string(secretID),
+		}
+	} else {
+		// The client certificate is presented during the TLS handshake
+		loginPath = "auth/" + vaultConfig.CertAuthPath + "/login"
+	}
+
+	secret, err := rawClient.Logical().WriteWithContext(ctx, loginPath, loginData)
+	if err != nil {
+		vaultAuthAttemptsErrorsCount.WithLabelValues("login_error").Inc()
+		return nil, errors.Wrapf(err, "failed to log in to Vault at %s", loginPath)
+	}
+
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		vaultAuthAttemptsErrorsCount.WithLabelValues("login_error").Inc()
+		return nil, errors.Errorf("no token returned by Vault login at %s", loginPath)
+	}
+
+	client, err := vault.NewClientFromRawClientWithContext(
+		ctx,
+		rawClient,
+		vault.ClientToken(secret.Auth.ClientToken),
+		vault.ClientLogger(&clientLogger{logger: mw.logger}),
+		vault.VaultNamespace(vaultConfig.VaultNamespace),
+	)
+	if err != nil {
+		vaultAuthAttemptsErrorsCount.WithLabelValues("config_error").Inc()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// getCertAuthCertificate reads the client certificate used for TLS certificate
+// auth from a kubernetes.io/tls Secret in the namespace of the webhook.
+func (mw *MutatingWebhook) getCertAuthCertificate(ctx context.Context, secretName string) (tls.Certificate, error) {
+	secret, err := mw.k8sClient.CoreV1().Secrets(mw.namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		vaultAuthAttemptsErrorsCount.WithLabelValues("kubernetes_error").Inc()
+		return tls.Certificate{}, errors.Wrap(err, "failed to read Vault certificate auth Secret")
+	}
+
+	certificate, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		vaultAuthAttemptsErrorsCount.WithLabelValues("config_error").Inc()
+		return tls.Certificate{}, errors.Wrapf(err, "error loading client certificate from Secret: %s", secretName)
+	}
+
+	return certificate, nil
+}
@@ -0,0 +1,258 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newClientCertificate returns a self-signed client certificate and its key in PEM format.
+func newClientCertificate(t *testing.T) (*x509.Certificate, []byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "vault-secrets-webhook"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return certificate,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestNewVaultClientAppRoleAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		secretData  map[string][]byte
+		appRolePath string
+		wantErr     string
+	}{
+		{
+			name: "logs in with role-id and secret-id",
+			secretData: map[string][]byte{
+				AppRoleRoleIDKey:   []byte("my-role-id"),
+				AppRoleSecretIDKey: []byte("my-secret-id"),
+			},
+			appRolePath: "approle",
+		},
+		{
+			name: "logs in at a custom mount path",
+			secretData: map[string][]byte{
+				AppRoleRoleIDKey:   []byte("my-role-id"),
+				AppRoleSecretIDKey: []byte("my-secret-id"),
+			},
+			appRolePath: "webhook-approle",
+		},
+		{
+			name: "rejects a Secret without secret-id",
+			secretData: map[string][]byte{
+				AppRoleRoleIDKey: []byte("my-role-id"),
+			},
+			appRolePath: "approle",
+			wantErr:     "must contain role-id and secret-id",
+		},
+		{
+			name: "fails on rejected credentials",
+			secretData: map[string][]byte{
+				AppRoleRoleIDKey:   []byte("my-role-id"),
+				AppRoleSecretIDKey: []byte("wrong-secret-id"),
+			},
+			appRolePath: "approle",
+			wantErr:     "failed to log in to Vault",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/auth/"+tt.appRolePath+"/login" {
+					w.WriteHeader(http.StatusNotFound)
+
+					return
+				}
+
+				var body map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["role_id"] != "my-role-id" || body["secret_id"] != "my-secret-id" {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"errors": ["invalid role or secret ID"]}`)
+
+					return
+				}
+
+				fmt.Fprint(w, `{"auth": {"client_token": "approle-token", "lease_duration": 3600}}`)
+			}))
+			defer server.Close()
+
+			mw := &MutatingWebhook{
+				k8sClient: fake.NewClientset(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "vault-approle", Namespace: "vault-infra"},
+					Data:       tt.secretData,
+				}),
+				namespace: "vault-infra",
+				logger:    slog.New(slog.DiscardHandler),
+			}
+
+			client, err := mw.newVaultClient(t.Context(), VaultConfig{
+				Addr:          server.URL,
+				AppRoleSecret: "vault-approle",
+				AppRolePath:   tt.appRolePath,
+			})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+
+			assert.Equal(t, "approle-token", client.RawClient().Token())
+		})
+	}
+}
+
+func TestNewVaultClientCertAuth(t *testing.T) {
+	clientCertificate, certPEM, keyPEM := newClientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCertificate)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/cert/login" || len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		fmt.Fprint(w, `{"auth": {"client_token": "cert-token", "lease_duration": 3600}}`)
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		name       string
+		secretData map[string][]byte
+		wantErr    string
+	}{
+		{
+			name: "logs in with the client certificate",
+			secretData: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+			},
+		},
+		{
+			name: "rejects a Secret without private key",
+			secretData: map[string][]byte{
+				corev1.TLSCertKey: certPEM,
+			},
+			wantErr: "error loading client certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := &MutatingWebhook{
+				k8sClient: fake.NewClientset(
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "vault-client-cert", Namespace: "vault-infra"},
+						Data:       tt.secretData,
+					},
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "vault-tls", Namespace: "vault-infra"},
+						Data:       map[string][]byte{"ca.crt": serverCA},
+					},
+				),
+				namespace: "vault-infra",
+				logger:    slog.New(slog.DiscardHandler),
+			}
+
+			client, err := mw.newVaultClient(t.Context(), VaultConfig{
+				Addr:           server.URL,
+				TLSSecret:      "vault-tls",
+				CertAuthSecret: "vault-client-cert",
+				CertAuthPath:   "cert",
+			})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+
+			assert.Equal(t, "cert-token", client.RawClient().Token())
+		})
+	}
+}
+
+func TestNewVaultClientRejectsMultipleAuthMethods(t *testing.T) {
+	mw := &MutatingWebhook{
+		k8sClient: fake.NewClientset(),
+		namespace: "vault-infra",
+		logger:    slog.New(slog.DiscardHandler),
+	}
+
+	_, err := mw.newVaultClient(t.Context(), VaultConfig{
+		Addr:           "https://vault:8200",
+		AppRoleSecret:  "vault-approle",
+		CertAuthSecret: "vault-client-cert",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mutually exclusive")
+}
@@ -0,0 +1,127 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bank-vaults/vault-sdk/vault"
+	"github.com/patrickmn/go-cache"
+	"github.com/spf13/viper"
+)
+
+// vaultClientCacheTTLMargin is subtracted from the token TTL, so that cached
+// clients are dropped well before their token expires.
+const vaultClientCacheTTLMargin = 30 * time.Second
+
+// vaultClientCache keeps authenticated Vault clients, so that admission
+// requests with the same Vault identity reuse the token instead of logging in again.
+type vaultClientCache struct {
+	clients *cache.Cache
+}
+
+func newVaultClientCache() *vaultClientCache {
+	clients := cache.New(cache.NoExpiration, time.Minute)
+	clients.OnEvicted(func(_ string, client any) {
+		client.(*vault.Client).Close()
+	})
+
+	return &vaultClientCache{clients: clients}
+}
+
+// vaultClientCacheKey identifies the Vault identity of a VaultConfig.
+func vaultClientCacheKey(vaultConfig VaultConfig) string {
+	return strings.Join([]string{
+		vaultConfig.Addr,
+		vaultConfig.Path,
+		vaultConfig.Role,
+		vaultConfig.VaultNamespace,
+		vaultConfig.VaultServiceAccount,
+		vaultConfig.ObjectNamespace,
+		vaultConfig.AuthMethod,
+		vaultConfig.TLSSecret,
+		strconv.FormatBool(vaultConfig.TLSClientCert),
+		vaultConfig.AppRoleSecret,
+		vaultConfig.AppRolePath,
+		vaultConfig.CertAuthSecret,
+		vaultConfig.CertAuthPath,
+		strconv.FormatBool(vaultConfig.SkipVerify),
+		vaultConfig.Token,
+	}, "\x00")
+}
+
+// vaultClientFor returns an authenticated Vault client for the given config, reusing
+// a cached one if possible. The returned release function must be called once the
+// client is no longer needed.
+func (mw *MutatingWebhook) vaultClientFor(ctx context.Context, vaultConfig VaultConfig) (*vault.Client, func(), error) {
+	maxTTL, _ := time.ParseDuration(viper.GetString("vault_client_cache_max_ttl"))
+	if mw.vaultClients == nil || maxTTL <= 0 {
+		client, err := mw.newVaultClient(ctx, vaultConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return client, client.Close, nil
+	}
+
+	key := vaultClientCacheKey(vaultConfig)
+	if client, ok := mw.vaultClients.clients.Get(key); ok {
+		vaultClientCacheHitsCount.WithLabelValues().Inc()
+
+		return client.(*vault.Client), func() {}, nil
+	}
+
+	vaultClientCacheMissesCount.WithLabelValues().Inc()
+
+	client, err := mw.newVaultClient(ctx, vaultConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ttl := maxTTL
+	secret, err := client.RawClient().Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		mw.logger.Debug("failed to look up Vault token, not caching the client: " + err.Error())
+
+		return client, client.Close, nil
+	}
+
+	tokenTTL, err := secret.TokenTTL()
+	if err == nil && tokenTTL > 0 {
+		ttl = min(ttl, tokenTTL-vaultClientCacheTTLMargin)
+	}
+	if ttl <= 0 {
+		return client, client.Close, nil
+	}
+
+	// Drop expired clients first, Add would silently overwrite them without closing.
+	mw.vaultClients.clients.DeleteExpired()
+
+	if err := mw.vaultClients.clients.Add(key, client, ttl); err != nil {
+		// Another request cached a client for the same identity in the meantime.
+		if cached, ok := mw.vaultClients.clients.Get(key); ok {
+			client.Close()
+
+			return cached.(*vault.Client), func() {}, nil
+		}
+
+		return client, client.Close, nil
+	}
+
+	return client, func() {}, nil
+}
@@ -0,0 +1,141 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestVaultClientCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		tokenTTL   int
+		maxTTL     string
+		roles      []string
+		wantLogins int64
+		wantHits   float64
+		wantMisses float64
+	}{
+		{
+			name:       "same identity reuses the client",
+			tokenTTL:   3600,
+			maxTTL:     "5m",
+			roles:      []string{"role", "role", "role"},
+			wantLogins: 1,
+			wantHits:   2,
+			wantMisses: 1,
+		},
+		{
+			name:       "different identities get their own clients",
+			tokenTTL:   3600,
+			maxTTL:     "5m",
+			roles:      []string{"role-a", "role-b", "role-a"},
+			wantLogins: 2,
+			wantHits:   1,
+			wantMisses: 2,
+		},
+		{
+			name:       "tokens close to expiry are not cached",
+			tokenTTL:   20,
+			maxTTL:     "5m",
+			roles:      []string{"role", "role"},
+			wantLogins: 2,
+			wantMisses: 2,
+		},
+		{
+			name:       "cache disabled",
+			tokenTTL:   3600,
+			maxTTL:     "0",
+			roles:      []string{"role", "role"},
+			wantLogins: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.Set("vault_client_cache_max_ttl", tt.maxTTL)
+
+			vaultClientCacheHitsCount.Reset()
+			vaultClientCacheMissesCount.Reset()
+
+			var logins atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+
+				if strings.HasSuffix(r.URL.Path, "/lookup-self") {
+					fmt.Fprintf(w, `{"data": {"ttl": %d}}`, tt.tokenTTL)
+
+					return
+				}
+
+				if strings.HasSuffix(r.URL.Path, "/login") {
+					logins.Add(1)
+				}
+				fmt.Fprintf(w, `{"auth": {"client_token": "test-token", "lease_duration": %d}}`, tt.tokenTTL)
+			}))
+			defer server.Close()
+
+			k8sClient := fake.NewClientset(
+				&corev1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-sa", Namespace: "test-namespace"},
+					Secrets:    []corev1.ObjectReference{{Name: "test-sa-token"}},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-sa-token", Namespace: "test-namespace"},
+					Data:       map[string][]byte{"token": []byte("sa-token")},
+				},
+			)
+
+			mw := &MutatingWebhook{
+				k8sClient:    k8sClient,
+				logger:       slog.New(slog.DiscardHandler),
+				vaultClients: newVaultClientCache(),
+			}
+			defer mw.vaultClients.clients.Flush()
+
+			for _, role := range tt.roles {
+				client, release, err := mw.vaultClientFor(t.Context(), VaultConfig{
+					Addr:                server.URL,
+					Role:                role,
+					Path:                "kubernetes",
+					VaultServiceAccount: "test-sa",
+					ObjectNamespace:     "test-namespace",
+				})
+				require.NoError(t, err)
+				require.NotNil(t, client)
+				release()
+			}
+
+			assert.Equal(t, tt.wantLogins, logins.Load())
+			assert.Equal(t, tt.wantHits, testutil.ToFloat64(vaultClientCacheHitsCount.WithLabelValues()))
+			assert.Equal(t, tt.wantMisses, testutil.ToFloat64(vaultClientCacheMissesCount.WithLabelValues()))
+		})
+	}
+}
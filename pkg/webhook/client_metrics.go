@@ -86,6 +86,24 @@ var (
 		},
 		[]string{"reason"},
 	)
+	vaultClientCacheHitsCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "vault",
+			Subsystem: "client",
+			Name:      "cache_hits_total",
+			Help:      "Count of Vault clients reused from the client cache.",
+		},
+		nil,
+	)
+	vaultClientCacheMissesCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "vault",
+			Subsystem: "client",
+			Name:      "cache_misses_total",
+			Help:      "Count of Vault client cache misses.",
+		},
+		nil,
+	)
 )
 
 // RegisterMetrics registers the Vault client metrics with Prometheus
@@ -97,6 +115,13 @@ func RegisterMetrics(registry prometheus.Registerer) {
 	registry.MustRegister(vaultRequestsErrorsCount)
 	registry.MustRegister(vaultAuthAttemptsCount)
 	registry.MustRegister(vaultAuthAttemptsErrorsCount)
+	registry.MustRegister(vaultClientCacheHitsCount)
+	registry.MustRegister(vaultClientCacheMissesCount)
+	registry.MustRegister(annotationValidationErrorsCount)
+	registry.MustRegister(imageCacheHitsCount)
+	registry.MustRegister(imageCacheMissesCount)
+	registry.MustRegister(imageCacheEvictionsCount)
+	registry.MustRegister(imageCacheLookupDuration)
 }
 
 // InstrumentErrorsAndSizeRoundTripper instruments RoundTripper to track request errors and size
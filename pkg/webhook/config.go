@@ -15,7 +15,11 @@
 package webhook
 
 import (
+	"log/slog"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"emperror.dev/errors"
@@ -37,6 +41,11 @@ type VaultConfig struct {
 	Path                          string
 	SkipVerify                    bool
 	TLSSecret                     string
+	TLSClientCert                 bool
+	AppRoleSecret                 string
+	AppRolePath                   string
+	CertAuthSecret                string
+	CertAuthPath                  string
 	ClientTimeout                 time.Duration
 	UseAgent                      bool
 	VaultEnvDaemon                bool
@@ -90,15 +99,56 @@ type VaultConfig struct {
 	VaultServiceAccount           string
 	ObjectNamespace               string
 	MutateProbes                  bool
+	NativeSidecars                bool
+	NativeSidecarsStartupProbe    bool
 	Token                         string
+	TokenSecret                   string
+	TokenSecretKey                string
+	TokenSecretCreate             bool
+	TokenAsFile                   bool
+	ContainerEntrypoints          map[string]imageEntrypoint
 }
 
-func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig, error) {
+// parseVaultConfig builds the VaultConfig of an object from its annotations and the
+// webhook defaults, which may be overridden by VaultInjectionPolicies. Namespace
+// annotations apply as if they were set on the object, unless the object overrides
+// them. The returned warnings are meant to be shown to the user as admission warnings.
+func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview, defaults configDefaults, namespaceAnnotations map[string]string) (VaultConfig, []string, error) {
+	validator := &annotationValidator{}
+
+	vaultConfig, err := parseVaultConfigAnnotations(obj, ar, defaults, namespaceAnnotations, validator)
+	if err != nil {
+		return vaultConfig, validator.warnings, err
+	}
+
+	if err := validator.err(); err != nil {
+		mode := annotationValidationMode(ar.Namespace)
+		for _, annotationErr := range validator.errs {
+			annotationValidationErrorsCount.WithLabelValues(annotationErr.Annotation, mode).Inc()
+		}
+
+		if mode == AnnotationValidationStrict {
+			return vaultConfig, validator.warnings, err
+		}
+
+		logger.Warn(err.Error(), slog.String("namespace", ar.Namespace), slog.String("name", obj.GetName()))
+
+		for _, annotationErr := range validator.errs {
+			validator.warn("annotation %s is ignored: %s", annotationErr.Annotation, annotationErr.Err)
+		}
+	}
+
+	return vaultConfig, validator.warnings, nil
+}
+
+// parseVaultConfigAnnotations builds the VaultConfig of an object, recording
+// deprecated and malformed annotations in the given validator.
+func parseVaultConfigAnnotations(obj metav1.Object, ar *model.AdmissionReview, defaults configDefaults, namespaceAnnotations map[string]string, validator *annotationValidator) (VaultConfig, error) {
 	vaultConfig := VaultConfig{
 		ObjectNamespace: ar.Namespace,
 	}
 
-	annotations := obj.GetAnnotations()
+	annotations := mergeAnnotations(namespaceAnnotations, obj.GetAnnotations())
 
 	if val := annotations[common.MutateAnnotation]; val == "skip" {
 		vaultConfig.Skip = true
@@ -106,11 +156,24 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 		return vaultConfig, nil
 	}
 
+	for _, deprecated := range slices.Sorted(maps.Keys(common.DeprecatedAnnotations)) {
+		if _, ok := annotations[deprecated]; !ok {
+			continue
+		}
+
+		replacement := common.DeprecatedAnnotations[deprecated]
+		if _, ok := annotations[replacement]; ok {
+			validator.warn("annotation %s is deprecated and conflicts with %s, which takes precedence, remove it", deprecated, replacement)
+		} else {
+			validator.warn("annotation %s is deprecated, use %s instead", deprecated, replacement)
+		}
+	}
+
 	if val, ok := annotations[common.VaultAddrAnnotation]; ok {
 		vaultConfig.Addr = val
 		vaultConfig.AddrFromObject = true
 	} else {
-		vaultConfig.Addr = viper.GetString("vault_addr")
+		vaultConfig.Addr = defaults.GetString("vault_addr")
 	}
 
 	if vaultConfig.AddrFromObject {
@@ -122,7 +185,7 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 	if val, ok := annotations[common.VaultRoleAnnotation]; ok {
 		vaultConfig.Role = val
 	} else {
-		if val := viper.GetString("vault_role"); val != "" {
+		if val := defaults.GetString("vault_role"); val != "" {
 			vaultConfig.Role = val
 		} else {
 			switch p := obj.(type) {
@@ -137,24 +200,29 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 	if val, ok := annotations[common.VaultAuthMethodAnnotation]; ok {
 		vaultConfig.AuthMethod = val
 	} else {
-		vaultConfig.AuthMethod = viper.GetString("vault_auth_method")
+		vaultConfig.AuthMethod = defaults.GetString("vault_auth_method")
 	}
 
 	if val, ok := annotations[common.VaultPathAnnotation]; ok {
 		vaultConfig.Path = val
 	} else {
-		vaultConfig.Path = viper.GetString("vault_path")
+		vaultConfig.Path = defaults.GetString("vault_path")
 	}
 
 	// TODO: Check for flag to verify we want to use namespace-local SAs instead of the vault webhook namespaces SA
 	if val, ok := annotations[common.VaultServiceaccountAnnotation]; ok {
 		vaultConfig.VaultServiceAccount = val
 	} else {
-		vaultConfig.VaultServiceAccount = viper.GetString("vault_serviceaccount")
+		vaultConfig.VaultServiceAccount = defaults.GetString("vault_serviceaccount")
 	}
 
 	if val, ok := annotations[common.VaultSkipVerifyAnnotation]; ok {
-		vaultConfig.SkipVerify = common.ResolveObjectSkipVerify(val, "vault_allow_object_skip_verify", "vault_skip_verify")
+		validator.parseBool(common.VaultSkipVerifyAnnotation, val)
+		var ignored bool
+		vaultConfig.SkipVerify, ignored = common.ResolveObjectSkipVerify(val, "vault_allow_object_skip_verify", "vault_skip_verify")
+		if ignored {
+			validator.warn("annotation %s is ignored, the webhook does not allow objects to skip TLS verification", common.VaultSkipVerifyAnnotation)
+		}
 	} else {
 		vaultConfig.SkipVerify = viper.GetBool("vault_skip_verify")
 	}
@@ -162,31 +230,69 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 	if val, ok := annotations[common.VaultTLSSecretAnnotation]; ok {
 		vaultConfig.TLSSecret = val
 	} else {
-		vaultConfig.TLSSecret = viper.GetString("vault_tls_secret")
+		vaultConfig.TLSSecret = defaults.GetString("vault_tls_secret")
+	}
+
+	if val, ok := annotations[common.VaultTLSClientCertAnnotation]; ok {
+		vaultConfig.TLSClientCert = validator.parseBool(common.VaultTLSClientCertAnnotation, val)
+	} else {
+		vaultConfig.TLSClientCert, _ = strconv.ParseBool(defaults.GetString("vault_tls_client_cert"))
+	}
+
+	// The AppRole and certificate auth Secrets are credentials of the webhook, in its own
+	// namespace, so objects may only select them if the operator opted in
+	if val, ok := annotations[common.VaultAppRoleSecretAnnotation]; ok && viper.GetBool("vault_allow_object_auth_secrets") {
+		vaultConfig.AppRoleSecret = val
+	} else {
+		if ok {
+			validator.warn("annotation %s is ignored, the webhook does not allow objects to select its Vault credentials", common.VaultAppRoleSecretAnnotation)
+		}
+		vaultConfig.AppRoleSecret = defaults.GetString("vault_approle_secret")
+	}
+
+	if val, ok := annotations[common.VaultAppRolePathAnnotation]; ok {
+		vaultConfig.AppRolePath = val
+	} else {
+		vaultConfig.AppRolePath = defaults.GetString("vault_approle_path")
+	}
+
+	if val, ok := annotations[common.VaultCertAuthSecretAnnotation]; ok && viper.GetBool("vault_allow_object_auth_secrets") {
+		vaultConfig.CertAuthSecret = val
+	} else {
+		if ok {
+			validator.warn("annotation %s is ignored, the webhook does not allow objects to select its Vault credentials", common.VaultCertAuthSecretAnnotation)
+		}
+		vaultConfig.CertAuthSecret = defaults.GetString("vault_cert_auth_secret")
+	}
+
+	if val, ok := annotations[common.VaultCertAuthPathAnnotation]; ok {
+		vaultConfig.CertAuthPath = val
+	} else {
+		vaultConfig.CertAuthPath = defaults.GetString("vault_cert_auth_path")
 	}
 
 	if val, ok := annotations[common.VaultClientTimeoutAnnotation]; ok {
-		vaultConfig.ClientTimeout, _ = time.ParseDuration(val)
+		vaultConfig.ClientTimeout = validator.parseDuration(common.VaultClientTimeoutAnnotation, val)
 	} else {
-		vaultConfig.ClientTimeout, _ = time.ParseDuration(viper.GetString("vault_client_timeout"))
+		vaultConfig.ClientTimeout, _ = time.ParseDuration(defaults.GetString("vault_client_timeout"))
 	}
 
 	if val, ok := annotations[common.VaultAgentAnnotation]; ok {
-		vaultConfig.UseAgent, _ = strconv.ParseBool(val)
+		vaultConfig.UseAgent = validator.parseBool(common.VaultAgentAnnotation, val)
 	} else {
-		vaultConfig.UseAgent, _ = strconv.ParseBool(viper.GetString("vault_agent"))
+		vaultConfig.UseAgent, _ = strconv.ParseBool(defaults.GetString("vault_agent"))
 	}
 
 	if val, ok := annotations[common.VaultEnvDaemonAnnotation]; ok {
-		vaultConfig.VaultEnvDaemon, _ = strconv.ParseBool(val)
+		vaultConfig.VaultEnvDaemon = validator.parseBool(common.VaultEnvDaemonAnnotation, val)
 	} else {
-		vaultConfig.VaultEnvDaemon, _ = strconv.ParseBool(viper.GetString("vault_env_daemon"))
+		vaultConfig.VaultEnvDaemon, _ = strconv.ParseBool(defaults.GetString("vault_env_daemon"))
 	}
 
 	if val, ok := annotations[common.VaultEnvDelayAnnotation]; ok {
-		vaultConfig.VaultEnvDelay, _ = time.ParseDuration(val)
+		vaultConfig.VaultEnvDelay = validator.parseDuration(common.VaultEnvDelayAnnotation, val)
 	} else {
-		vaultConfig.VaultEnvDelay, _ = time.ParseDuration(viper.GetString("vault_env_delay"))
+		vaultConfig.VaultEnvDelay, _ = time.ParseDuration(defaults.GetString("vault_env_delay"))
 	}
 
 	if val, ok := annotations[common.VaultConsulTemplateConfigmapAnnotation]; ok {
@@ -197,8 +303,8 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 
 	if val, ok := annotations[common.ServiceAccountTokenVolumeNameAnnotation]; ok {
 		vaultConfig.ServiceAccountTokenVolumeName = val
-	} else if viper.GetString("SERVICE_ACCOUNT_TOKEN_VOLUME_NAME") != "" {
-		vaultConfig.ServiceAccountTokenVolumeName = viper.GetString("SERVICE_ACCOUNT_TOKEN_VOLUME_NAME")
+	} else if defaults.GetString("SERVICE_ACCOUNT_TOKEN_VOLUME_NAME") != "" {
+		vaultConfig.ServiceAccountTokenVolumeName = defaults.GetString("SERVICE_ACCOUNT_TOKEN_VOLUME_NAME")
 	} else {
 		vaultConfig.ServiceAccountTokenVolumeName = "/var/run/secrets/kubernetes.io/serviceaccount"
 	}
@@ -206,18 +312,18 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 	if val, ok := annotations[common.VaultConsulTemplateImageAnnotation]; ok {
 		vaultConfig.CtImage = val
 	} else {
-		vaultConfig.CtImage = viper.GetString("vault_ct_image")
+		vaultConfig.CtImage = defaults.GetString("vault_ct_image")
 	}
 
 	if val, ok := annotations[common.VaultIgnoreMissingSecretsAnnotation]; ok {
 		vaultConfig.IgnoreMissingSecrets = val
 	} else {
-		vaultConfig.IgnoreMissingSecrets = viper.GetString("vault_ignore_missing_secrets")
+		vaultConfig.IgnoreMissingSecrets = defaults.GetString("vault_ignore_missing_secrets")
 	}
 	if val, ok := annotations[common.VaultEnvPassthroughAnnotation]; ok {
 		vaultConfig.VaultEnvPassThrough = val
 	} else {
-		vaultConfig.VaultEnvPassThrough = viper.GetString("vault_env_passthrough")
+		vaultConfig.VaultEnvPassThrough = defaults.GetString("vault_env_passthrough")
 	}
 	if val, ok := annotations[common.VaultConfigfilePathAnnotation]; ok {
 		vaultConfig.ConfigfilePath = val
@@ -228,95 +334,118 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 	}
 
 	if val, ok := annotations[common.VaultConsulTemplatePullPolicyAnnotation]; ok {
-		vaultConfig.CtImagePullPolicy = getPullPolicy(val)
+		vaultConfig.CtImagePullPolicy = validator.parsePullPolicy(common.VaultConsulTemplatePullPolicyAnnotation, val)
 	} else {
-		vaultConfig.CtImagePullPolicy = getPullPolicy(viper.GetString("vault_ct_pull_policy"))
+		vaultConfig.CtImagePullPolicy = getPullPolicy(defaults.GetString("vault_ct_pull_policy"))
 	}
 
 	if val, ok := annotations[common.VaultConsulTemplateOnceAnnotation]; ok {
-		vaultConfig.CtOnce, _ = strconv.ParseBool(val)
+		vaultConfig.CtOnce = validator.parseBool(common.VaultConsulTemplateOnceAnnotation, val)
 	} else {
 		vaultConfig.CtOnce = false
 	}
 
-	if val, err := resource.ParseQuantity(annotations[common.VaultConsulTemplateCPUAnnotation]); err == nil {
-		vaultConfig.CtCPU = val
-	} else {
-		vaultConfig.CtCPU = resource.MustParse("100m")
+	vaultConfig.CtCPU = defaultQuantity(defaults, "VAULT_CT_CPU_LIMIT", "100m")
+	if val, ok := annotations[common.VaultConsulTemplateCPUAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultConsulTemplateCPUAnnotation, val); ok {
+			vaultConfig.CtCPU = q
+		}
 	}
 
-	if val, err := resource.ParseQuantity(annotations[common.VaultConsulTemplateMemoryAnnotation]); err == nil {
-		vaultConfig.CtMemory = val
-	} else {
-		vaultConfig.CtMemory = resource.MustParse("128Mi")
+	vaultConfig.CtMemory = defaultQuantity(defaults, "VAULT_CT_MEMORY_LIMIT", "128Mi")
+	if val, ok := annotations[common.VaultConsulTemplateMemoryAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultConsulTemplateMemoryAnnotation, val); ok {
+			vaultConfig.CtMemory = q
+		}
 	}
 
 	if val, ok := annotations[common.VaultConsulTemplateShareProcessNamespaceAnnotation]; ok {
 		vaultConfig.CtShareProcessDefault = "found"
-		vaultConfig.CtShareProcess, _ = strconv.ParseBool(val)
+		vaultConfig.CtShareProcess = validator.parseBool(common.VaultConsulTemplateShareProcessNamespaceAnnotation, val)
 	} else {
 		vaultConfig.CtShareProcessDefault = "empty"
 		vaultConfig.CtShareProcess = false
 	}
 
 	if val, ok := annotations[common.PSPAllowPrivilegeEscalationAnnotation]; ok {
-		vaultConfig.PspAllowPrivilegeEscalation, _ = strconv.ParseBool(val)
+		vaultConfig.PspAllowPrivilegeEscalation = validator.parseBool(common.PSPAllowPrivilegeEscalationAnnotation, val)
 	} else {
-		vaultConfig.PspAllowPrivilegeEscalation, _ = strconv.ParseBool(viper.GetString("psp_allow_privilege_escalation"))
+		vaultConfig.PspAllowPrivilegeEscalation, _ = strconv.ParseBool(defaults.GetString("psp_allow_privilege_escalation"))
 	}
 
 	if val, ok := annotations[common.RunAsNonRootAnnotation]; ok {
-		vaultConfig.RunAsNonRoot, _ = strconv.ParseBool(val)
+		vaultConfig.RunAsNonRoot = validator.parseBool(common.RunAsNonRootAnnotation, val)
 	} else {
-		vaultConfig.RunAsNonRoot, _ = strconv.ParseBool(viper.GetString("run_as_non_root"))
+		vaultConfig.RunAsNonRoot, _ = strconv.ParseBool(defaults.GetString("run_as_non_root"))
 	}
 
 	if val, ok := annotations[common.RunAsUserAnnotation]; ok {
-		vaultConfig.RunAsUser, _ = strconv.ParseInt(val, 10, 64)
+		vaultConfig.RunAsUser = validator.parseInt(common.RunAsUserAnnotation, val, 64)
 	} else {
-		vaultConfig.RunAsUser, _ = strconv.ParseInt(viper.GetString("run_as_user"), 0, 64)
+		vaultConfig.RunAsUser, _ = strconv.ParseInt(defaults.GetString("run_as_user"), 0, 64)
 	}
 
 	if val, ok := annotations[common.RunAsGroupAnnotation]; ok {
-		vaultConfig.RunAsGroup, _ = strconv.ParseInt(val, 10, 64)
+		vaultConfig.RunAsGroup = validator.parseInt(common.RunAsGroupAnnotation, val, 64)
 	} else {
-		vaultConfig.RunAsGroup, _ = strconv.ParseInt(viper.GetString("run_as_group"), 0, 64)
+		vaultConfig.RunAsGroup, _ = strconv.ParseInt(defaults.GetString("run_as_group"), 0, 64)
 	}
 
 	if val, ok := annotations[common.ReadOnlyRootFsAnnotation]; ok {
-		vaultConfig.ReadOnlyRootFilesystem, _ = strconv.ParseBool(val)
+		vaultConfig.ReadOnlyRootFilesystem = validator.parseBool(common.ReadOnlyRootFsAnnotation, val)
 	} else {
-		vaultConfig.ReadOnlyRootFilesystem, _ = strconv.ParseBool(viper.GetString("readonly_root_fs"))
+		vaultConfig.ReadOnlyRootFilesystem, _ = strconv.ParseBool(defaults.GetString("readonly_root_fs"))
 	}
 
 	if val, ok := annotations[common.RegistrySkipVerifyAnnotation]; ok {
-		vaultConfig.RegistrySkipVerify, _ = strconv.ParseBool(val)
+		vaultConfig.RegistrySkipVerify = validator.parseBool(common.RegistrySkipVerifyAnnotation, val)
 	} else {
-		vaultConfig.RegistrySkipVerify, _ = strconv.ParseBool(viper.GetString("registry_skip_verify"))
+		vaultConfig.RegistrySkipVerify, _ = strconv.ParseBool(defaults.GetString("registry_skip_verify"))
+	}
+
+	// Containers are named by the object, so their entrypoints are never namespace defaults
+	for _, key := range slices.Sorted(maps.Keys(obj.GetAnnotations())) {
+		containerName, ok := strings.CutPrefix(key, common.ContainerEntrypointAnnotationPrefix)
+		if !ok {
+			continue
+		}
+
+		val := obj.GetAnnotations()[key]
+		entrypoint, err := parseImageEntrypoint(val)
+		if err != nil {
+			validator.add(key, val, err)
+
+			continue
+		}
+
+		if vaultConfig.ContainerEntrypoints == nil {
+			vaultConfig.ContainerEntrypoints = map[string]imageEntrypoint{}
+		}
+		vaultConfig.ContainerEntrypoints[containerName] = entrypoint
 	}
 
 	if val, ok := annotations[common.LogLevelAnnotation]; ok {
 		vaultConfig.LogLevel = val
 	} else {
-		vaultConfig.LogLevel = viper.GetString("log_level")
+		vaultConfig.LogLevel = defaults.GetString("log_level")
 	}
 
 	if val, ok := annotations[common.EnableJSONLogAnnotation]; ok {
 		vaultConfig.EnableJSONLog = val
 	} else {
-		vaultConfig.EnableJSONLog = viper.GetString("enable_json_log")
+		vaultConfig.EnableJSONLog = defaults.GetString("enable_json_log")
 	}
 
 	if val, ok := annotations[common.TransitKeyIDAnnotation]; ok {
 		vaultConfig.TransitKeyID = val
 	} else {
-		vaultConfig.TransitKeyID = viper.GetString("transit_key_id")
+		vaultConfig.TransitKeyID = defaults.GetString("transit_key_id")
 	}
 
 	if val, ok := annotations[common.TransitPathAnnotation]; ok {
 		vaultConfig.TransitPath = val
 	} else {
-		vaultConfig.TransitPath = viper.GetString("transit_path")
+		vaultConfig.TransitPath = defaults.GetString("transit_path")
 	}
 
 	if val, ok := annotations[common.VaultAgentConfigmapAnnotation]; ok {
@@ -326,44 +455,56 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 	}
 
 	if val, ok := annotations[common.VaultAgentOnceAnnotation]; ok {
-		vaultConfig.AgentOnce, _ = strconv.ParseBool(val)
+		vaultConfig.AgentOnce = validator.parseBool(common.VaultAgentOnceAnnotation, val)
 	} else {
 		vaultConfig.AgentOnce = false
 	}
 
-	// This is done to preserve backwards compatibility with vault-agent-cpu
-	if val, err := resource.ParseQuantity(annotations[common.VaultAgentCPUAnnotation]); err == nil {
-		vaultConfig.AgentCPULimit = val
-	} else if val, err := resource.ParseQuantity(annotations[common.VaultAgentCPULimitAnnotation]); err == nil {
-		vaultConfig.AgentCPULimit = val
-	} else {
-		vaultConfig.AgentCPULimit = resource.MustParse("100m")
+	// This is done to preserve backwards compatibility with vault-agent-cpu,
+	// vault-agent-cpu-limit takes precedence over it
+	vaultConfig.AgentCPULimit = defaultQuantity(defaults, "VAULT_AGENT_CPU_LIMIT", "100m")
+	if val, ok := annotations[common.VaultAgentCPUAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultAgentCPUAnnotation, val); ok {
+			vaultConfig.AgentCPULimit = q
+		}
+	}
+	if val, ok := annotations[common.VaultAgentCPULimitAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultAgentCPULimitAnnotation, val); ok {
+			vaultConfig.AgentCPULimit = q
+		}
 	}
 
-	// This is done to preserve backwards compatibility with vault-agent-memory
-	if val, err := resource.ParseQuantity(annotations[common.VaultAgentMemoryAnnotation]); err == nil {
-		vaultConfig.AgentMemoryLimit = val
-	} else if val, err := resource.ParseQuantity(annotations[common.VaultAgentMemoryLimitAnnotation]); err == nil {
-		vaultConfig.AgentMemoryLimit = val
-	} else {
-		vaultConfig.AgentMemoryLimit = resource.MustParse("128Mi")
+	// This is done to preserve backwards compatibility with vault-agent-memory,
+	// vault-agent-memory-limit takes precedence over it
+	vaultConfig.AgentMemoryLimit = defaultQuantity(defaults, "VAULT_AGENT_MEMORY_LIMIT", "128Mi")
+	if val, ok := annotations[common.VaultAgentMemoryAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultAgentMemoryAnnotation, val); ok {
+			vaultConfig.AgentMemoryLimit = q
+		}
+	}
+	if val, ok := annotations[common.VaultAgentMemoryLimitAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultAgentMemoryLimitAnnotation, val); ok {
+			vaultConfig.AgentMemoryLimit = q
+		}
 	}
 
-	if val, err := resource.ParseQuantity(annotations[common.VaultAgentCPURequestAnnotation]); err == nil {
-		vaultConfig.AgentCPURequest = val
-	} else {
-		vaultConfig.AgentCPURequest = resource.MustParse("100m")
+	vaultConfig.AgentCPURequest = defaultQuantity(defaults, "VAULT_AGENT_CPU_REQUEST", "100m")
+	if val, ok := annotations[common.VaultAgentCPURequestAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultAgentCPURequestAnnotation, val); ok {
+			vaultConfig.AgentCPURequest = q
+		}
 	}
 
-	if val, err := resource.ParseQuantity(annotations[common.VaultAgentMemoryRequestAnnotation]); err == nil {
-		vaultConfig.AgentMemoryRequest = val
-	} else {
-		vaultConfig.AgentMemoryRequest = resource.MustParse("128Mi")
+	vaultConfig.AgentMemoryRequest = defaultQuantity(defaults, "VAULT_AGENT_MEMORY_REQUEST", "128Mi")
+	if val, ok := annotations[common.VaultAgentMemoryRequestAnnotation]; ok {
+		if q, ok := validator.parseQuantity(common.VaultAgentMemoryRequestAnnotation, val); ok {
+			vaultConfig.AgentMemoryRequest = q
+		}
 	}
 
 	if val, ok := annotations[common.VaultAgentShareProcessNamespaceAnnotation]; ok {
 		vaultConfig.AgentShareProcessDefault = "found"
-		vaultConfig.AgentShareProcess, _ = strconv.ParseBool(val)
+		vaultConfig.AgentShareProcess = validator.parseBool(common.VaultAgentShareProcessNamespaceAnnotation, val)
 	} else {
 		vaultConfig.AgentShareProcessDefault = "empty"
 		vaultConfig.AgentShareProcess = false
@@ -374,92 +515,120 @@ func parseVaultConfig(obj metav1.Object, ar *model.AdmissionReview) (VaultConfig
 	}
 
 	if val, ok := annotations[common.TokenAuthMountAnnotation]; ok {
-		vaultConfig.TokenAuthMount = val
+		if validator.validateTokenAuthMount(common.TokenAuthMountAnnotation, val) {
+			vaultConfig.TokenAuthMount = val
+		}
 	}
 
 	if val, ok := annotations[common.VaultEnvImageAnnotation]; ok {
 		vaultConfig.EnvImage = val
 	} else {
-		vaultConfig.EnvImage = viper.GetString("vault_env_image")
+		vaultConfig.EnvImage = defaults.GetString("vault_env_image")
 	}
 
-	vaultConfig.EnvLogServer = viper.GetString("VAULT_ENV_LOG_SERVER")
+	vaultConfig.EnvLogServer = defaults.GetString("VAULT_ENV_LOG_SERVER")
 
 	if val, ok := annotations[common.VaultEnvImagePullPolicyAnnotation]; ok {
-		vaultConfig.EnvImagePullPolicy = getPullPolicy(val)
+		vaultConfig.EnvImagePullPolicy = validator.parsePullPolicy(common.VaultEnvImagePullPolicyAnnotation, val)
 	} else {
-		vaultConfig.EnvImagePullPolicy = getPullPolicy(viper.GetString("vault_env_pull_policy"))
+		vaultConfig.EnvImagePullPolicy = getPullPolicy(defaults.GetString("vault_env_pull_policy"))
 	}
 
 	if val, ok := annotations[common.VaultImageAnnotation]; ok {
 		vaultConfig.AgentImage = val
 	} else {
-		vaultConfig.AgentImage = viper.GetString("vault_image")
+		vaultConfig.AgentImage = defaults.GetString("vault_image")
 	}
 	if val, ok := annotations[common.VaultImagePullPolicyAnnotation]; ok {
-		vaultConfig.AgentImagePullPolicy = getPullPolicy(val)
+		vaultConfig.AgentImagePullPolicy = validator.parsePullPolicy(common.VaultImagePullPolicyAnnotation, val)
 	} else {
-		vaultConfig.AgentImagePullPolicy = getPullPolicy(viper.GetString("vault_image_pull_policy"))
+		vaultConfig.AgentImagePullPolicy = getPullPolicy(defaults.GetString("vault_image_pull_policy"))
 	}
 
 	if val, ok := annotations[common.VaultAgentEnvVariablesAnnotation]; ok {
+		validator.validateEnvVariables(common.VaultAgentEnvVariablesAnnotation, val)
 		vaultConfig.AgentEnvVariables = val
 	}
 
 	if val, ok := annotations[common.VaultNamespaceAnnotation]; ok {
 		vaultConfig.VaultNamespace = val
 	} else {
-		vaultConfig.VaultNamespace = viper.GetString("VAULT_NAMESPACE")
+		vaultConfig.VaultNamespace = defaults.GetString("VAULT_NAMESPACE")
 	}
 
 	if val, ok := annotations[common.VaultConsuleTemplateInjectInInitcontainersAnnotation]; ok {
-		vaultConfig.CtInjectInInitcontainers, _ = strconv.ParseBool(val)
+		vaultConfig.CtInjectInInitcontainers = validator.parseBool(common.VaultConsuleTemplateInjectInInitcontainersAnnotation, val)
 	} else {
 		vaultConfig.CtInjectInInitcontainers = false
 	}
 
-	if val, err := resource.ParseQuantity(viper.GetString("VAULT_ENV_CPU_REQUEST")); err == nil {
+	if val, err := resource.ParseQuantity(defaults.GetString("VAULT_ENV_CPU_REQUEST")); err == nil {
 		vaultConfig.EnvCPURequest = val
 	} else {
 		vaultConfig.EnvCPURequest = resource.MustParse("100m")
 	}
 
-	if val, err := resource.ParseQuantity(viper.GetString("VAULT_ENV_MEMORY_REQUEST")); err == nil {
+	if val, err := resource.ParseQuantity(defaults.GetString("VAULT_ENV_MEMORY_REQUEST")); err == nil {
 		vaultConfig.EnvMemoryRequest = val
 	} else {
 		vaultConfig.EnvMemoryRequest = resource.MustParse("256Mi")
 	}
 
-	if val, err := resource.ParseQuantity(viper.GetString("VAULT_ENV_CPU_LIMIT")); err == nil {
+	if val, err := resource.ParseQuantity(defaults.GetString("VAULT_ENV_CPU_LIMIT")); err == nil {
 		vaultConfig.EnvCPULimit = val
 	} else {
 		vaultConfig.EnvCPULimit = resource.MustParse("500m")
 	}
 
-	if val, err := resource.ParseQuantity(viper.GetString("VAULT_ENV_MEMORY_LIMIT")); err == nil {
+	if val, err := resource.ParseQuantity(defaults.GetString("VAULT_ENV_MEMORY_LIMIT")); err == nil {
 		vaultConfig.EnvMemoryLimit = val
 	} else {
 		vaultConfig.EnvMemoryLimit = resource.MustParse("256Mi")
 	}
 
 	if val, ok := annotations[common.MutateProbesAnnotation]; ok {
-		vaultConfig.MutateProbes, _ = strconv.ParseBool(val)
+		vaultConfig.MutateProbes = validator.parseBool(common.MutateProbesAnnotation, val)
 	} else {
 		vaultConfig.MutateProbes = false
 	}
 
+	if val, ok := annotations[common.NativeSidecarsAnnotation]; ok {
+		vaultConfig.NativeSidecars = validator.parseBool(common.NativeSidecarsAnnotation, val)
+	} else {
+		vaultConfig.NativeSidecars, _ = strconv.ParseBool(defaults.GetString("native_sidecars"))
+	}
+
+	if val, ok := annotations[common.NativeSidecarsStartupProbeAnnotation]; ok {
+		vaultConfig.NativeSidecarsStartupProbe = validator.parseBool(common.NativeSidecarsStartupProbeAnnotation, val)
+	} else {
+		vaultConfig.NativeSidecarsStartupProbe, _ = strconv.ParseBool(defaults.GetString("native_sidecars_startup_probe"))
+	}
+
 	if val, ok := annotations[common.TransitBatchSizeAnnotation]; ok {
-		batchSize, _ := strconv.ParseInt(val, 10, 32)
-		vaultConfig.TransitBatchSize = int(batchSize)
+		vaultConfig.TransitBatchSize = int(validator.parseInt(common.TransitBatchSizeAnnotation, val, 32))
 	} else {
-		vaultConfig.TransitBatchSize = viper.GetInt("transit_batch_size")
+		vaultConfig.TransitBatchSize = defaults.GetInt("transit_batch_size")
 	}
 
-	vaultConfig.Token = viper.GetString("vault_token")
+	vaultConfig.Token = defaults.GetString("vault_token")
+	vaultConfig.TokenSecret = defaults.GetString("vault_token_secret")
+	vaultConfig.TokenSecretKey = defaults.GetString("vault_token_secret_key")
+	vaultConfig.TokenSecretCreate, _ = strconv.ParseBool(defaults.GetString("vault_token_secret_create"))
+	vaultConfig.TokenAsFile, _ = strconv.ParseBool(defaults.GetString("vault_token_as_file"))
 
 	return vaultConfig, nil
 }
 
+// defaultQuantity returns the quantity of the given setting, or the fallback
+// if the setting is empty or malformed.
+func defaultQuantity(defaults configDefaults, key string, fallback string) resource.Quantity {
+	if val, err := resource.ParseQuantity(defaults.GetString(key)); err == nil {
+		return val
+	}
+
+	return resource.MustParse(fallback)
+}
+
 func getPullPolicy(pullPolicyStr string) corev1.PullPolicy {
 	switch pullPolicyStr {
 	case "Never", "never":
@@ -484,12 +653,45 @@ func SetConfigDefaults() {
 	viper.SetDefault("vault_skip_verify", "false")
 	viper.SetDefault("vault_addr_allowlist", "")
 	viper.SetDefault("vault_allow_object_skip_verify", "false")
+	viper.SetDefault("vault_allow_object_auth_secrets", "false")
 	viper.SetDefault("vault_allow_private_addr", "false")
+	viper.SetDefault("annotation_validation", AnnotationValidationLenient)
+	viper.SetDefault("annotation_validation_strict_namespaces", "")
+	viper.SetDefault("enable_injection_policies", "false")
+	viper.SetDefault("enable_namespace_defaults", "false")
+	viper.SetDefault("enable_namespace_failure_policies", "false")
+	viper.SetDefault("mutation_failure_policy", MutationFailurePolicyFail)
+	viper.SetDefault("enable_access_policies", "false")
+	viper.SetDefault("enable_events", "false")
+	viper.SetDefault("events_burst", 25)
+	viper.SetDefault("events_interval", "5m")
+	viper.SetDefault("enable_secret_rollouts", "false")
+	viper.SetDefault("secret_rollouts_interval", "1m")
+	viper.SetDefault("secret_rollouts_lease", "vault-secrets-webhook-secret-rollouts")
+	viper.SetDefault("enable_secret_version_annotations", "false")
+	viper.SetDefault("signing_key_secret", "vault-secrets-webhook-signing-key")
+	viper.SetDefault("enable_secret_resync", "false")
+	viper.SetDefault("secret_resync_interval", "5m")
+	viper.SetDefault("secret_resync_lease", "vault-secrets-webhook-secret-resync")
+	viper.SetDefault("enable_dynamic_secrets", "false")
+	viper.SetDefault("dynamic_secrets_interval", "1m")
+	viper.SetDefault("dynamic_secrets_lease", "vault-secrets-webhook-dynamic-secrets")
+	viper.SetDefault("dynamic_secrets_token_role", "vault-secrets-webhook-dynamic-secrets")
 	viper.SetDefault("vault_path", "kubernetes")
 	viper.SetDefault("vault_auth_method", "jwt")
 	viper.SetDefault("vault_role", "")
 	viper.SetDefault("vault_tls_secret", "")
+	viper.SetDefault("vault_tls_client_cert", "false")
+	viper.SetDefault("vault_approle_secret", "")
+	viper.SetDefault("vault_approle_path", "approle")
+	viper.SetDefault("vault_cert_auth_secret", "")
+	viper.SetDefault("vault_cert_auth_path", "cert")
+	viper.SetDefault("vault_token_secret", "")
+	viper.SetDefault("vault_token_secret_key", "token")
+	viper.SetDefault("vault_token_secret_create", "false")
+	viper.SetDefault("vault_token_as_file", "false")
 	viper.SetDefault("vault_client_timeout", "10s")
+	viper.SetDefault("vault_client_cache_max_ttl", "5m")
 	viper.SetDefault("vault_agent", "false")
 	viper.SetDefault("vault_env_daemon", "false")
 	viper.SetDefault("vault_ct_share_process_namespace", "")
@@ -512,13 +714,26 @@ func SetConfigDefaults() {
 	viper.SetDefault("default_image_pull_secret_service_account", "")
 	viper.SetDefault("default_image_pull_secret_namespace", "")
 	viper.SetDefault("registry_skip_verify", "false")
+	viper.SetDefault("default_image_platform", "")
+	viper.SetDefault("image_cache_size", 1000)
+	viper.SetDefault("image_cache_ttl", "1h")
+	viper.SetDefault("image_entrypoints", "")
+	viper.SetDefault("registry_mirrors", "")
 	viper.SetDefault("enable_json_log", "false")
 	viper.SetDefault("log_level", "info")
 	viper.SetDefault("vault_agent_share_process_namespace", "")
+	viper.SetDefault("native_sidecars", "false")
+	viper.SetDefault("native_sidecars_startup_probe", "true")
 	viper.SetDefault("VAULT_ENV_CPU_REQUEST", "")
 	viper.SetDefault("VAULT_ENV_MEMORY_REQUEST", "")
 	viper.SetDefault("VAULT_ENV_CPU_LIMIT", "")
 	viper.SetDefault("VAULT_ENV_MEMORY_LIMIT", "")
+	viper.SetDefault("VAULT_AGENT_CPU_REQUEST", "")
+	viper.SetDefault("VAULT_AGENT_MEMORY_REQUEST", "")
+	viper.SetDefault("VAULT_AGENT_CPU_LIMIT", "")
+	viper.SetDefault("VAULT_AGENT_MEMORY_LIMIT", "")
+	viper.SetDefault("VAULT_CT_CPU_LIMIT", "")
+	viper.SetDefault("VAULT_CT_MEMORY_LIMIT", "")
 	viper.SetDefault("VAULT_ENV_LOG_SERVER", "")
 	viper.SetDefault("VAULT_NAMESPACE", "")
 
@@ -17,9 +17,11 @@ package webhook
 import (
 	"context"
 	"encoding/base64"
+	"maps"
 
 	"emperror.dev/errors"
 	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
@@ -43,24 +45,32 @@ func configMapNeedsMutation(configMap *corev1.ConfigMap) bool {
 func (mw *MutatingWebhook) MutateConfigMap(ctx context.Context, configMap *corev1.ConfigMap, vaultConfig VaultConfig) error {
 	// do an early exit and don't construct the Vault client if not needed
 	if !configMapNeedsMutation(configMap) {
+		mw.forgetSecretTemplates(ctx, configMap, configMapResolvedData(configMap))
+
 		return nil
 	}
 
-	vaultClient, err := mw.newVaultClient(ctx, vaultConfig)
-	if err != nil {
-		return errors.Wrap(err, "failed to create vault client")
+	references := resolvedReferences(configMap)
+
+	if viper.GetBool("enable_secret_resync") {
+		if err := mw.recordSecretTemplates(ctx, configMap, vaultConfig.ObjectNamespace, configMapDataTemplates(configMap)); err != nil {
+			return err
+		}
 	}
 
-	defer vaultClient.Close()
+	var versions map[string]string
+	if recordsSecretVersions() {
+		versions = mw.resolvedSecretVersions(ctx, configMap, references, vaultConfig)
+	}
 
-	config := injector.Config{
-		TransitKeyID:     vaultConfig.TransitKeyID,
-		TransitPath:      vaultConfig.TransitPath,
-		TransitBatchSize: vaultConfig.TransitBatchSize,
+	secretResolver, release, err := mw.secretResolverFor(ctx, vaultConfig)
+	if err != nil {
+		return err
 	}
-	secretInjector := injector.NewSecretInjector(config, vaultClient, nil, logger)
 
-	configMap.Data, err = secretInjector.GetDataFromVaultWithContext(ctx, configMap.Data)
+	defer release()
+
+	configMap.Data, err = secretResolver.GetDataFromVaultWithContext(ctx, configMap.Data)
 	if err != nil {
 		return err
 	}
@@ -70,18 +80,34 @@ func (mw *MutatingWebhook) MutateConfigMap(ctx context.Context, configMap *corev
 			binaryData := map[string]string{
 				key: string(value),
 			}
-			err := mw.mutateConfigMapBinaryData(ctx, configMap, binaryData, &secretInjector)
+			err := mw.mutateConfigMapBinaryData(ctx, configMap, binaryData, secretResolver)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	if recordsSecretVersions() {
+		mw.annotateResolvedSecrets(ctx, configMap, references, versions, configMapResolvedData(configMap))
+	}
+
 	return nil
 }
 
-func (mw *MutatingWebhook) mutateConfigMapBinaryData(ctx context.Context, configMap *corev1.ConfigMap, data map[string]string, secretInjector *injector.SecretInjector) error {
-	mapData, err := secretInjector.GetDataFromVaultWithContext(ctx, data)
+// configMapResolvedData returns the Data and BinaryData of a ConfigMap, as hashed
+// in its secrets hash annotation.
+func configMapResolvedData(configMap *corev1.ConfigMap) map[string][]byte {
+	data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for key, value := range configMap.Data {
+		data[key] = []byte(value)
+	}
+	maps.Copy(data, configMap.BinaryData)
+
+	return data
+}
+
+func (mw *MutatingWebhook) mutateConfigMapBinaryData(ctx context.Context, configMap *corev1.ConfigMap, data map[string]string, secretResolver SecretResolver) error {
+	mapData, err := secretResolver.GetDataFromVaultWithContext(ctx, data)
 	if err != nil {
 		return err
 	}
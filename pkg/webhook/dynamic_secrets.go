@@ -0,0 +1,433 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	"github.com/bank-vaults/vault-sdk/vault"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// leaseRecorder is handed the dynamic secrets read while resolving the values of a
+// Secret. Instead of renewing their leases in the background of the admission request,
+// it records them so that they are tracked on the Secret.
+type leaseRecorder struct {
+	mu     sync.Mutex
+	leases map[string]time.Duration
+}
+
+func (r *leaseRecorder) Renew(_ string, secret *vaultapi.Secret) error {
+	// KV version 1 secrets have a lease duration, but no lease
+	if secret.LeaseID == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.leases == nil {
+		r.leases = map[string]time.Duration{}
+	}
+	r.leases[secret.LeaseID] = time.Duration(secret.LeaseDuration) * time.Second
+
+	return nil
+}
+
+// leaseToken is the Vault token owning the leases of the dynamic secrets of a Secret,
+// identified by its accessor. The client reading with it is only set at admission time.
+type leaseToken struct {
+	accessor string
+	ttl      time.Duration
+	client   *vault.Client
+}
+
+// revoke revokes the token read with at admission time, and so the leases it owns.
+func (t leaseToken) revoke(ctx context.Context) error {
+	if t.client == nil {
+		return nil
+	}
+
+	return errors.Wrap(t.client.RawClient().Auth().Token().RevokeSelfWithContext(ctx, ""), "failed to revoke the lease token")
+}
+
+func (t leaseToken) close() {
+	if t.client != nil {
+		t.client.Close()
+	}
+}
+
+// leaseTokenSecretResolver returns a SecretResolver reading the secrets of a Secret with
+// a new token created through the dynamic_secrets_token_role token role, and handing the
+// ones read with a lease to the renewer. The leases are owned by this token instead of
+// the login token of the webhook, which nothing renews after the admission request, so
+// the token role must create periodic orphan tokens: they are renewed along with the
+// leases, and revoked with them once the Secret is deleted.
+func (mw *MutatingWebhook) leaseTokenSecretResolver(ctx context.Context, vaultConfig VaultConfig, secret *corev1.Secret, renewer injector.SecretRenewer) (SecretResolver, leaseToken, error) {
+	if mw.secretResolver != nil {
+		return mw.secretResolver, leaseToken{}, nil
+	}
+
+	loginClient, release, err := mw.vaultClientFor(ctx, vaultConfig)
+	if err != nil {
+		return nil, leaseToken{}, withReason(ReasonVaultAuthFailed, errors.Wrap(err, "failed to create vault client"))
+	}
+	defer release()
+
+	role := viper.GetString("dynamic_secrets_token_role")
+	created, err := loginClient.RawClient().Auth().Token().CreateWithRoleWithContext(ctx, &vaultapi.TokenCreateRequest{
+		Metadata: map[string]string{
+			"namespace": vaultConfig.ObjectNamespace,
+			"secret":    cmp.Or(secret.Name, secret.GenerateName),
+		},
+	}, role)
+	if err != nil {
+		return nil, leaseToken{}, withReason(ReasonVaultAuthFailed, errors.Wrapf(err, "failed to create a token with token role %s", role))
+	}
+	if created == nil || created.Auth == nil || created.Auth.ClientToken == "" {
+		return nil, leaseToken{}, withReason(ReasonVaultAuthFailed, errors.Errorf("no token returned by token role %s", role))
+	}
+
+	rawClient, err := loginClient.RawClient().Clone()
+	if err != nil {
+		return nil, leaseToken{}, errors.Wrap(err, "failed to clone vault client")
+	}
+	tokenClient, err := vault.NewClientFromRawClientWithContext(
+		ctx,
+		rawClient,
+		vault.ClientToken(created.Auth.ClientToken),
+		vault.ClientLogger(&clientLogger{logger: mw.logger}),
+		vault.VaultNamespace(vaultConfig.VaultNamespace),
+	)
+	if err != nil {
+		return nil, leaseToken{}, errors.Wrap(err, "failed to create vault client")
+	}
+
+	token := leaseToken{
+		accessor: created.Auth.Accessor,
+		ttl:      time.Duration(created.Auth.LeaseDuration) * time.Second,
+		client:   tokenClient,
+	}
+
+	return newVaultSecretResolver(vaultConfig, tokenClient, renewer), token, nil
+}
+
+// recordLeases records the leases of the dynamic secrets of a Secret, the accessor of the
+// token owning them and their shortest TTL in its annotations, along with the accessors of
+// the tokens it held before that are superseded by it, then labels it and adds the
+// finalizer revoking them. The annotations are signed for the namespace of the Secret,
+// as only the leases the webhook read for it may be renewed and revoked.
+func (mw *MutatingWebhook) recordLeases(ctx context.Context, secret *corev1.Secret, namespace string, token leaseToken, leases map[string]time.Duration, superseded []string, now time.Time) error {
+	if len(leases) == 0 && len(superseded) == 0 {
+		return nil
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+
+	if len(leases) > 0 {
+		ttl := slices.Min(slices.Collect(maps.Values(leases)))
+		if token.ttl > 0 {
+			ttl = min(ttl, token.ttl)
+		}
+
+		secret.Annotations[common.VaultLeaseIDsAnnotation] = strings.Join(slices.Sorted(maps.Keys(leases)), ",")
+		secret.Annotations[common.VaultLeaseTokenAccessorAnnotation] = token.accessor
+		secret.Annotations[common.VaultLeaseTTLAnnotation] = ttl.String()
+		secret.Annotations[common.VaultLeaseRenewedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	} else {
+		delete(secret.Annotations, common.VaultLeaseIDsAnnotation)
+		delete(secret.Annotations, common.VaultLeaseTokenAccessorAnnotation)
+		delete(secret.Annotations, common.VaultLeaseTTLAnnotation)
+		delete(secret.Annotations, common.VaultLeaseRenewedAtAnnotation)
+	}
+
+	if len(superseded) > 0 {
+		secret.Annotations[common.VaultSupersededLeaseTokensAnnotation] = strings.Join(slices.Sorted(slices.Values(superseded)), ",")
+	} else {
+		delete(secret.Annotations, common.VaultSupersededLeaseTokensAnnotation)
+	}
+
+	if err := mw.signLeases(ctx, secret, namespace); err != nil {
+		return err
+	}
+
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[common.VaultLeasesLabel] = "true"
+
+	controllerutil.AddFinalizer(secret, common.VaultLeasesFinalizer)
+
+	return nil
+}
+
+// recordSecretLeases records the leases of the dynamic secrets read while mutating a Secret
+// and the token owning them. The token the Secret held before, if the webhook signed it, is
+// superseded by it.
+func (mw *MutatingWebhook) recordSecretLeases(ctx context.Context, secret *corev1.Secret, namespace string, token leaseToken, leases map[string]time.Duration) error {
+	previous, signed, err := mw.signedLeases(ctx, secret, namespace)
+	if err != nil {
+		return err
+	}
+
+	var superseded []string
+	if signed {
+		superseded = previous.superseded
+		if previous.token != "" && previous.token != token.accessor {
+			superseded = append(superseded, previous.token)
+		}
+	}
+
+	return mw.recordLeases(ctx, secret, namespace, token, leases, superseded, time.Now())
+}
+
+// recordedLeases are the leases of a Secret, as recorded in its annotations.
+type recordedLeases struct {
+	leaseIDs []string
+	// token is the accessor of the token owning the leases
+	token string
+	// superseded are the accessors of the tokens the Secret held before
+	superseded []string
+}
+
+// signLeases signs the lease annotations of a Secret in the given namespace.
+func (mw *MutatingWebhook) signLeases(ctx context.Context, secret *corev1.Secret, namespace string) error {
+	signature, err := mw.leasesSignature(ctx, secret, namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign lease IDs")
+	}
+
+	secret.Annotations[common.VaultLeaseIDsSignatureAnnotation] = signature
+
+	return nil
+}
+
+// signedLeases returns the leases recorded on a Secret in the given namespace, and
+// whether they are signed by the webhook.
+func (mw *MutatingWebhook) signedLeases(ctx context.Context, secret *corev1.Secret, namespace string) (recordedLeases, bool, error) {
+	signature, err := mw.leasesSignature(ctx, secret, namespace)
+	if err != nil {
+		return recordedLeases{}, false, err
+	}
+
+	leases := recordedLeases{
+		leaseIDs:   common.SplitAndTrim(secret.Annotations[common.VaultLeaseIDsAnnotation]),
+		token:      secret.Annotations[common.VaultLeaseTokenAccessorAnnotation],
+		superseded: common.SplitAndTrim(secret.Annotations[common.VaultSupersededLeaseTokensAnnotation]),
+	}
+
+	return leases, hmac.Equal([]byte(signature), []byte(secret.Annotations[common.VaultLeaseIDsSignatureAnnotation])), nil
+}
+
+func (mw *MutatingWebhook) leasesSignature(ctx context.Context, secret *corev1.Secret, namespace string) (string, error) {
+	return mw.sign(ctx,
+		[]byte("Secret"),
+		[]byte(namespace),
+		[]byte(secret.Annotations[common.VaultLeaseIDsAnnotation]),
+		[]byte(secret.Annotations[common.VaultLeaseTokenAccessorAnnotation]),
+		[]byte(secret.Annotations[common.VaultSupersededLeaseTokensAnnotation]),
+	)
+}
+
+// StartLeaseRenewal starts watching the Secrets holding Vault leases and blocks until
+// the cache is synced. Once it returns, the leases are checked every dynamic_secrets_interval
+// until the context is done: they are renewed once half of their TTL passed, and revoked
+// when their Secret is deleted, which is held back by a finalizer until then. Only the
+// replica of the webhook holding the dynamic_secrets_lease Lease renews and revokes them.
+func (mw *MutatingWebhook) StartLeaseRenewal(ctx context.Context) error {
+	interval, err := time.ParseDuration(viper.GetString("dynamic_secrets_interval"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(mw.k8sClient, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = common.VaultLeasesLabel + "=true"
+		}),
+	)
+	secretInformer := factory.Core().V1().Secrets().Informer()
+
+	factory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), secretInformer.HasSynced) {
+		return errors.New("failed to sync lease cache")
+	}
+
+	return mw.runAsLeader(ctx, viper.GetString("dynamic_secrets_lease"), func(ctx context.Context) {
+		wait.UntilWithContext(ctx, func(ctx context.Context) {
+			for _, item := range secretInformer.GetStore().List() {
+				secret, ok := item.(*corev1.Secret)
+				if !ok {
+					continue
+				}
+
+				if err := mw.renewLeases(ctx, secret.DeepCopy(), time.Now()); err != nil {
+					mw.logger.Warn("failed to renew Vault leases", slog.String("namespace", secret.Namespace), slog.String("name", secret.Name), slog.Any("error", err))
+				}
+			}
+		}, interval)
+	})
+}
+
+// renewLeases renews the token owning the leases of a Secret and the leases if half of
+// their TTL passed since they were last renewed, or revokes the token, and so the leases,
+// and removes its finalizer if the Secret is being deleted. Superseded tokens are revoked
+// right away. The tokens and leases are renewed and revoked with the Vault role of the
+// Secret, and only if they are signed by the webhook, so that the leases of other Secrets
+// cannot be listed.
+func (mw *MutatingWebhook) renewLeases(ctx context.Context, secret *corev1.Secret, now time.Time) error {
+	deleted := secret.DeletionTimestamp != nil
+	if deleted && !controllerutil.ContainsFinalizer(secret, common.VaultLeasesFinalizer) {
+		return nil
+	}
+
+	recorded, signed, err := mw.signedLeases(ctx, secret, secret.Namespace)
+	if err != nil {
+		return err
+	}
+	if !signed {
+		if !deleted {
+			return errors.New("the lease IDs of the Secret are not signed by the webhook")
+		}
+
+		// The deletion of the Secret is not held back for leases the webhook did not record
+		mw.logger.Warn("not revoking Vault leases not signed by the webhook", slog.String("namespace", secret.Namespace), slog.String("name", secret.Name))
+
+		return mw.removeLeasesFinalizer(ctx, secret)
+	}
+
+	ttl, _ := time.ParseDuration(secret.Annotations[common.VaultLeaseTTLAnnotation])
+	renewedAt, err := time.Parse(time.RFC3339, secret.Annotations[common.VaultLeaseRenewedAtAnnotation])
+	renewalDue := len(recorded.leaseIDs) > 0 && (err != nil || !now.Before(renewedAt.Add(ttl/2)))
+	if !deleted && !renewalDue && len(recorded.superseded) == 0 {
+		return nil
+	}
+
+	vaultConfig, _, err := mw.vaultConfigFor(&model.AdmissionReview{Namespace: secret.Namespace}, secret)
+	if err != nil {
+		return err
+	}
+
+	vaultClient, release, err := mw.vaultClientFor(ctx, vaultConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create vault client")
+	}
+	defer release()
+
+	// Revoking a token revokes the leases it owns
+	revoked := recorded.superseded
+	if deleted && recorded.token != "" {
+		revoked = append(revoked, recorded.token)
+	}
+	for _, accessor := range revoked {
+		if err := vaultClient.RawClient().Auth().Token().RevokeAccessorWithContext(ctx, accessor); err != nil && !isInvalidAccessor(err) {
+			return errors.Wrapf(err, "failed to revoke the lease token with accessor %s", accessor)
+		}
+	}
+
+	if deleted {
+		return mw.removeLeasesFinalizer(ctx, secret)
+	}
+
+	if !renewalDue {
+		delete(secret.Annotations, common.VaultSupersededLeaseTokensAnnotation)
+		if err := mw.signLeases(ctx, secret, secret.Namespace); err != nil {
+			return err
+		}
+
+		// Nothing is left to revoke when the Secret is deleted
+		if len(recorded.leaseIDs) == 0 {
+			delete(secret.Labels, common.VaultLeasesLabel)
+			controllerutil.RemoveFinalizer(secret, common.VaultLeasesFinalizer)
+		}
+		_, err = mw.k8sClient.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+
+		return errors.Wrap(err, "failed to update the revoked leases of the Secret")
+	}
+
+	// The token is renewed first, Vault revokes the leases of an expired token
+	token := leaseToken{accessor: recorded.token}
+	if token.accessor != "" {
+		renewedToken, err := vaultClient.RawClient().Auth().Token().RenewAccessorWithContext(ctx, token.accessor, 0)
+		if err != nil {
+			return errors.Wrapf(err, "failed to renew the lease token with accessor %s", token.accessor)
+		}
+		if renewedToken != nil && renewedToken.Auth != nil {
+			token.ttl = time.Duration(renewedToken.Auth.LeaseDuration) * time.Second
+		}
+	}
+
+	leases := map[string]time.Duration{}
+	for _, leaseID := range recorded.leaseIDs {
+		renewed, err := vaultClient.RawClient().Sys().RenewWithContext(ctx, leaseID, int(ttl.Seconds()))
+		if err != nil {
+			return errors.Wrapf(err, "failed to renew lease %s", leaseID)
+		}
+
+		leases[leaseID] = ttl
+		if renewed != nil {
+			leases[leaseID] = time.Duration(renewed.LeaseDuration) * time.Second
+		}
+		if leases[leaseID] < ttl {
+			mw.logger.Warn("Vault lease is reaching its maximum TTL", slog.String("namespace", secret.Namespace), slog.String("name", secret.Name), slog.String("lease-id", leaseID), slog.Duration("ttl", leases[leaseID]))
+		}
+	}
+
+	if err := mw.recordLeases(ctx, secret, secret.Namespace, token, leases, nil, now); err != nil {
+		return err
+	}
+	_, err = mw.k8sClient.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+
+	return errors.Wrap(err, "failed to update the renewed leases of the Secret")
+}
+
+// isInvalidAccessor reports whether Vault rejected a token accessor as invalid, which
+// it does for the accessors of tokens that already expired or were revoked.
+func isInvalidAccessor(err error) bool {
+	var responseErr *vaultapi.ResponseError
+
+	return errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusBadRequest &&
+		slices.ContainsFunc(responseErr.Errors, func(message string) bool { return strings.Contains(message, "invalid accessor") })
+}
+
+func (mw *MutatingWebhook) removeLeasesFinalizer(ctx context.Context, secret *corev1.Secret) error {
+	controllerutil.RemoveFinalizer(secret, common.VaultLeasesFinalizer)
+	_, err := mw.k8sClient.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+
+	return errors.Wrap(err, "failed to remove the finalizer of the Secret")
+}
@@ -0,0 +1,298 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestDynamicSecrets(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+	viper.Set("enable_dynamic_secrets", true)
+
+	var mu sync.Mutex
+	var logins, created, leased int
+	var renewed, renewedTokens, revokedTokens []string
+	tokens := map[string]string{}      // accessors of the live lease tokens
+	leaseOwners := map[string]string{} // tokens owning the live leases
+
+	// expire expires a token like Vault, which revokes the leases it owns
+	expire := func(token string) {
+		for accessor, owner := range tokens {
+			if owner == token {
+				delete(tokens, accessor)
+			}
+		}
+		for leaseID, owner := range leaseOwners {
+			if owner == token {
+				delete(leaseOwners, leaseID)
+			}
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		token := r.Header.Get("X-Vault-Token")
+		var body map[string]any
+		if r.Method != http.MethodGet {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+		}
+
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			logins++
+			fmt.Fprintf(w, `{"auth": {"client_token": "approle-token-%d", "lease_duration": 60}}`, logins)
+
+		case "/v1/auth/token/create/vault-secrets-webhook-dynamic-secrets":
+			assert.True(t, strings.HasPrefix(token, "approle-token-"), "lease tokens are created with the login token")
+			created++
+			tokens[fmt.Sprintf("accessor-%d", created)] = fmt.Sprintf("secret-token-%d", created)
+			fmt.Fprintf(w, `{"auth": {"client_token": "secret-token-%d", "accessor": "accessor-%d", "lease_duration": 3600, "renewable": true}}`, created, created)
+
+		case "/v1/database/creds/app":
+			assert.True(t, strings.HasPrefix(token, "secret-token-"), "dynamic secrets are leased with the lease token")
+			leased++
+			leaseID := fmt.Sprintf("database/creds/app/%d", leased)
+			leaseOwners[leaseID] = token
+			fmt.Fprintf(w, `{"lease_id": %q, "lease_duration": 3600, "renewable": true, "data": {"username": "v-app", "password": "p4ss"}}`, leaseID)
+
+		case "/v1/kv/app":
+			fmt.Fprint(w, `{"lease_duration": 2764800, "data": {"token": "t0ken"}}`)
+
+		case "/v1/sys/leases/renew":
+			leaseID := body["lease_id"].(string)
+			if _, ok := leaseOwners[leaseID]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors": ["lease not found"]}`)
+
+				return
+			}
+			renewed = append(renewed, leaseID)
+			assert.EqualValues(t, 3600, body["increment"])
+			fmt.Fprintf(w, `{"lease_id": %q, "lease_duration": 1800, "renewable": true}`, leaseID)
+
+		case "/v1/auth/token/renew-accessor":
+			accessor := body["accessor"].(string)
+			renewedTokens = append(renewedTokens, accessor)
+			fmt.Fprintf(w, `{"auth": {"accessor": %q, "lease_duration": 3600, "renewable": true}}`, accessor)
+
+		case "/v1/auth/token/revoke-accessor":
+			accessor := body["accessor"].(string)
+			if _, ok := tokens[accessor]; !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors": ["1 error occurred:\n\t* invalid accessor\n\n"]}`)
+
+				return
+			}
+			revokedTokens = append(revokedTokens, accessor)
+			expire(tokens[accessor])
+			w.WriteHeader(http.StatusNoContent)
+
+		case "/v1/auth/token/revoke-self":
+			for accessor, owner := range tokens {
+				if owner == token {
+					revokedTokens = append(revokedTokens, accessor)
+				}
+			}
+			expire(token)
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	viper.Set("vault_addr", server.URL)
+	viper.Set("vault_approle_secret", "vault-approle")
+
+	mw := &MutatingWebhook{
+		k8sClient: fake.NewClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "vault-approle", Namespace: "vault-infra"},
+			Data: map[string][]byte{
+				AppRoleRoleIDKey:   []byte("my-role-id"),
+				AppRoleSecretIDKey: []byte("my-secret-id"),
+			},
+		}),
+		namespace: "vault-infra",
+		logger:    slog.New(slog.DiscardHandler),
+	}
+
+	references := map[string][]byte{
+		"username": []byte("vault:database/creds/app#username"),
+		"password": []byte("vault:database/creds/app#password"),
+		"token":    []byte("vault:kv/app#token"),
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data:       maps.Clone(references),
+	}
+	vaultConfig, _, err := mw.vaultConfigFor(&model.AdmissionReview{Namespace: "default"}, secret)
+	require.NoError(t, err)
+
+	// Dry runs lease no dynamic secrets
+	dryRun := secret.DeepCopy()
+	require.NoError(t, mw.MutateSecret(t.Context(), dryRun, vaultConfig, true))
+	assert.Zero(t, created)
+	assert.Zero(t, leased)
+	assert.NotContains(t, dryRun.Annotations, common.VaultLeaseIDsAnnotation)
+	assert.Empty(t, dryRun.Finalizers)
+
+	// Lease tokens owning no lease are revoked right away
+	static := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "static", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("vault:kv/app#token")},
+	}
+	require.NoError(t, mw.MutateSecret(t.Context(), static, vaultConfig, false))
+	assert.Equal(t, "t0ken", string(static.Data["token"]))
+	assert.Equal(t, []string{"accessor-1"}, revokedTokens)
+	assert.NotContains(t, static.Annotations, common.VaultLeaseTokenAccessorAnnotation)
+	assert.Empty(t, static.Finalizers)
+
+	before := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, mw.MutateSecret(t.Context(), secret, vaultConfig, false))
+
+	assert.Equal(t, "v-app", string(secret.Data["username"]))
+	assert.Equal(t, "p4ss", string(secret.Data["password"]))
+	assert.Equal(t, "database/creds/app/1", secret.Annotations[common.VaultLeaseIDsAnnotation], "KV version 1 secrets have no lease")
+	assert.Equal(t, "accessor-2", secret.Annotations[common.VaultLeaseTokenAccessorAnnotation])
+	assert.Equal(t, "1h0m0s", secret.Annotations[common.VaultLeaseTTLAnnotation])
+	assert.Equal(t, "true", secret.Labels[common.VaultLeasesLabel])
+	assert.Equal(t, []string{common.VaultLeasesFinalizer}, secret.Finalizers)
+
+	issuedAt, err := time.Parse(time.RFC3339, secret.Annotations[common.VaultLeaseRenewedAtAnnotation])
+	require.NoError(t, err)
+	assert.False(t, issuedAt.Before(before))
+
+	// The login token the Secret was mutated with expires long before the lease
+	mu.Lock()
+	expire(fmt.Sprintf("approle-token-%d", logins))
+	mu.Unlock()
+
+	_, err = mw.k8sClient.CoreV1().Secrets("default").Create(t.Context(), secret, metav1.CreateOptions{})
+	require.NoError(t, err)
+	get := func() *corev1.Secret {
+		secret, err := mw.k8sClient.CoreV1().Secrets("default").Get(t.Context(), "db", metav1.GetOptions{})
+		require.NoError(t, err)
+
+		return secret
+	}
+
+	// Leases are renewed along with their token once half of their TTL passed
+	require.NoError(t, mw.renewLeases(t.Context(), get(), issuedAt.Add(20*time.Minute)))
+	assert.Empty(t, renewed)
+
+	require.NoError(t, mw.renewLeases(t.Context(), get(), issuedAt.Add(40*time.Minute)))
+	assert.Equal(t, []string{"database/creds/app/1"}, renewed)
+	assert.Equal(t, []string{"accessor-2"}, renewedTokens)
+	assert.Equal(t, "30m0s", get().Annotations[common.VaultLeaseTTLAnnotation])
+	assert.Equal(t, issuedAt.Add(40*time.Minute).Format(time.RFC3339), get().Annotations[common.VaultLeaseRenewedAtAnnotation])
+
+	// The token of leases replaced by updating the Secret is revoked, along with them
+	updated := get()
+	updated.Data = maps.Clone(references)
+	require.NoError(t, mw.MutateSecret(t.Context(), updated, vaultConfig, false))
+	assert.Equal(t, "database/creds/app/2", updated.Annotations[common.VaultLeaseIDsAnnotation])
+	assert.Equal(t, "accessor-3", updated.Annotations[common.VaultLeaseTokenAccessorAnnotation])
+	assert.Equal(t, "accessor-2", updated.Annotations[common.VaultSupersededLeaseTokensAnnotation])
+	_, err = mw.k8sClient.CoreV1().Secrets("default").Update(t.Context(), updated, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, mw.renewLeases(t.Context(), get(), time.Now()))
+	assert.Equal(t, []string{"accessor-1", "accessor-2"}, revokedTokens)
+	assert.NotContains(t, leaseOwners, "database/creds/app/1")
+	assert.Contains(t, leaseOwners, "database/creds/app/2")
+	assert.NotContains(t, get().Annotations, common.VaultSupersededLeaseTokensAnnotation)
+
+	// The token, and so the leases, are revoked when the Secret is deleted
+	deleted := get()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	require.NoError(t, mw.renewLeases(t.Context(), deleted, time.Now()))
+	assert.Equal(t, []string{"accessor-1", "accessor-2", "accessor-3"}, revokedTokens)
+	assert.Empty(t, leaseOwners)
+	assert.Empty(t, get().Finalizers)
+
+	// Leases are only renewed and revoked for the namespace the webhook recorded them for
+	copied := updated.DeepCopy()
+	copied.Namespace = "payments"
+	copied.Finalizers = []string{common.VaultLeasesFinalizer}
+	copied, err = mw.k8sClient.CoreV1().Secrets("payments").Create(t.Context(), copied, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.ErrorContains(t, mw.renewLeases(t.Context(), copied.DeepCopy(), issuedAt.Add(2*time.Hour)), "not signed by the webhook")
+
+	copied.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	require.NoError(t, mw.renewLeases(t.Context(), copied, time.Now()))
+	assert.Len(t, revokedTokens, 3)
+	assert.Len(t, renewed, 1)
+
+	copied, err = mw.k8sClient.CoreV1().Secrets("payments").Get(t.Context(), "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, copied.Finalizers)
+}
+
+func TestIsInvalidAccessor(t *testing.T) {
+	assert.True(t, isInvalidAccessor(&vaultapi.ResponseError{StatusCode: http.StatusBadRequest, Errors: []string{"1 error occurred:\n\t* invalid accessor\n\n"}}))
+	assert.False(t, isInvalidAccessor(&vaultapi.ResponseError{StatusCode: http.StatusForbidden, Errors: []string{"permission denied"}}))
+	assert.False(t, isInvalidAccessor(errors.New("invalid accessor")))
+}
+
+func TestLeaseRenewalLeaderElection(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	k8sClient := fake.NewClientset()
+	mw := &MutatingWebhook{
+		k8sClient: k8sClient,
+		namespace: "vault-infra",
+		logger:    slog.New(slog.DiscardHandler),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, mw.StartLeaseRenewal(ctx))
+
+	// Only the replica holding the Lease renews and revokes the leases
+	assert.Eventually(t, func() bool {
+		lease, err := k8sClient.CoordinationV1().Leases("vault-infra").Get(ctx, "vault-secrets-webhook-dynamic-secrets", metav1.GetOptions{})
+
+		return err == nil && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != ""
+	}, 10*time.Second, 100*time.Millisecond)
+}
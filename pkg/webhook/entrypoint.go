@@ -0,0 +1,104 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"emperror.dev/errors"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// imageEntrypoint is the entrypoint and cmd of an image, supplied instead of
+// reading them from the config of the image in its registry.
+type imageEntrypoint struct {
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Cmd        []string `json:"cmd,omitempty"`
+}
+
+func parseImageEntrypoint(value string) (imageEntrypoint, error) {
+	var entrypoint imageEntrypoint
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entrypoint); err != nil {
+		return entrypoint, errors.New(`must be a JSON object like {"entrypoint": ["/app"], "cmd": ["serve"]}`)
+	}
+
+	if len(entrypoint.Entrypoint) == 0 && len(entrypoint.Cmd) == 0 {
+		return entrypoint, errors.New("must set entrypoint or cmd")
+	}
+
+	return entrypoint, nil
+}
+
+// parseImageEntrypoints parses the JSON map of images to their entrypoint of the
+// image_entrypoints setting. Images are keyed by their normalized name, so that
+// nginx:1.27 matches docker.io/library/nginx:1.27.
+func parseImageEntrypoints(value string) (map[string]imageEntrypoint, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var rawEntrypoints map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &rawEntrypoints); err != nil {
+		return nil, errors.Wrap(err, "must be a JSON object of images to their entrypoint")
+	}
+
+	entrypoints := make(map[string]imageEntrypoint, len(rawEntrypoints))
+	for image, rawEntrypoint := range rawEntrypoints {
+		entrypoint, err := parseImageEntrypoint(string(rawEntrypoint))
+		if err != nil {
+			return nil, errors.Wrapf(err, "entrypoint of image %s", image)
+		}
+
+		entrypoints[imageEntrypointKey(image)] = entrypoint
+	}
+
+	return entrypoints, nil
+}
+
+func imageEntrypointKey(image string) string {
+	reference, err := name.ParseReference(image)
+	if err != nil {
+		return image
+	}
+
+	return reference.Name()
+}
+
+// imageConfigFor returns the entrypoint and cmd of the image of a container, from the
+// container entrypoint annotation of its pod or the image entrypoints of the webhook if
+// they have it, which spares a lookup in the registry of the image.
+func (mw *MutatingWebhook) imageConfigFor(ctx context.Context, container *corev1.Container, podSpec *corev1.PodSpec, vaultConfig VaultConfig) (*v1.Config, error) {
+	if entrypoint, ok := vaultConfig.ContainerEntrypoints[container.Name]; ok {
+		return &v1.Config{Entrypoint: entrypoint.Entrypoint, Cmd: entrypoint.Cmd}, nil
+	}
+
+	if entrypoint, ok := mw.imageEntrypoints[imageEntrypointKey(container.Image)]; ok {
+		return &v1.Config{Entrypoint: entrypoint.Entrypoint, Cmd: entrypoint.Cmd}, nil
+	}
+
+	imageConfig, err := mw.registry.GetImageConfig(ctx, mw.k8sClient, vaultConfig.ObjectNamespace, vaultConfig.RegistrySkipVerify, container, podSpec)
+	if err != nil {
+		return nil, withReason(ReasonImageConfigLookupFailed, err)
+	}
+
+	return imageConfig, nil
+}
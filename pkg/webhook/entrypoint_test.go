@@ -0,0 +1,93 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestParseImageEntrypoints(t *testing.T) {
+	entrypoints, err := parseImageEntrypoints(`{"nginx:1.27": {"entrypoint": ["/docker-entrypoint.sh"], "cmd": ["nginx", "-g", "daemon off;"]}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]imageEntrypoint{
+		"index.docker.io/library/nginx:1.27": {Entrypoint: []string{"/docker-entrypoint.sh"}, Cmd: []string{"nginx", "-g", "daemon off;"}},
+	}, entrypoints)
+
+	entrypoints, err = parseImageEntrypoints("")
+	require.NoError(t, err)
+	assert.Empty(t, entrypoints)
+
+	_, err = parseImageEntrypoints(`["nginx:1.27"]`)
+	require.Error(t, err)
+
+	_, err = parseImageEntrypoints(`{"nginx:1.27": {"command": ["nginx"]}}`)
+	require.ErrorContains(t, err, "entrypoint of image nginx:1.27")
+
+	_, err = parseImageEntrypoints(`{"nginx:1.27": {}}`)
+	require.ErrorContains(t, err, "must set entrypoint or cmd")
+}
+
+func TestMutateContainersWithoutRegistry(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	imageEntrypoints, err := parseImageEntrypoints(`{"docker.io/library/nginx:1.27": {"entrypoint": ["/docker-entrypoint.sh"], "cmd": ["nginx"]}}`)
+	require.NoError(t, err)
+
+	mw := &MutatingWebhook{
+		registry:         failingRegistry{},
+		imageEntrypoints: imageEntrypoints,
+		logger:           slog.New(slog.DiscardHandler),
+	}
+
+	env := []corev1.EnvVar{{Name: "PASSWORD", Value: "vault:secret/data/app#password"}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Annotations: map[string]string{
+				common.ContainerEntrypointAnnotationPrefix + "app": `{"entrypoint": ["/app"], "cmd": ["serve"]}`,
+			},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app", Image: "ghcr.io/example/app:v1", Args: []string{"--verbose"}, Env: env},
+			{Name: "nginx", Image: "nginx:1.27", Env: env},
+			{Name: "worker", Image: "ghcr.io/example/worker:v1", Env: env},
+		}},
+	}
+
+	vaultConfig, _, err := mw.vaultConfigFor(&model.AdmissionReview{Namespace: "default"}, pod)
+	require.NoError(t, err)
+
+	// The annotation and the image entrypoints spare the registry lookup
+	_, err = mw.mutateContainers(t.Context(), pod.Spec.Containers[:2], &pod.Spec, vaultConfig)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/app", "--verbose"}, pod.Spec.Containers[0].Args)
+	assert.Equal(t, []string{"/docker-entrypoint.sh", "nginx"}, pod.Spec.Containers[1].Args)
+
+	_, err = mw.mutateContainers(t.Context(), pod.Spec.Containers[2:], &pod.Spec, vaultConfig)
+	require.Error(t, err)
+	assert.Equal(t, ReasonImageConfigLookupFailed, eventReason(err))
+}
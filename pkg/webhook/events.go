@@ -0,0 +1,215 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// Reasons of the Kubernetes Events recorded about mutations.
+const (
+	ReasonMutated                     = "Mutated"
+	ReasonMutationFailed              = "MutationFailed"
+	ReasonVaultAuthFailed             = "VaultAuthFailed"
+	ReasonVaultSecretNotFound         = "VaultSecretNotFound"
+	ReasonVaultReadFailed             = "VaultReadFailed"
+	ReasonVaultAccessDenied           = "VaultAccessDenied"
+	ReasonImageConfigLookupFailed     = "ImageConfigLookupFailed"
+	ReasonVaultCertificateIssueFailed = "VaultCertificateIssueFailed"
+)
+
+// reasonError attaches the reason of the Event recorded about a failed mutation to an error.
+type reasonError struct {
+	reason string
+	err    error
+}
+
+func (e reasonError) Error() string {
+	return e.err.Error()
+}
+
+func (e reasonError) Unwrap() error {
+	return e.err
+}
+
+func withReason(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return reasonError{reason: reason, err: err}
+}
+
+// vaultReadError attaches a reason to an error returned while reading secrets from Vault.
+// The Vault SDK reports missing paths and keys only through its error messages.
+func vaultReadError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if strings.Contains(err.Error(), "not found") {
+		return withReason(ReasonVaultSecretNotFound, err)
+	}
+
+	return withReason(ReasonVaultReadFailed, err)
+}
+
+// eventReason returns the reason of the Event recorded about a failed mutation.
+func eventReason(err error) string {
+	var reasonErr reasonError
+	if errors.As(err, &reasonErr) {
+		return reasonErr.reason
+	}
+
+	return ReasonMutationFailed
+}
+
+// RecordEvents makes the webhook record Kubernetes Events about the mutations it does.
+// Events about the same object are rate-limited to a burst of events_burst, refilled
+// every events_interval. Events are recorded until the context is done.
+func (mw *MutatingWebhook) RecordEvents(ctx context.Context) {
+	interval, err := time.ParseDuration(viper.GetString("events_interval"))
+	if err != nil || interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	broadcaster := record.NewBroadcaster(
+		record.WithContext(ctx),
+		record.WithCorrelatorOptions(record.CorrelatorOptions{
+			BurstSize: viper.GetInt("events_burst"),
+			QPS:       float32(1 / interval.Seconds()),
+		}),
+	)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: mw.k8sClient.CoreV1().Events("")})
+
+	mw.eventRecorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "vault-secrets-webhook"})
+}
+
+// EventRecorderMutator records an Event about the outcome of every mutation, if the
+// webhook records Events. Dry runs and objects left untouched are not recorded.
+func (mw *MutatingWebhook) EventRecorderMutator(mutator mutating.MutatorFunc) mutating.MutatorFunc {
+	return func(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
+		if mw.eventRecorder == nil || ar.DryRun {
+			return mutator(ctx, ar, obj)
+		}
+
+		var original runtime.Object
+		if runtimeObj, ok := obj.(runtime.Object); ok {
+			original = runtimeObj.DeepCopyObject()
+			// Removing a stale mutation error is not a mutation worth an Event
+			removeMutationError(original.(metav1.Object))
+		}
+
+		result, err := mutator(ctx, ar, obj)
+		if err != nil {
+			mw.recordFailureEvent(ar, obj, err)
+
+			return result, err
+		}
+
+		// Failures admitted by the mutation failure policy are recorded by the mutator
+		if result == nil || result.MutatedObject == nil || original == nil {
+			return result, err
+		}
+		if _, ok := result.MutatedObject.GetAnnotations()[common.MutationErrorAnnotation]; ok {
+			return result, err
+		}
+
+		if target := eventTarget(ar, obj); target != nil && !equality.Semantic.DeepEqual(original, result.MutatedObject) {
+			mw.eventRecorder.Eventf(target, corev1.EventTypeNormal, ReasonMutated, "Injected Vault secrets into %s", eventSubject(ar, obj))
+		}
+
+		return result, err
+	}
+}
+
+// recordFailureEvent records a Warning Event about a failed mutation, if the webhook records Events.
+func (mw *MutatingWebhook) recordFailureEvent(ar *model.AdmissionReview, obj metav1.Object, err error) {
+	if mw.eventRecorder == nil || ar.DryRun {
+		return
+	}
+
+	if target := eventTarget(ar, obj); target != nil {
+		mw.eventRecorder.Eventf(target, corev1.EventTypeWarning, eventReason(err), "Failed to mutate %s: %s", eventSubject(ar, obj), err)
+	}
+}
+
+// eventTarget returns the object Events about a mutation are recorded against: the
+// controller of the object, the object itself if it already exists, or its namespace.
+// Objects being created cannot be the target, as they do not exist yet.
+func eventTarget(ar *model.AdmissionReview, obj metav1.Object) *corev1.ObjectReference {
+	if owner := metav1.GetControllerOfNoCopy(obj); owner != nil {
+		return &corev1.ObjectReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			UID:        owner.UID,
+			Namespace:  ar.Namespace,
+		}
+	}
+
+	if obj.GetUID() != "" && ar.RequestGVK != nil {
+		return &corev1.ObjectReference{
+			APIVersion: metav1.GroupVersion{Group: ar.RequestGVK.Group, Version: ar.RequestGVK.Version}.String(),
+			Kind:       ar.RequestGVK.Kind,
+			Name:       obj.GetName(),
+			UID:        obj.GetUID(),
+			Namespace:  ar.Namespace,
+		}
+	}
+
+	if ar.Namespace == "" {
+		return nil
+	}
+
+	// Recorded in the namespace itself, so that its users can see the Event
+	return &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       ar.Namespace,
+		Namespace:  ar.Namespace,
+	}
+}
+
+// eventSubject names the mutated object in the message of an Event.
+func eventSubject(ar *model.AdmissionReview, obj metav1.Object) string {
+	kind := "object"
+	if ar.RequestGVK != nil {
+		kind = ar.RequestGVK.Kind
+	}
+
+	name := obj.GetName()
+	if name == "" {
+		name = obj.GetGenerateName()
+	}
+
+	return kind + " " + name
+}
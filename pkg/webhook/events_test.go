@@ -0,0 +1,160 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"emperror.dev/errors"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+type failingRegistry struct{}
+
+func (failingRegistry) GetImageConfig(_ context.Context, _ kubernetes.Interface, _ string, _ bool, _ *corev1.Container, _ *corev1.PodSpec) (*v1.Config, error) {
+	return nil, errors.New("registry unavailable")
+}
+
+func TestEventReason(t *testing.T) {
+	assert.Equal(t, ReasonMutationFailed, eventReason(errors.New("failed")))
+	assert.Equal(t, ReasonImageConfigLookupFailed, eventReason(errors.Wrap(withReason(ReasonImageConfigLookupFailed, errors.New("failed")), "wrapped")))
+	assert.Equal(t, ReasonVaultSecretNotFound, eventReason(vaultReadError(errors.New("path not found: secret/data/account"))))
+	assert.Equal(t, ReasonVaultSecretNotFound, eventReason(vaultReadError(errors.New("key 'password' not found under path: secret/data/account"))))
+	assert.Equal(t, ReasonVaultReadFailed, eventReason(vaultReadError(errors.New("permission denied"))))
+}
+
+func TestEventRecorderMutator(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	newPod := func(command []string, ownerReferences ...metav1.OwnerReference) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "my-app-", OwnerReferences: ownerReferences},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:    "app",
+					Image:   "myimage",
+					Command: command,
+					Env:     []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/db#password"}},
+				}},
+			},
+		}
+	}
+
+	controller := true
+	replicaSet := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "my-app-5d8f", UID: "1234", Controller: &controller}
+
+	tests := []struct {
+		name        string
+		pod         *corev1.Pod
+		annotations map[string]string
+		dryRun      bool
+		wantErr     bool
+		wantEvents  []string
+	}{
+		{
+			name:       "failure is recorded against the owner",
+			pod:        newPod(nil, replicaSet),
+			wantErr:    true,
+			wantEvents: []string{"Warning ImageConfigLookupFailed Failed to mutate Pod my-app-: registry unavailable"},
+		},
+		{
+			name:       "success is recorded",
+			pod:        newPod([]string{"/bin/app"}, replicaSet),
+			wantEvents: []string{"Normal Mutated Injected Vault secrets into Pod my-app-"},
+		},
+		{
+			name:        "a mutation error set by the user does not suppress the event",
+			pod:         newPod([]string{"/bin/app"}, replicaSet),
+			annotations: map[string]string{common.MutationErrorAnnotation: "hidden"},
+			wantEvents:  []string{"Normal Mutated Injected Vault secrets into Pod my-app-"},
+		},
+		{
+			name:    "dry runs are not recorded",
+			pod:     newPod(nil, replicaSet),
+			dryRun:  true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			recorder.IncludeObject = true
+
+			mw := &MutatingWebhook{
+				k8sClient:     fake.NewClientset(),
+				registry:      failingRegistry{},
+				logger:        slog.New(slog.DiscardHandler),
+				eventRecorder: recorder,
+			}
+
+			ar := &model.AdmissionReview{
+				Namespace:  "default",
+				DryRun:     tt.dryRun,
+				RequestGVK: &metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			}
+
+			tt.pod.Annotations = tt.annotations
+			result, err := mw.EventRecorderMutator(mw.VaultSecretsMutator)(context.Background(), ar, tt.pod)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.NotContains(t, result.MutatedObject.(metav1.Object).GetAnnotations(), common.MutationErrorAnnotation)
+			}
+
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+
+			require.Len(t, events, len(tt.wantEvents))
+			for i, event := range events {
+				assert.Contains(t, event, tt.wantEvents[i])
+				assert.Contains(t, event, "involvedObject{kind=ReplicaSet,apiVersion=apps/v1}")
+			}
+		})
+	}
+}
+
+func TestEventTarget(t *testing.T) {
+	ar := &model.AdmissionReview{
+		Namespace:  "default",
+		RequestGVK: &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+	}
+
+	target := eventTarget(ar, &metav1.ObjectMeta{Name: "my-app"})
+	assert.Equal(t, &corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: "default", Namespace: "default"}, target)
+
+	target = eventTarget(ar, &metav1.ObjectMeta{Name: "my-app", UID: "1234"})
+	assert.Equal(t, &corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "my-app", UID: "1234", Namespace: "default"}, target)
+
+	assert.Nil(t, eventTarget(&model.AdmissionReview{}, &metav1.ObjectMeta{Name: "my-app"}))
+}
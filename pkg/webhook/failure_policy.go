@@ -0,0 +1,115 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+const (
+	// MutationFailurePolicyFail rejects the admission request if the mutation fails.
+	MutationFailurePolicyFail = "Fail"
+	// MutationFailurePolicyIgnore admits the object unmodified if the mutation fails,
+	// marked with the mutation-error annotation and an admission warning.
+	MutationFailurePolicyIgnore = "Ignore"
+)
+
+// WatchNamespaceFailurePolicies starts watching namespaces and blocks until the
+// cache is synced. Once it returns, the mutation failure policy annotation of a
+// namespace overrides the global mutation failure policy for the objects in it.
+func (mw *MutatingWebhook) WatchNamespaceFailurePolicies(ctx context.Context) error {
+	if err := mw.watchNamespaces(ctx); err != nil {
+		return err
+	}
+
+	mw.namespaceFailurePolicies = true
+
+	return nil
+}
+
+// mutationFailurePolicy returns the mutation failure policy of the given namespace.
+// Unknown values fail closed.
+func (mw *MutatingWebhook) mutationFailurePolicy(namespace string) string {
+	policy := viper.GetString("mutation_failure_policy")
+
+	if mw.namespaceFailurePolicies && namespace != "" {
+		ns, err := mw.namespaces.Get(namespace)
+		if err != nil && !apierrors.IsNotFound(err) {
+			mw.logger.Warn("failed to get namespace, using the global mutation failure policy", slog.String("namespace", namespace), slog.Any("error", err))
+		}
+		if err == nil {
+			if val, ok := ns.GetAnnotations()[common.MutationFailurePolicyAnnotation]; ok {
+				policy = val
+			}
+		}
+	}
+
+	if strings.EqualFold(policy, MutationFailurePolicyIgnore) {
+		return MutationFailurePolicyIgnore
+	}
+
+	return MutationFailurePolicyFail
+}
+
+// failOpen returns the result admitting the original object unmodified after a failed
+// mutation, or nil if the failure policy of the namespace rejects the object.
+func (mw *MutatingWebhook) failOpen(ar *model.AdmissionReview, original runtime.Object, warnings []string, err error) *mutating.MutatorResult {
+	if original == nil || mw.mutationFailurePolicy(ar.Namespace) != MutationFailurePolicyIgnore {
+		return nil
+	}
+
+	obj := original.(metav1.Object)
+
+	mw.logger.Warn("mutation failed, admitting the object unmodified as the mutation failure policy is Ignore",
+		slog.String("namespace", ar.Namespace), slog.String("name", obj.GetName()), slog.Any("error", err))
+	mw.recordFailureEvent(ar, obj, err)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.MutationErrorAnnotation] = err.Error()
+	obj.SetAnnotations(annotations)
+
+	return &mutating.MutatorResult{
+		MutatedObject: obj,
+		Warnings:      append(warnings, "Vault secrets were not injected, the object is admitted unmodified: "+err.Error()),
+	}
+}
+
+// removeMutationError removes the mutation error annotation from an object, left by
+// a previous failed mutation or supplied by the user, and reports whether it had one.
+func removeMutationError(obj metav1.Object) bool {
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[common.MutationErrorAnnotation]; !ok {
+		return false
+	}
+
+	delete(annotations, common.MutationErrorAnnotation)
+	obj.SetAnnotations(annotations)
+
+	return true
+}
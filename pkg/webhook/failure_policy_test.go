@@ -0,0 +1,151 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestMutationFailurePolicy(t *testing.T) {
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-app"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "myimage",
+					Env:   []corev1.EnvVar{{Name: "DB_PASSWORD", Value: "vault:secret/data/db#password"}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		globalPolicy      string
+		namespacePolicies bool
+		namespace         string
+		wantAdmitted      bool
+	}{
+		{name: "fails closed by default", namespace: "dev"},
+		{name: "global Ignore policy", globalPolicy: MutationFailurePolicyIgnore, namespace: "dev", wantAdmitted: true},
+		{name: "unknown global policy fails closed", globalPolicy: "Open", namespace: "dev"},
+		{name: "namespace annotations are ignored unless enabled", namespace: "dev"},
+		{name: "namespace Ignore policy", namespacePolicies: true, namespace: "dev", wantAdmitted: true},
+		{name: "namespace Fail policy overrides global Ignore", globalPolicy: MutationFailurePolicyIgnore, namespacePolicies: true, namespace: "critical"},
+		{name: "namespaces without annotation use the global policy", globalPolicy: MutationFailurePolicyIgnore, namespacePolicies: true, namespace: "other", wantAdmitted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			SetConfigDefaults()
+			if tt.globalPolicy != "" {
+				viper.Set("mutation_failure_policy", tt.globalPolicy)
+			}
+
+			k8sClient := fake.NewClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "dev",
+					Annotations: map[string]string{common.MutationFailurePolicyAnnotation: MutationFailurePolicyIgnore},
+				}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "critical",
+					Annotations: map[string]string{common.MutationFailurePolicyAnnotation: MutationFailurePolicyFail},
+				}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+			)
+
+			recorder := record.NewFakeRecorder(10)
+			mw := &MutatingWebhook{
+				k8sClient:     k8sClient,
+				registry:      failingRegistry{},
+				logger:        slog.New(slog.DiscardHandler),
+				eventRecorder: recorder,
+			}
+			if tt.namespacePolicies {
+				require.NoError(t, mw.WatchNamespaceFailurePolicies(context.Background()))
+			}
+
+			pod := newPod()
+			ar := &model.AdmissionReview{Namespace: tt.namespace, RequestGVK: &metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}}
+			result, err := mw.EventRecorderMutator(mw.VaultSecretsMutator)(context.Background(), ar, pod)
+
+			close(recorder.Events)
+			assert.Len(t, recorder.Events, 1, "the failure is recorded once")
+			assert.Contains(t, <-recorder.Events, "Warning ImageConfigLookupFailed")
+
+			if !tt.wantAdmitted {
+				require.Error(t, err)
+				assert.Equal(t, ReasonImageConfigLookupFailed, eventReason(err))
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			admitted, ok := result.MutatedObject.(*corev1.Pod)
+			require.True(t, ok)
+			assert.Equal(t, newPod().Spec, admitted.Spec, "the object is admitted unmodified")
+			assert.Equal(t, "registry unavailable", admitted.Annotations[common.MutationErrorAnnotation])
+			require.Len(t, result.Warnings, 1)
+			assert.Contains(t, result.Warnings[0], "registry unavailable")
+		})
+	}
+}
+
+func TestMutationErrorIsRemoved(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	recorder := record.NewFakeRecorder(10)
+	mw := &MutatingWebhook{
+		k8sClient:     fake.NewClientset(),
+		logger:        slog.New(slog.DiscardHandler),
+		eventRecorder: recorder,
+	}
+
+	// Left by a previous mutation, that failed open
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "plain",
+			Annotations: map[string]string{common.MutationErrorAnnotation: "vault unavailable"},
+		},
+		Data: map[string][]byte{"greeting": []byte("hello")},
+	}
+
+	ar := &model.AdmissionReview{Namespace: "default", RequestGVK: &metav1.GroupVersionKind{Version: "v1", Kind: "Secret"}}
+	result, err := mw.EventRecorderMutator(mw.VaultSecretsMutator)(context.Background(), ar, secret)
+	require.NoError(t, err)
+
+	require.NotNil(t, result.MutatedObject)
+	assert.NotContains(t, result.MutatedObject.(*corev1.Secret).Annotations, common.MutationErrorAnnotation)
+
+	close(recorder.Events)
+	assert.Empty(t, recorder.Events, "removing the error is not a mutation")
+}
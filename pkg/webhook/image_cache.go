@@ -0,0 +1,183 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"container/list"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	imageCacheHitsCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "registry",
+			Subsystem: "image_cache",
+			Name:      "hits_total",
+			Help:      "Count of image configs served from the image cache.",
+		},
+	)
+	imageCacheMissesCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "registry",
+			Subsystem: "image_cache",
+			Name:      "misses_total",
+			Help:      "Count of image configs fetched from the registry.",
+		},
+	)
+	imageCacheEvictionsCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "registry",
+			Subsystem: "image_cache",
+			Name:      "evictions_total",
+			Help:      "Count of image configs evicted from the image cache.",
+		},
+		[]string{"reason"},
+	)
+	imageCacheLookupDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "registry",
+			Subsystem: "image_cache",
+			Name:      "lookup_duration_seconds",
+			Help:      "Duration of image config lookups in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"result"},
+	)
+)
+
+// imageConfigCache is a least recently used cache of image configs bounded in size.
+// Entries added with a TTL expire, the others are only evicted to make room.
+type imageConfigCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// recency orders the entries from the most to the least recently used
+	recency *list.List
+	now     func() time.Time
+}
+
+type imageCacheEntry struct {
+	key       string
+	config    *v1.Config
+	expiresAt time.Time
+}
+
+func newImageConfigCache(size int) *imageConfigCache {
+	return &imageConfigCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		recency: list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *imageConfigCache) Get(key string) (*v1.Config, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*imageCacheEntry)
+	if !entry.expiresAt.IsZero() && c.now().After(entry.expiresAt) {
+		c.remove(element, "expired")
+
+		return nil, false
+	}
+
+	c.recency.MoveToFront(element)
+
+	return entry.config, true
+}
+
+// Set adds or replaces the config of a key, expiring after the TTL if it is positive.
+func (c *imageConfigCache) Set(key string, config *v1.Config, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &imageCacheEntry{key: key, config: config}
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.recency.MoveToFront(element)
+
+		return
+	}
+
+	c.entries[key] = c.recency.PushFront(entry)
+
+	for c.recency.Len() > c.size {
+		c.remove(c.recency.Back(), "size")
+	}
+}
+
+// Flush removes every entry and returns how many there were.
+func (c *imageConfigCache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	flushed := c.recency.Len()
+	c.entries = map[string]*list.Element{}
+	c.recency.Init()
+
+	return flushed
+}
+
+func (c *imageConfigCache) remove(element *list.Element, reason string) {
+	c.recency.Remove(element)
+	delete(c.entries, element.Value.(*imageCacheEntry).key)
+	imageCacheEvictionsCount.WithLabelValues(reason).Inc()
+}
+
+// ImageCacheFlushPath is the path of the admin endpoint flushing the image config cache.
+// It is unauthenticated, so it is only served on the telemetry listener, never on the
+// admission listener reachable by the API server.
+const ImageCacheFlushPath = "/admin/image-cache/flush"
+
+// imageCacheFlusher is implemented by image registries caching image configs.
+type imageCacheFlusher interface {
+	FlushImageCache() int
+}
+
+// ImageCacheFlushHandler flushes the image config cache of the webhook on POST requests,
+// so that the configs of images pushed again under the same tag are fetched again.
+func (mw *MutatingWebhook) ImageCacheFlushHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		if flusher, ok := mw.registry.(imageCacheFlusher); ok {
+			flushed := flusher.FlushImageCache()
+			mw.logger.Info("image cache flushed", slog.Int("entries", flushed))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
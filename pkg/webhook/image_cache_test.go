@@ -0,0 +1,111 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageConfigCache(t *testing.T) {
+	now := time.Now()
+	imageCache := newImageConfigCache(2)
+	imageCache.now = func() time.Time { return now }
+
+	first := &v1.Config{Entrypoint: []string{"/first"}}
+	second := &v1.Config{Entrypoint: []string{"/second"}}
+	third := &v1.Config{Entrypoint: []string{"/third"}}
+
+	imageCache.Set("first", first, time.Hour)
+	imageCache.Set("second", second, 0)
+
+	// The least recently used entry is evicted to make room
+	_, ok := imageCache.Get("first")
+	require.True(t, ok)
+	imageCache.Set("third", third, 0)
+
+	_, ok = imageCache.Get("second")
+	assert.False(t, ok)
+
+	config, ok := imageCache.Get("first")
+	require.True(t, ok)
+	assert.Equal(t, first, config)
+
+	// Entries with a TTL expire, the others do not
+	now = now.Add(2 * time.Hour)
+
+	_, ok = imageCache.Get("first")
+	assert.False(t, ok)
+
+	config, ok = imageCache.Get("third")
+	require.True(t, ok)
+	assert.Equal(t, third, config)
+
+	assert.Equal(t, 1, imageCache.Flush())
+	_, ok = imageCache.Get("third")
+	assert.False(t, ok)
+}
+
+func TestRegistryCachesImageConfigsByDigest(t *testing.T) {
+	registry := &Registry{imageCache: newImageConfigCache(10), imageTTL: time.Hour}
+	imageConfig := &v1.Config{Entrypoint: []string{"/app"}}
+	platform := &v1.Platform{OS: "linux", Architecture: "arm64"}
+	digest := v1.Hash{Algorithm: "sha256", Hex: "3f7a3e0a2c5e1d4b8f9a6c7d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"}
+
+	tag, err := name.ParseReference("ghcr.io/example/app:v1")
+	require.NoError(t, err)
+	registry.cacheImageConfig(tag, digest, platform, imageConfig)
+
+	pinned, err := name.ParseReference("ghcr.io/example/app@" + digest.String())
+	require.NoError(t, err)
+
+	config, ok := registry.imageCache.Get(imageCacheKey(pinned, platform))
+	require.True(t, ok, "the config is cached by the digest the tag resolved to")
+	assert.Equal(t, imageConfig, config)
+
+	_, ok = registry.imageCache.Get(imageCacheKey(pinned, nil))
+	assert.False(t, ok, "the config is cached by platform")
+
+	assert.Equal(t, 2, registry.FlushImageCache())
+}
+
+func TestImageCacheFlushHandler(t *testing.T) {
+	registry := &Registry{imageCache: newImageConfigCache(10), imageTTL: time.Hour}
+	registry.imageCache.Set("ghcr.io/example/app:v1", &v1.Config{}, time.Hour)
+
+	mw := &MutatingWebhook{registry: registry, logger: slog.New(slog.DiscardHandler)}
+
+	recorder := httptest.NewRecorder()
+	mw.ImageCacheFlushHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, ImageCacheFlushPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	_, ok := registry.imageCache.Get("ghcr.io/example/app:v1")
+	require.True(t, ok)
+
+	recorder = httptest.NewRecorder()
+	mw.ImageCacheFlushHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, ImageCacheFlushPath, nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	_, ok = registry.imageCache.Get("ghcr.io/example/app:v1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,277 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// VaultInjectionPolicyResource is the resource of the cluster-scoped
+// VaultInjectionPolicy custom resource.
+var VaultInjectionPolicyResource = schema.GroupVersionResource{
+	Group:    "vault.security.banzaicloud.io",
+	Version:  "v1alpha1",
+	Resource: "vaultinjectionpolicies",
+}
+
+// VaultInjectionPolicy overrides the webhook defaults for the namespaces
+// selected by its namespace selector. Annotations on the mutated object
+// still take precedence over the policy.
+type VaultInjectionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VaultInjectionPolicySpec `json:"spec"`
+}
+
+// VaultInjectionPolicySpec holds the defaults of a VaultInjectionPolicy.
+// Empty fields leave the webhook default in place.
+type VaultInjectionPolicySpec struct {
+	// NamespaceSelector selects the namespaces the policy applies to.
+	// A missing selector selects every namespace.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Priority orders overlapping policies, the highest priority wins.
+	// Policies with the same priority are ordered by name.
+	Priority int32 `json:"priority,omitempty"`
+
+	VaultAddr           string `json:"vaultAddr,omitempty"`
+	VaultRole           string `json:"vaultRole,omitempty"`
+	VaultPath           string `json:"vaultPath,omitempty"`
+	VaultAuthMethod     string `json:"vaultAuthMethod,omitempty"`
+	VaultNamespace      string `json:"vaultNamespace,omitempty"`
+	VaultServiceAccount string `json:"vaultServiceAccount,omitempty"`
+	VaultTLSSecret      string `json:"vaultTLSSecret,omitempty"`
+	VaultAppRoleSecret  string `json:"vaultAppRoleSecret,omitempty"`
+	VaultAppRolePath    string `json:"vaultAppRolePath,omitempty"`
+	VaultCertAuthSecret string `json:"vaultCertAuthSecret,omitempty"`
+	VaultCertAuthPath   string `json:"vaultCertAuthPath,omitempty"`
+
+	VaultImage           string `json:"vaultImage,omitempty"`
+	VaultImagePullPolicy string `json:"vaultImagePullPolicy,omitempty"`
+	VaultEnvImage        string `json:"vaultEnvImage,omitempty"`
+	VaultEnvPullPolicy   string `json:"vaultEnvPullPolicy,omitempty"`
+	VaultCtImage         string `json:"vaultCtImage,omitempty"`
+	VaultCtPullPolicy    string `json:"vaultCtPullPolicy,omitempty"`
+
+	VaultEnvResources   *corev1.ResourceRequirements `json:"vaultEnvResources,omitempty"`
+	VaultAgentResources *corev1.ResourceRequirements `json:"vaultAgentResources,omitempty"`
+	// VaultCtResources only sets limits, consul-template has no requests.
+	VaultCtResources *corev1.ResourceRequirements `json:"vaultCtResources,omitempty"`
+}
+
+// settings returns the non-empty fields of the spec keyed by the
+// corresponding webhook setting.
+func (s VaultInjectionPolicySpec) settings() configDefaults {
+	settings := configDefaults{}
+	set := func(key, value string) {
+		if value != "" {
+			settings[strings.ToLower(key)] = value
+		}
+	}
+
+	set("vault_addr", s.VaultAddr)
+	set("vault_role", s.VaultRole)
+	set("vault_path", s.VaultPath)
+	set("vault_auth_method", s.VaultAuthMethod)
+	set("VAULT_NAMESPACE", s.VaultNamespace)
+	set("vault_serviceaccount", s.VaultServiceAccount)
+	set("vault_tls_secret", s.VaultTLSSecret)
+	set("vault_approle_secret", s.VaultAppRoleSecret)
+	set("vault_approle_path", s.VaultAppRolePath)
+	set("vault_cert_auth_secret", s.VaultCertAuthSecret)
+	set("vault_cert_auth_path", s.VaultCertAuthPath)
+	set("vault_image", s.VaultImage)
+	set("vault_image_pull_policy", s.VaultImagePullPolicy)
+	set("vault_env_image", s.VaultEnvImage)
+	set("vault_env_pull_policy", s.VaultEnvPullPolicy)
+	set("vault_ct_image", s.VaultCtImage)
+	set("vault_ct_pull_policy", s.VaultCtPullPolicy)
+
+	setResources := func(prefix string, resources *corev1.ResourceRequirements) {
+		if resources == nil {
+			return
+		}
+		if q, ok := resources.Requests[corev1.ResourceCPU]; ok {
+			set(prefix+"_CPU_REQUEST", q.String())
+		}
+		if q, ok := resources.Requests[corev1.ResourceMemory]; ok {
+			set(prefix+"_MEMORY_REQUEST", q.String())
+		}
+		if q, ok := resources.Limits[corev1.ResourceCPU]; ok {
+			set(prefix+"_CPU_LIMIT", q.String())
+		}
+		if q, ok := resources.Limits[corev1.ResourceMemory]; ok {
+			set(prefix+"_MEMORY_LIMIT", q.String())
+		}
+	}
+
+	setResources("VAULT_ENV", s.VaultEnvResources)
+	setResources("VAULT_AGENT", s.VaultAgentResources)
+	setResources("VAULT_CT", s.VaultCtResources)
+
+	return settings
+}
+
+// configDefaults holds webhook settings that take precedence over the
+// configuration read by viper. Keys are lower case setting names.
+// A nil configDefaults falls back to viper for every setting.
+type configDefaults map[string]string
+
+func (d configDefaults) GetString(key string) string {
+	if val, ok := d[strings.ToLower(key)]; ok {
+		return val
+	}
+
+	return viper.GetString(key)
+}
+
+func (d configDefaults) GetInt(key string) int {
+	if val, ok := d[strings.ToLower(key)]; ok {
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+
+	return viper.GetInt(key)
+}
+
+// WatchInjectionPolicies starts watching VaultInjectionPolicy resources and
+// namespaces, and blocks until their caches are synced. Once it returns, the
+// matching policies are merged into the defaults of every admission request.
+func (mw *MutatingWebhook) WatchInjectionPolicies(ctx context.Context, dynamicClient dynamic.Interface) error {
+	store, err := mw.watchPolicies(ctx, dynamicClient, VaultInjectionPolicyResource, toVaultInjectionPolicy)
+	if err != nil {
+		return errors.WrapIf(err, "failed to watch VaultInjectionPolicies")
+	}
+
+	mw.injectionPolicies = store
+
+	return nil
+}
+
+// watchPolicies starts watching namespaces and the given cluster-scoped policy
+// resource, and returns the store of the policies once the caches are synced.
+func (mw *MutatingWebhook) watchPolicies(ctx context.Context, dynamicClient dynamic.Interface, resource schema.GroupVersionResource, transform cache.TransformFunc) (cache.Store, error) {
+	if err := mw.watchNamespaces(ctx); err != nil {
+		return nil, err
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+	informer := factory.ForResource(resource).Informer()
+	if err := informer.SetTransform(transform); err != nil {
+		return nil, errors.Wrap(err, "failed to set transform")
+	}
+
+	factory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return nil, errors.New("failed to sync cache")
+	}
+
+	return informer.GetStore(), nil
+}
+
+// namespaceSelector converts the namespace selector of a policy,
+// a missing selector selects every namespace.
+func namespaceSelector(selector *metav1.LabelSelector) (labels.Selector, error) {
+	if selector == nil {
+		return labels.Everything(), nil
+	}
+
+	return metav1.LabelSelectorAsSelector(selector)
+}
+
+// configDefaultsFor merges the VaultInjectionPolicies matching the given
+// namespace, in ascending priority order.
+func (mw *MutatingWebhook) configDefaultsFor(namespace string) (configDefaults, error) {
+	if mw.injectionPolicies == nil {
+		return nil, nil
+	}
+
+	namespaceLabels, err := mw.namespaceLabels(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []*VaultInjectionPolicy
+	for _, obj := range mw.injectionPolicies.List() {
+		policy, ok := obj.(*VaultInjectionPolicy)
+		if !ok {
+			continue
+		}
+
+		selector, err := namespaceSelector(policy.Spec.NamespaceSelector)
+		if err != nil {
+			mw.logger.Warn("ignoring VaultInjectionPolicy with invalid namespace selector",
+				slog.String("policy", policy.Name), slog.Any("error", err))
+
+			continue
+		}
+
+		if selector.Matches(namespaceLabels) {
+			policies = append(policies, policy)
+		}
+	}
+
+	// Policies applied later override earlier ones, so the highest priority
+	// goes last and, within a priority, the alphabetically first name wins.
+	slices.SortFunc(policies, func(a, b *VaultInjectionPolicy) int {
+		return cmp.Or(cmp.Compare(a.Spec.Priority, b.Spec.Priority), strings.Compare(b.Name, a.Name))
+	})
+
+	defaults := configDefaults{}
+	for _, policy := range policies {
+		maps.Copy(defaults, policy.Spec.settings())
+	}
+
+	return defaults, nil
+}
+
+// toVaultInjectionPolicy converts the unstructured objects of the informer to
+// VaultInjectionPolicies, so that requests don't have to convert them.
+func toVaultInjectionPolicy(obj any) (any, error) { //nolint:unparam // implements cache.TransformFunc
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+
+	policy := &VaultInjectionPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), policy); err != nil {
+		// Keep the unstructured object, it is skipped when merging policies.
+		logger.Warn("ignoring malformed VaultInjectionPolicy", slog.String("policy", u.GetName()), slog.Any("error", err))
+
+		return obj, nil
+	}
+
+	return policy, nil
+}
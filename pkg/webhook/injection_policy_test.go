@@ -0,0 +1,191 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func newInjectionPolicy(t *testing.T, name string, priority int32, selector *metav1.LabelSelector, spec VaultInjectionPolicySpec) runtime.Object {
+	t.Helper()
+
+	spec.NamespaceSelector = selector
+	spec.Priority = priority
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&VaultInjectionPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: VaultInjectionPolicyResource.GroupVersion().String(),
+			Kind:       "VaultInjectionPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec,
+	})
+	require.NoError(t, err)
+
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestInjectionPolicyDefaults(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	k8sClient := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"team": "web"}}},
+	)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{VaultInjectionPolicyResource: "VaultInjectionPolicyList"},
+		newInjectionPolicy(t, "cluster", 0, nil, VaultInjectionPolicySpec{
+			VaultAddr:  "https://vault.shared:8200",
+			VaultImage: "hashicorp/vault:1.20",
+		}),
+		newInjectionPolicy(t, "payments", 10, &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}, VaultInjectionPolicySpec{
+			VaultAddr: "https://vault.payments:8200",
+			VaultPath: "payments",
+			VaultEnvResources: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+			VaultAgentResources: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+			},
+			VaultCtResources: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+			},
+		}),
+		newInjectionPolicy(t, "payments-low", 5, &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}, VaultInjectionPolicySpec{
+			VaultAddr: "https://vault.ignored:8200",
+			VaultRole: "payments",
+		}),
+	)
+
+	mw := &MutatingWebhook{k8sClient: k8sClient, logger: slog.Default()}
+	require.NoError(t, mw.WatchInjectionPolicies(context.Background(), dynamicClient))
+
+	tests := []struct {
+		name            string
+		namespace       string
+		annotations     map[string]string
+		wantAddr        string
+		wantPath        string
+		wantRole        string
+		wantAgentImage  string
+		wantEnvMemLimit string
+		wantEnvCPULimit string
+
+		wantAgentCPULimit   string
+		wantAgentMemRequest string
+		wantCtMemLimit      string
+	}{
+		{
+			name:            "namespace matching every policy uses the highest priority values",
+			namespace:       "payments",
+			wantAddr:        "https://vault.payments:8200",
+			wantPath:        "payments",
+			wantRole:        "payments",
+			wantAgentImage:  "hashicorp/vault:1.20",
+			wantEnvMemLimit: "512Mi",
+			wantEnvCPULimit: "500m",
+
+			wantAgentCPULimit:   "250m",
+			wantAgentMemRequest: "64Mi",
+			wantCtMemLimit:      "256Mi",
+		},
+		{
+			name:            "namespace matching only the cluster-wide policy",
+			namespace:       "web",
+			wantAddr:        "https://vault.shared:8200",
+			wantPath:        "kubernetes",
+			wantRole:        "default",
+			wantAgentImage:  "hashicorp/vault:1.20",
+			wantEnvMemLimit: "256Mi",
+			wantEnvCPULimit: "500m",
+
+			wantAgentCPULimit:   "100m",
+			wantAgentMemRequest: "128Mi",
+			wantCtMemLimit:      "128Mi",
+		},
+		{
+			name:      "annotations take precedence over policies",
+			namespace: "payments",
+			annotations: map[string]string{
+				common.VaultPathAnnotation:  "override",
+				common.VaultImageAnnotation: "hashicorp/vault:2.0",
+
+				common.VaultAgentCPULimitAnnotation:        "1",
+				common.VaultConsulTemplateMemoryAnnotation: "512Mi",
+			},
+			wantAddr:        "https://vault.payments:8200",
+			wantPath:        "override",
+			wantRole:        "payments",
+			wantAgentImage:  "hashicorp/vault:2.0",
+			wantEnvMemLimit: "512Mi",
+			wantEnvCPULimit: "500m",
+
+			wantAgentCPULimit:   "1",
+			wantAgentMemRequest: "64Mi",
+			wantCtMemLimit:      "512Mi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults, err := mw.configDefaultsFor(tt.namespace)
+			require.NoError(t, err)
+
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			vaultConfig, _, err := parseVaultConfig(secret, &model.AdmissionReview{Namespace: tt.namespace}, defaults, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantAddr, vaultConfig.Addr)
+			assert.False(t, vaultConfig.AddrFromObject)
+			assert.Equal(t, tt.wantPath, vaultConfig.Path)
+			assert.Equal(t, tt.wantRole, vaultConfig.Role)
+			assert.Equal(t, tt.wantAgentImage, vaultConfig.AgentImage)
+			assert.Equal(t, resource.MustParse(tt.wantEnvMemLimit), vaultConfig.EnvMemoryLimit)
+			assert.Equal(t, resource.MustParse(tt.wantEnvCPULimit), vaultConfig.EnvCPULimit)
+			assert.Equal(t, resource.MustParse(tt.wantAgentCPULimit), vaultConfig.AgentCPULimit)
+			assert.Equal(t, resource.MustParse(tt.wantAgentMemRequest), vaultConfig.AgentMemoryRequest)
+			assert.Equal(t, resource.MustParse(tt.wantCtMemLimit), vaultConfig.CtMemory)
+		})
+	}
+}
+
+func TestInjectionPolicyDisabled(t *testing.T) {
+	mw := &MutatingWebhook{}
+
+	defaults, err := mw.configDefaultsFor("default")
+	require.NoError(t, err)
+	assert.Nil(t, defaults)
+}
@@ -0,0 +1,72 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// runAsLeader runs a background loop on the single replica of the webhook holding the
+// given Lease in the webhook namespace, until the context is done. The loop is canceled
+// when the replica loses the Lease, and started again if it acquires it again.
+func (mw *MutatingWebhook) runAsLeader(ctx context.Context, lease string, run func(context.Context)) error {
+	identity, err := os.Hostname()
+	if err != nil {
+		return errors.Wrap(err, "failed to get the leader election identity")
+	}
+
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		mw.namespace,
+		lease,
+		mw.k8sClient.CoreV1(),
+		mw.k8sClient.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: identity},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create Lease %s", lease)
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Name:            lease,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: run,
+			OnStoppedLeading: func() {
+				mw.logger.Debug("stopped leading", slog.String("lease", lease), slog.String("identity", identity))
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to elect the leader of Lease %s", lease)
+	}
+
+	// Run returns when the Lease is lost
+	go wait.UntilWithContext(ctx, elector.Run, time.Second)
+
+	return nil
+}
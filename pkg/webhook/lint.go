@@ -0,0 +1,248 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// Kinds of annotation findings reported by LintAnnotations.
+const (
+	// LintUnknown is an annotation with the webhook prefix the webhook does not know, usually a typo.
+	LintUnknown = "unknown"
+	// LintDeprecated is an annotation that still works but has a replacement.
+	LintDeprecated = "deprecated"
+	// LintMalformed is an annotation whose value cannot be parsed.
+	LintMalformed = "malformed"
+	// LintIneffective is a well-formed annotation that has no effect on the object.
+	LintIneffective = "ineffective"
+)
+
+// AnnotationFinding is a problem with a single webhook annotation of an object.
+type AnnotationFinding struct {
+	Kind       string `json:"kind"`
+	Annotation string `json:"annotation"`
+	Value      string `json:"value"`
+	Message    string `json:"message"`
+}
+
+// consulTemplateAnnotations only take effect if consul-template is injected.
+var consulTemplateAnnotations = []string{
+	common.VaultConsulTemplateImageAnnotation,
+	common.VaultConsulTemplateOnceAnnotation,
+	common.VaultConsulTemplatePullPolicyAnnotation,
+	common.VaultConsulTemplateShareProcessNamespaceAnnotation,
+	common.VaultConsulTemplateCPUAnnotation,
+	common.VaultConsulTemplateMemoryAnnotation,
+	common.VaultConsuleTemplateInjectInInitcontainersAnnotation,
+}
+
+// vaultAgentAnnotations only take effect if vault-agent is injected.
+var vaultAgentAnnotations = []string{
+	common.VaultAgentOnceAnnotation,
+	common.VaultAgentShareProcessNamespaceAnnotation,
+	common.VaultAgentCPUAnnotation,
+	common.VaultAgentCPULimitAnnotation,
+	common.VaultAgentCPURequestAnnotation,
+	common.VaultAgentMemoryAnnotation,
+	common.VaultAgentMemoryLimitAnnotation,
+	common.VaultAgentMemoryRequestAnnotation,
+	common.VaultAgentEnvVariablesAnnotation,
+}
+
+// vaultClientAnnotations are the only annotations taking effect on objects other than pods,
+// as these objects are mutated by the Vault client of the webhook itself.
+var vaultClientAnnotations = []string{
+	common.MutateAnnotation,
+	common.VaultAddrAnnotation,
+	common.VaultRoleAnnotation,
+	common.VaultPathAnnotation,
+	common.VaultAuthMethodAnnotation,
+	common.VaultSkipVerifyAnnotation,
+	common.VaultTLSSecretAnnotation,
+	common.VaultTLSClientCertAnnotation,
+	common.VaultServiceaccountAnnotation,
+	common.VaultNamespaceAnnotation,
+	common.VaultAppRoleSecretAnnotation,
+	common.VaultAppRolePathAnnotation,
+	common.VaultCertAuthSecretAnnotation,
+	common.VaultCertAuthPathAnnotation,
+	common.TransitKeyIDAnnotation,
+	common.TransitPathAnnotation,
+	common.TransitBatchSizeAnnotation,
+}
+
+// recordedAnnotations are written by the webhook itself, recording the outcome of a mutation.
+var recordedAnnotations = []string{
+	common.MutatedAnnotation,
+	common.MutationErrorAnnotation,
+	common.VaultSecretPathsAnnotation,
+	common.VaultSecretVersionsAnnotation,
+	common.VaultSecretsRotatedAtAnnotation,
+	common.VaultSecretsHashAnnotation,
+	common.VaultSecretTemplatesAnnotation,
+	common.VaultSecretTemplatesSignatureAnnotation,
+	common.VaultLeaseIDsAnnotation,
+	common.VaultLeaseIDsSignatureAnnotation,
+	common.VaultLeaseTokenAccessorAnnotation,
+	common.VaultSupersededLeaseTokensAnnotation,
+	common.VaultLeaseTTLAnnotation,
+	common.VaultLeaseRenewedAtAnnotation,
+}
+
+// secretSourceAnnotations only take effect on Secrets.
+var secretSourceAnnotations = []string{
+	common.VaultSecretSourceAnnotation,
+	common.VaultSecretSourceTTLAnnotation,
+	common.VaultSecretSourceCommonNameAnnotation,
+}
+
+// LintAnnotations reports every webhook annotation of an object that is unknown,
+// deprecated, malformed or has no effect on it. Workloads are checked through the
+// annotations of their pod template, like the webhook configures them.
+// Findings are sorted by annotation.
+func LintAnnotations(obj metav1.Object) []AnnotationFinding {
+	configObj := obj
+	if podTemplate := podTemplateSpec(obj); podTemplate != nil {
+		configObj = templatePod(obj, podTemplate)
+	}
+
+	annotations := map[string]string{}
+	for key, value := range configObj.GetAnnotations() {
+		if strings.HasPrefix(key, common.AnnotationPrefix) {
+			annotations[key] = value
+		}
+	}
+
+	var findings []AnnotationFinding
+	report := func(kind, annotation, format string, args ...any) {
+		findings = append(findings, AnnotationFinding{
+			Kind:       kind,
+			Annotation: annotation,
+			Value:      annotations[annotation],
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, annotation := range slices.Sorted(maps.Keys(annotations)) {
+		if !slices.Contains(common.Annotations, annotation) && !strings.HasPrefix(annotation, common.ContainerEntrypointAnnotationPrefix) {
+			report(LintUnknown, annotation, "annotation %s is unknown to the webhook", annotation)
+		}
+
+		if replacement, ok := common.DeprecatedAnnotations[annotation]; ok {
+			report(LintDeprecated, annotation, "annotation %s is deprecated, use %s instead", annotation, replacement)
+		}
+	}
+
+	if annotations[common.MutateAnnotation] == "skip" {
+		for _, annotation := range slices.Sorted(maps.Keys(annotations)) {
+			if annotation != common.MutateAnnotation && slices.Contains(common.Annotations, annotation) {
+				report(LintIneffective, annotation, "annotation %s has no effect, the object is not mutated as %s is skip", annotation, common.MutateAnnotation)
+			}
+		}
+
+		return sortFindings(findings)
+	}
+
+	validator := &annotationValidator{}
+	if _, err := parseVaultConfigAnnotations(configObj, &model.AdmissionReview{Namespace: obj.GetNamespace()}, nil, nil, validator); err != nil {
+		report(LintMalformed, common.VaultAddrAnnotation, "annotation %s is invalid: %s", common.VaultAddrAnnotation, err)
+	}
+
+	for _, annotationErr := range validator.errs {
+		report(LintMalformed, annotationErr.Annotation, "annotation %s is malformed: %s", annotationErr.Annotation, annotationErr.Err)
+	}
+
+	if _, ok := annotations[common.MutationFailurePolicyAnnotation]; ok {
+		if _, isNamespace := configObj.(*corev1.Namespace); !isNamespace {
+			report(LintIneffective, common.MutationFailurePolicyAnnotation, "annotation %s only has an effect on namespaces", common.MutationFailurePolicyAnnotation)
+		}
+	}
+
+	if _, isSecret := configObj.(*corev1.Secret); isSecret {
+		if ttl, ok := annotations[common.VaultSecretSourceTTLAnnotation]; ok {
+			if _, err := time.ParseDuration(ttl); err != nil {
+				report(LintMalformed, common.VaultSecretSourceTTLAnnotation, "annotation %s is malformed: %s", common.VaultSecretSourceTTLAnnotation, err)
+			}
+		}
+	} else {
+		for _, annotation := range secretSourceAnnotations {
+			if _, ok := annotations[annotation]; ok {
+				report(LintIneffective, annotation, "annotation %s only has an effect on Secrets", annotation)
+			}
+		}
+	}
+
+	switch pod := configObj.(type) {
+	case *corev1.Pod:
+		for _, annotation := range slices.Sorted(maps.Keys(annotations)) {
+			containerName, ok := strings.CutPrefix(annotation, common.ContainerEntrypointAnnotationPrefix)
+			if ok && !slices.ContainsFunc(slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers), func(container corev1.Container) bool {
+				return container.Name == containerName
+			}) {
+				report(LintIneffective, annotation, "annotation %s has no effect, there is no container named %s", annotation, containerName)
+			}
+		}
+
+		if _, ok := annotations[common.VaultConsulTemplateConfigmapAnnotation]; !ok {
+			for _, annotation := range consulTemplateAnnotations {
+				if _, ok := annotations[annotation]; ok {
+					report(LintIneffective, annotation, "annotation %s has no effect without %s", annotation, common.VaultConsulTemplateConfigmapAnnotation)
+				}
+			}
+		}
+
+		if _, ok := annotations[common.VaultAgentConfigmapAnnotation]; !ok {
+			for _, annotation := range vaultAgentAnnotations {
+				if _, ok := annotations[annotation]; ok {
+					report(LintIneffective, annotation, "annotation %s has no effect without %s", annotation, common.VaultAgentConfigmapAnnotation)
+				}
+			}
+		}
+	case *corev1.Namespace:
+		// Namespace annotations are the defaults of every kind of object in the namespace
+	default:
+		for _, annotation := range slices.Sorted(maps.Keys(annotations)) {
+			if slices.Contains(common.Annotations, annotation) && !slices.Contains(vaultClientAnnotations, annotation) &&
+				!slices.Contains(recordedAnnotations, annotation) && !slices.Contains(secretSourceAnnotations, annotation) &&
+				annotation != common.MutationFailurePolicyAnnotation {
+				report(LintIneffective, annotation, "annotation %s only has an effect on pods and workloads", annotation)
+			}
+			if strings.HasPrefix(annotation, common.ContainerEntrypointAnnotationPrefix) {
+				report(LintIneffective, annotation, "annotation %s only has an effect on pods and workloads", annotation)
+			}
+		}
+	}
+
+	return sortFindings(findings)
+}
+
+func sortFindings(findings []AnnotationFinding) []AnnotationFinding {
+	slices.SortStableFunc(findings, func(a, b AnnotationFinding) int {
+		return strings.Compare(a.Annotation, b.Annotation)
+	})
+
+	return findings
+}
@@ -0,0 +1,180 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestLintAnnotations(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	type finding struct {
+		kind       string
+		annotation string
+	}
+
+	tests := []struct {
+		name         string
+		obj          metav1.Object
+		wantFindings []finding
+	}{
+		{
+			name: "valid pod",
+			obj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultAddrAnnotation:                    "https://vault:8200",
+				common.VaultConsulTemplateConfigmapAnnotation: "my-template",
+				common.VaultConsulTemplateOnceAnnotation:      "true",
+				"app.kubernetes.io/name":                      "my-app",
+			}}},
+		},
+		{
+			name: "unknown, deprecated and malformed annotations",
+			obj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.AnnotationPrefix + "vault-adr":                 "https://vault:8200",
+				common.VaultAgentConfigmapAnnotation:                  "my-agent",
+				common.VaultAgentCPUAnnotation:                        "100m",
+				common.VaultEnvDelayAnnotation:                        "10",
+				common.VaultConsuleTemplateSecretsMountPathAnnotation: "/secrets",
+			}}},
+			wantFindings: []finding{
+				{LintUnknown, common.AnnotationPrefix + "vault-adr"},
+				{LintDeprecated, common.VaultAgentCPUAnnotation},
+				{LintDeprecated, common.VaultConsuleTemplateSecretsMountPathAnnotation},
+				{LintMalformed, common.VaultEnvDelayAnnotation},
+			},
+		},
+		{
+			name: "consul-template and vault-agent settings without a ConfigMap",
+			obj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultConsulTemplateOnceAnnotation: "true",
+				common.VaultAgentOnceAnnotation:          "true",
+			}}},
+			wantFindings: []finding{
+				{LintIneffective, common.VaultAgentOnceAnnotation},
+				{LintIneffective, common.VaultConsulTemplateOnceAnnotation},
+			},
+		},
+		{
+			name: "workloads are linted through their pod template",
+			obj: &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					common.VaultConsulTemplateOnceAnnotation: "true",
+				}},
+			}}},
+			wantFindings: []finding{
+				{LintIneffective, common.VaultConsulTemplateOnceAnnotation},
+			},
+		},
+		{
+			name: "pod annotations on a Secret",
+			obj: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultRoleAnnotation:      "my-role",
+				common.VaultEnvDaemonAnnotation: "true",
+			}}},
+			wantFindings: []finding{
+				{LintIneffective, common.VaultEnvDaemonAnnotation},
+			},
+		},
+		{
+			name: "annotations recorded by the webhook on a Secret",
+			obj: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultSecretPathsAnnotation: "secret/data/db",
+				common.VaultSecretsHashAnnotation: "sha256:0123",
+			}}},
+		},
+		{
+			name: "secret source annotations",
+			obj: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultSecretSourceAnnotation:    "pki/issue/web",
+				common.VaultSecretSourceTTLAnnotation: "1 day",
+			}}},
+			wantFindings: []finding{
+				{LintMalformed, common.VaultSecretSourceTTLAnnotation},
+			},
+		},
+		{
+			name: "secret source annotation on a pod",
+			obj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultSecretSourceAnnotation: "pki/issue/web",
+			}}},
+			wantFindings: []finding{
+				{LintIneffective, common.VaultSecretSourceAnnotation},
+			},
+		},
+		{
+			name: "container entrypoint annotations",
+			obj: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					common.ContainerEntrypointAnnotationPrefix + "app":     `{"entrypoint": ["/app"], "cmd": ["serve"]}`,
+					common.ContainerEntrypointAnnotationPrefix + "worker":  `["/worker"]`,
+					common.ContainerEntrypointAnnotationPrefix + "sidecar": `{"entrypoint": ["/sidecar"]}`,
+				}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "worker"}}},
+			},
+			wantFindings: []finding{
+				{LintIneffective, common.ContainerEntrypointAnnotationPrefix + "sidecar"},
+				{LintMalformed, common.ContainerEntrypointAnnotationPrefix + "worker"},
+			},
+		},
+		{
+			name: "mutation failure policy on a pod",
+			obj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.MutationFailurePolicyAnnotation: MutationFailurePolicyIgnore,
+			}}},
+			wantFindings: []finding{
+				{LintIneffective, common.MutationFailurePolicyAnnotation},
+			},
+		},
+		{
+			name: "namespace annotations apply to every kind of object",
+			obj: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultEnvDaemonAnnotation:        "true",
+				common.MutationFailurePolicyAnnotation: MutationFailurePolicyIgnore,
+			}}},
+		},
+		{
+			name: "skipped object",
+			obj: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.MutateAnnotation:    "skip",
+				common.VaultRoleAnnotation: "my-role",
+			}}},
+			wantFindings: []finding{
+				{LintIneffective, common.VaultRoleAnnotation},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var findings []finding
+			for _, f := range LintAnnotations(tt.obj) {
+				assert.NotEmpty(t, f.Message)
+				findings = append(findings, finding{f.Kind, f.Annotation})
+			}
+
+			assert.Equal(t, tt.wantFindings, findings)
+		})
+	}
+}
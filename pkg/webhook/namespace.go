@@ -0,0 +1,116 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// WatchNamespaceDefaults starts watching namespaces and blocks until the cache
+// is synced. Once it returns, the webhook annotations of a namespace are used as
+// defaults for the objects in it, and the objects' own annotations override them.
+func (mw *MutatingWebhook) WatchNamespaceDefaults(ctx context.Context) error {
+	if err := mw.watchNamespaces(ctx); err != nil {
+		return err
+	}
+
+	mw.namespaceDefaults = true
+
+	return nil
+}
+
+// watchNamespaces starts a namespace informer shared by the features that
+// need namespace metadata. It is a no-op if the informer is already running.
+func (mw *MutatingWebhook) watchNamespaces(ctx context.Context) error {
+	if mw.namespaces != nil {
+		return nil
+	}
+
+	factory := informers.NewSharedInformerFactory(mw.k8sClient, 0)
+	namespaces := factory.Core().V1().Namespaces()
+	informer := namespaces.Informer()
+
+	factory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("failed to sync namespace cache")
+	}
+
+	mw.namespaces = namespaces.Lister()
+
+	return nil
+}
+
+// namespaceLabels returns the labels of the given namespace. Namespaces missing
+// from the cache still get the immutable kubernetes.io/metadata.name label.
+func (mw *MutatingWebhook) namespaceLabels(name string) (labels.Set, error) {
+	namespace, err := mw.namespaces.Get(name)
+	if apierrors.IsNotFound(err) {
+		return labels.Set{corev1.LabelMetadataName: name}, nil
+	}
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to get namespace")
+	}
+
+	return namespace.GetLabels(), nil
+}
+
+// namespaceAnnotations returns the webhook annotations of the given namespace,
+// or nil if namespace defaults are disabled or the namespace is not cached.
+func (mw *MutatingWebhook) namespaceAnnotations(name string) (map[string]string, error) {
+	if !mw.namespaceDefaults || name == "" {
+		return nil, nil
+	}
+
+	namespace, err := mw.namespaces.Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to get namespace")
+	}
+
+	annotations := map[string]string{}
+	for key, value := range namespace.GetAnnotations() {
+		if strings.HasPrefix(key, common.AnnotationPrefix) {
+			annotations[key] = value
+		}
+	}
+
+	return annotations, nil
+}
+
+// mergeAnnotations returns the namespace annotations overridden by the object's own annotations.
+func mergeAnnotations(namespaceAnnotations, objectAnnotations map[string]string) map[string]string {
+	if len(namespaceAnnotations) == 0 {
+		return objectAnnotations
+	}
+
+	annotations := maps.Clone(namespaceAnnotations)
+	maps.Copy(annotations, objectAnnotations)
+
+	return annotations
+}
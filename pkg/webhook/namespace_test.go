@@ -0,0 +1,125 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestNamespaceDefaults(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+	viper.Set("vault_addr_allowlist", "https://vault.team-a.svc:8200")
+
+	k8sClient := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+			Annotations: map[string]string{
+				common.VaultRoleAnnotation: "team-a",
+				common.VaultPathAnnotation: "team-a-k8s",
+				common.VaultAddrAnnotation: "https://vault.team-a.svc:8200",
+				"example.com/unrelated":    "ignored",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "team-b",
+			Annotations: map[string]string{
+				common.VaultAddrAnnotation: "https://vault.elsewhere:8200",
+			},
+		}},
+	)
+
+	mw := &MutatingWebhook{k8sClient: k8sClient}
+	require.NoError(t, mw.WatchNamespaceDefaults(context.Background()))
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		wantRole    string
+		wantPath    string
+		wantAddr    string
+		wantErr     bool
+	}{
+		{
+			name:      "namespace annotations override the global defaults",
+			namespace: "team-a",
+			wantRole:  "team-a",
+			wantPath:  "team-a-k8s",
+			wantAddr:  "https://vault.team-a.svc:8200",
+		},
+		{
+			name:        "object annotations override the namespace annotations",
+			namespace:   "team-a",
+			annotations: map[string]string{common.VaultRoleAnnotation: "my-app"},
+			wantRole:    "my-app",
+			wantPath:    "team-a-k8s",
+			wantAddr:    "https://vault.team-a.svc:8200",
+		},
+		{
+			name:      "unknown namespace uses the global defaults",
+			namespace: "unknown",
+			wantRole:  "default",
+			wantPath:  "kubernetes",
+			wantAddr:  "https://vault:8200",
+		},
+		{
+			name:      "namespace address is validated like an object address",
+			namespace: "team-b",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespaceAnnotations, err := mw.namespaceAnnotations(tt.namespace)
+			require.NoError(t, err)
+			assert.NotContains(t, namespaceAnnotations, "example.com/unrelated")
+
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			vaultConfig, _, err := parseVaultConfig(secret, &model.AdmissionReview{Namespace: tt.namespace}, nil, namespaceAnnotations)
+			if tt.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantRole, vaultConfig.Role)
+			assert.Equal(t, tt.wantPath, vaultConfig.Path)
+			assert.Equal(t, tt.wantAddr, vaultConfig.Addr)
+			assert.Equal(t, tt.annotations, secret.Annotations, "object annotations must not be modified")
+		})
+	}
+}
+
+func TestNamespaceDefaultsDisabled(t *testing.T) {
+	mw := &MutatingWebhook{}
+
+	namespaceAnnotations, err := mw.namespaceAnnotations("default")
+	require.NoError(t, err)
+	assert.Nil(t, namespaceAnnotations)
+}
@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"strings"
 
-	"emperror.dev/errors"
 	injector "github.com/bank-vaults/vault-sdk/injector/vault"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -77,7 +76,7 @@ func sliceIterator(s []interface{}) iterator {
 	return c
 }
 
-func traverseObject(ctx context.Context, o interface{}, secretInjector *injector.SecretInjector) error {
+func traverseObject(ctx context.Context, o interface{}, secretResolver SecretResolver) error {
 	var iterator iterator
 
 	switch value := o.(type) {
@@ -93,7 +92,7 @@ func traverseObject(ctx context.Context, o interface{}, secretInjector *injector
 		switch s := e.Get().(type) {
 		case string:
 			if common.HasVaultPrefix(s) {
-				dataFromVault, err := secretInjector.GetDataFromVaultWithContext(ctx, map[string]string{"data": s})
+				dataFromVault, err := secretResolver.GetDataFromVaultWithContext(ctx, map[string]string{"data": s})
 				if err != nil {
 					return err
 				}
@@ -102,7 +101,7 @@ func traverseObject(ctx context.Context, o interface{}, secretInjector *injector
 			} else if injector.HasInlineVaultDelimiters(s) {
 				dataFromVault := s
 				for _, vaultSecretReference := range injector.FindInlineVaultDelimiters(s) {
-					mapData, err := secretInjector.GetDataFromVaultWithContext(ctx, map[string]string{"data": vaultSecretReference[1]})
+					mapData, err := secretResolver.GetDataFromVaultWithContext(ctx, map[string]string{"data": vaultSecretReference[1]})
 					if err != nil {
 						return err
 					}
@@ -111,7 +110,7 @@ func traverseObject(ctx context.Context, o interface{}, secretInjector *injector
 				e.Set(dataFromVault)
 			}
 		case map[string]interface{}, []interface{}:
-			err := traverseObject(ctx, e.Get(), secretInjector)
+			err := traverseObject(ctx, e.Get(), secretResolver)
 			if err != nil {
 				return err
 			}
@@ -124,19 +123,12 @@ func traverseObject(ctx context.Context, o interface{}, secretInjector *injector
 func (mw *MutatingWebhook) MutateObject(ctx context.Context, object *unstructured.Unstructured, vaultConfig VaultConfig) error {
 	mw.logger.Debug(fmt.Sprintf("mutating object: %s.%s", object.GetNamespace(), object.GetName()))
 
-	vaultClient, err := mw.newVaultClient(ctx, vaultConfig)
+	secretResolver, release, err := mw.secretResolverFor(ctx, vaultConfig)
 	if err != nil {
-		return errors.Wrap(err, "failed to create vault client")
+		return err
 	}
 
-	defer vaultClient.Close()
+	defer release()
 
-	config := injector.Config{
-		TransitKeyID:     vaultConfig.TransitKeyID,
-		TransitPath:      vaultConfig.TransitPath,
-		TransitBatchSize: vaultConfig.TransitBatchSize,
-	}
-	secretInjector := injector.NewSecretInjector(config, vaultClient, nil, logger)
-
-	return traverseObject(ctx, object.Object, &secretInjector)
+	return traverseObject(ctx, object.Object, secretResolver)
 }
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -27,6 +28,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
 	kubeVer "k8s.io/apimachinery/pkg/version"
 
 	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
@@ -52,8 +54,15 @@ auto_auth {
         }
 }`
 	VaultEnvVolumeName = "vault-env"
+
+	vaultTokenVolumeName = "vault-token"
+	vaultTokenMountPath  = "/vault/token/"
 )
 
+// nativeSidecarsMinVersion is the first Kubernetes version that enables
+// native sidecar containers by default.
+var nativeSidecarsMinVersion = version.MajorMinor(1, 29)
+
 func (mw *MutatingWebhook) MutatePod(ctx context.Context, pod *corev1.Pod, vaultConfig VaultConfig, dryRun bool) error {
 	mw.logger.Debug("Successfully connected to the API")
 
@@ -84,6 +93,13 @@ func (mw *MutatingWebhook) MutatePod(ctx context.Context, pod *corev1.Pod, vault
 		mw.logger.Debug("No pod containers were mutated")
 	}
 
+	if vaultConfig.Token != "" && vaultConfig.TokenSecret != "" &&
+		(initContainersMutated || containersMutated || vaultConfig.CtConfigMap != "" || vaultConfig.AgentConfigMap != "") {
+		if err := mw.ensureVaultTokenSecret(ctx, vaultConfig, dryRun); err != nil {
+			return err
+		}
+	}
+
 	containerEnvVars := []corev1.EnvVar{
 		{
 			Name:  "VAULT_ADDR",
@@ -95,12 +111,7 @@ func (mw *MutatingWebhook) MutatePod(ctx context.Context, pod *corev1.Pod, vault
 		},
 	}
 
-	if vaultConfig.Token != "" {
-		containerEnvVars = append(containerEnvVars, corev1.EnvVar{
-			Name:  "VAULT_TOKEN",
-			Value: vaultConfig.Token,
-		})
-	}
+	containerEnvVars = append(containerEnvVars, getVaultTokenEnvVars(vaultConfig, false)...)
 
 	containerVolMounts := []corev1.VolumeMount{
 		{
@@ -116,16 +127,23 @@ func (mw *MutatingWebhook) MutatePod(ctx context.Context, pod *corev1.Pod, vault
 			volumeName = "vault-env-tls"
 		}
 
-		containerEnvVars = append(containerEnvVars, corev1.EnvVar{
-			Name:  "VAULT_CACERT",
-			Value: mountPath + "ca.crt",
-		})
+		containerEnvVars = append(containerEnvVars, getVaultTLSEnvVars(mountPath, vaultConfig)...)
 		containerVolMounts = append(containerVolMounts, corev1.VolumeMount{
 			Name:      volumeName,
 			MountPath: mountPath,
 		})
 	}
 
+	if vaultConfig.NativeSidecars && !mw.supportsNativeSidecars() {
+		mw.logger.Info("Kubernetes API server does not support native sidecars, injecting regular containers")
+		vaultConfig.NativeSidecars = false
+	}
+
+	// Long-running containers injected as native sidecars, they are
+	// placed right after the init containers created by the webhook.
+	var sidecars []corev1.Container
+	var webhookInitContainers []corev1.Container
+
 	if vaultConfig.CtConfigMap != "" {
 		mw.logger.Debug("Consul Template config found")
 
@@ -150,7 +168,12 @@ func (mw *MutatingWebhook) MutatePod(ctx context.Context, pod *corev1.Pod, vault
 			pod.Spec.ShareProcessNamespace = &shareProcessNamespace
 		}
 		if !vaultConfig.CtOnce {
-			pod.Spec.Containers = append(getContainers(pod.Spec.SecurityContext, vaultConfig, containerEnvVars, containerVolMounts), pod.Spec.Containers...)
+			ctContainers := getContainers(pod.Spec.SecurityContext, vaultConfig, containerEnvVars, containerVolMounts)
+			if vaultConfig.NativeSidecars {
+				sidecars = append(sidecars, asNativeSidecars(ctContainers, vaultConfig)...)
+			} else {
+				pod.Spec.Containers = append(ctContainers, pod.Spec.Containers...)
+			}
 		} else {
 			if vaultConfig.CtInjectInInitcontainers {
 				mw.addSecretsVolToContainers(vaultConfig, pod.Spec.InitContainers)
@@ -186,7 +209,8 @@ func (mw *MutatingWebhook) MutatePod(ctx context.Context, pod *corev1.Pod, vault
 			}
 		}
 
-		pod.Spec.InitContainers = append(getInitContainers(pod.Spec.Containers, pod.Spec.SecurityContext, vaultConfig, initContainersMutated, containersMutated, containerEnvVars, containerVolMounts), pod.Spec.InitContainers...)
+		webhookInitContainers = getInitContainers(pod.Spec.Containers, pod.Spec.SecurityContext, vaultConfig, initContainersMutated, containersMutated, containerEnvVars, containerVolMounts)
+		pod.Spec.InitContainers = append(webhookInitContainers, pod.Spec.InitContainers...)
 		mw.logger.Debug("Successfully appended pod init containers to spec")
 
 		pod.Spec.Volumes = append(pod.Spec.Volumes, mw.getVolumes(pod.Spec.Volumes, agentConfigMapName, vaultConfig)...)
@@ -220,15 +244,79 @@ func (mw *MutatingWebhook) MutatePod(ctx context.Context, pod *corev1.Pod, vault
 			shareProcessNamespace := true
 			pod.Spec.ShareProcessNamespace = &shareProcessNamespace
 		}
-		pod.Spec.Containers = append(getAgentContainers(pod.Spec.Containers, pod.Spec.SecurityContext, vaultConfig, containerEnvVars, containerVolMounts), pod.Spec.Containers...)
+		agentContainers := getAgentContainers(pod.Spec.Containers, pod.Spec.SecurityContext, vaultConfig, containerEnvVars, containerVolMounts)
+		if vaultConfig.NativeSidecars {
+			sidecars = append(sidecars, asNativeSidecars(agentContainers, vaultConfig)...)
+		} else {
+			pod.Spec.Containers = append(agentContainers, pod.Spec.Containers...)
+		}
 
 		mw.logger.Debug("Successfully appended pod containers to spec")
 	}
 
+	if len(sidecars) > 0 {
+		pod.Spec.InitContainers = slices.Insert(pod.Spec.InitContainers, len(webhookInitContainers), sidecars...)
+
+		mw.logger.Debug("Successfully appended native sidecars to pod init containers")
+	}
+
 	return nil
 }
 
+// supportsNativeSidecars reports whether the Kubernetes API server runs
+// native sidecar containers, i.e. init containers with restartPolicy Always.
+func (mw *MutatingWebhook) supportsNativeSidecars() bool {
+	info, err := mw.k8sClient.Discovery().ServerVersion()
+	if err != nil {
+		mw.logger.Warn(fmt.Sprintf("failed to get Kubernetes API version: %s", err))
+
+		return false
+	}
+
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		mw.logger.Warn(fmt.Sprintf("failed to parse Kubernetes API version %q: %s", info.GitVersion, err))
+
+		return false
+	}
+
+	return serverVersion.AtLeast(nativeSidecarsMinVersion)
+}
+
+// asNativeSidecars turns long-running containers into native sidecars. The startup
+// probe holds back the containers after them until the first secrets are rendered.
+// It runs a shell in the sidecar, so it has to be disabled for images without one,
+// like distroless builds of vault-agent or consul-template.
+func asNativeSidecars(containers []corev1.Container, vaultConfig VaultConfig) []corev1.Container {
+	restartPolicy := corev1.ContainerRestartPolicyAlways
+
+	for i := range containers {
+		containers[i].RestartPolicy = &restartPolicy
+		if !vaultConfig.NativeSidecarsStartupProbe {
+			continue
+		}
+
+		containers[i].StartupProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{
+					Command: []string{"sh", "-c", fmt.Sprintf("test -n \"$(ls -A '%s')\"", vaultConfig.ConfigfilePath)},
+				},
+			},
+			PeriodSeconds:    1,
+			FailureThreshold: 300,
+		}
+	}
+
+	return containers
+}
+
+// isPodAlreadyMutated reports whether the pod was mutated already, either
+// directly or through the pod template of its workload.
 func isPodAlreadyMutated(pod *corev1.Pod) bool {
+	if pod.Annotations[common.MutatedAnnotation] == "true" {
+		return true
+	}
+
 	for _, volume := range pod.Spec.Volumes {
 		if volume.Name == VaultEnvVolumeName {
 			return true
@@ -270,13 +358,26 @@ func (mw *MutatingWebhook) mutateContainers(ctx context.Context, containers []co
 			continue
 		}
 
+		// env vars from ConfigMaps and Secrets are only known at this point
+		if mw.accessPolicies != nil {
+			var references []vaultReference
+			for _, env := range envVars {
+				source := fmt.Sprintf("env var %s of container %s", env.Name, container.Name)
+				references = append(references, vaultReferences(source, env.Value)...)
+			}
+
+			if err := mw.authorizeVaultAccess(vaultConfig.ObjectNamespace, podServiceAccount(podSpec), vaultConfig, references); err != nil {
+				return false, err
+			}
+		}
+
 		mutated = true
 
 		args := container.Command
 
 		// the container has no explicitly specified command
 		if len(args) == 0 {
-			imageConfig, err := mw.registry.GetImageConfig(ctx, mw.k8sClient, vaultConfig.ObjectNamespace, vaultConfig.RegistrySkipVerify, &container, podSpec) //nolint:gosec
+			imageConfig, err := mw.imageConfigFor(ctx, &container, podSpec, vaultConfig) //nolint:gosec
 			if err != nil {
 				return false, err
 			}
@@ -363,10 +464,14 @@ func (mw *MutatingWebhook) mutateContainers(ctx context.Context, containers []co
 			},
 		}...)
 
-		if vaultConfig.Token != "" {
-			container.Env = append(container.Env, corev1.EnvVar{
-				Name:  "VAULT_TOKEN",
-				Value: vaultConfig.Token,
+		// vault-env reads VAULT_TOKEN_FILE, unless it is already pointed to the token of vault-agent
+		tokenFile := vaultConfig.TokenAsFile && !vaultConfig.UseAgent && vaultConfig.TokenAuthMount == ""
+		container.Env = append(container.Env, getVaultTokenEnvVars(vaultConfig, tokenFile)...)
+		if tokenFile && vaultConfig.Token != "" && vaultConfig.TokenSecret != "" {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      vaultTokenVolumeName,
+				MountPath: vaultTokenMountPath,
+				ReadOnly:  true,
 			})
 		}
 
@@ -423,10 +528,7 @@ func (mw *MutatingWebhook) mutateContainers(ctx context.Context, containers []co
 				volumeName = "vault-env-tls"
 			}
 
-			container.Env = append(container.Env, corev1.EnvVar{
-				Name:  "VAULT_CACERT",
-				Value: mountPath + "ca.crt",
-			})
+			container.Env = append(container.Env, getVaultTLSEnvVars(mountPath, vaultConfig)...)
 			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
 				Name:      volumeName,
 				MountPath: mountPath,
@@ -540,6 +642,20 @@ func (mw *MutatingWebhook) getVolumes(existingVolumes []corev1.Volume, agentConf
 			volumeName = "vault-env-tls"
 		}
 
+		items := []corev1.KeyToPath{{
+			Key:  "ca.crt",
+			Path: "ca.crt",
+		}}
+		if vaultConfig.TLSClientCert {
+			items = append(items, corev1.KeyToPath{
+				Key:  corev1.TLSCertKey,
+				Path: corev1.TLSCertKey,
+			}, corev1.KeyToPath{
+				Key:  corev1.TLSPrivateKeyKey,
+				Path: corev1.TLSPrivateKeyKey,
+			})
+		}
+
 		volumes = append(volumes, corev1.Volume{
 			Name: volumeName,
 			VolumeSource: corev1.VolumeSource{
@@ -549,9 +665,28 @@ func (mw *MutatingWebhook) getVolumes(existingVolumes []corev1.Volume, agentConf
 							LocalObjectReference: corev1.LocalObjectReference{
 								Name: vaultConfig.TLSSecret,
 							},
+							Items: items,
+						},
+					}},
+				},
+			},
+		})
+	}
+	if vaultConfig.Token != "" && vaultConfig.TokenSecret != "" && vaultConfig.TokenAsFile {
+		mw.logger.Debug("Add vault token volume to podspec")
+
+		volumes = append(volumes, corev1.Volume{
+			Name: vaultTokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						Secret: &corev1.SecretProjection{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: vaultConfig.TokenSecret,
+							},
 							Items: []corev1.KeyToPath{{
-								Key:  "ca.crt",
-								Path: "ca.crt",
+								Key:  vaultConfig.TokenSecretKey,
+								Path: "token",
 							}},
 						},
 					}},
@@ -559,6 +694,7 @@ func (mw *MutatingWebhook) getVolumes(existingVolumes []corev1.Volume, agentConf
 			},
 		})
 	}
+
 	if vaultConfig.CtConfigMap != "" {
 		mw.logger.Debug("Add consul template volumes to podspec")
 
@@ -637,6 +773,111 @@ func hasTLSVolume(volumes []corev1.Volume) bool {
 	return false
 }
 
+// getVaultTLSEnvVars points the Vault clients of the injected and mutated containers
+// to the files of the Vault TLS Secret mounted at mountPath.
+func getVaultTLSEnvVars(mountPath string, vaultConfig VaultConfig) []corev1.EnvVar {
+	envVars := []corev1.EnvVar{
+		{
+			Name:  "VAULT_CACERT",
+			Value: mountPath + "ca.crt",
+		},
+	}
+
+	if vaultConfig.TLSClientCert {
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  "VAULT_CLIENT_CERT",
+			Value: mountPath + corev1.TLSCertKey,
+		}, corev1.EnvVar{
+			Name:  "VAULT_CLIENT_KEY",
+			Value: mountPath + corev1.TLSPrivateKeyKey,
+		})
+	}
+
+	return envVars
+}
+
+// getVaultTokenEnvVars passes the static Vault token to a container. Without a
+// token Secret the token is set in plain text, otherwise it is referenced from
+// the Secret, or from the projected token file if tokenFile is set.
+func getVaultTokenEnvVars(vaultConfig VaultConfig, tokenFile bool) []corev1.EnvVar {
+	if vaultConfig.Token == "" {
+		return nil
+	}
+
+	if vaultConfig.TokenSecret == "" {
+		return []corev1.EnvVar{{
+			Name:  "VAULT_TOKEN",
+			Value: vaultConfig.Token,
+		}}
+	}
+
+	if tokenFile {
+		return []corev1.EnvVar{{
+			Name:  "VAULT_TOKEN_FILE",
+			Value: vaultTokenMountPath + "token",
+		}}
+	}
+
+	return []corev1.EnvVar{{
+		Name: "VAULT_TOKEN",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: vaultConfig.TokenSecret,
+				},
+				Key: vaultConfig.TokenSecretKey,
+			},
+		},
+	}}
+}
+
+// ensureVaultTokenSecret checks that the Secret holding the static Vault token
+// exists in the namespace of the pod, and creates it if configured to do so.
+// An existing Secret is never overwritten.
+func (mw *MutatingWebhook) ensureVaultTokenSecret(ctx context.Context, vaultConfig VaultConfig, dryRun bool) error {
+	secrets := mw.k8sClient.CoreV1().Secrets(vaultConfig.ObjectNamespace)
+
+	secret, err := secrets.Get(ctx, vaultConfig.TokenSecret, metav1.GetOptions{})
+	if err == nil {
+		if _, ok := secret.Data[vaultConfig.TokenSecretKey]; !ok {
+			return errors.Errorf("Vault token Secret %s has no key %s", vaultConfig.TokenSecret, vaultConfig.TokenSecretKey)
+		}
+
+		return nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return errors.WrapIf(err, "failed to get Vault token Secret")
+	}
+
+	if !vaultConfig.TokenSecretCreate {
+		return errors.Errorf("Vault token Secret %s not found in namespace %s", vaultConfig.TokenSecret, vaultConfig.ObjectNamespace)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	_, err = secrets.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      vaultConfig.TokenSecret,
+			Namespace: vaultConfig.ObjectNamespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "vault-secrets-webhook",
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			vaultConfig.TokenSecretKey: []byte(vaultConfig.Token),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.WrapIf(err, "failed to create Vault token Secret")
+	}
+
+	return nil
+}
+
 func getServiceAccountMount(containers []corev1.Container, vaultConfig VaultConfig) (serviceAccountMount corev1.VolumeMount) {
 mountSearch:
 	for _, container := range containers {
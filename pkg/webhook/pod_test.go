@@ -17,6 +17,7 @@ package webhook
 import (
 	"context"
 	"log/slog"
+	"slices"
 	"testing"
 	"time"
 
@@ -24,6 +25,10 @@ import (
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes"
 	fake "k8s.io/client-go/kubernetes/fake"
 )
@@ -1995,3 +2000,356 @@ func Test_mutatingWebhook_mutatePod(t *testing.T) {
 		})
 	}
 }
+
+func Test_mutatingWebhook_mutatePodNativeSidecars(t *testing.T) {
+	tests := []struct {
+		name               string
+		serverVersion      string
+		vaultConfig        VaultConfig
+		wantInitContainers []string
+		wantContainers     []string
+	}{
+		{
+			name:          "Will inject vault-agent as native sidecar",
+			serverVersion: "v1.30.2",
+			vaultConfig: VaultConfig{
+				AgentConfigMap:             "config-map-test",
+				ConfigfilePath:             "/vault/secrets",
+				NativeSidecars:             true,
+				NativeSidecarsStartupProbe: true,
+			},
+			wantInitContainers: []string{"vault-agent", "my-init"},
+			wantContainers:     []string{"MyContainer"},
+		},
+		{
+			name:          "Will inject consul-template as native sidecar after the webhook init containers",
+			serverVersion: "v1.29.0-gke.1",
+			vaultConfig: VaultConfig{
+				CtConfigMap:                "config-map-test",
+				ConfigfilePath:             "/vault/secrets",
+				NativeSidecars:             true,
+				NativeSidecarsStartupProbe: true,
+			},
+			wantInitContainers: []string{"vault-agent", "consul-template", "my-init"},
+			wantContainers:     []string{"MyContainer"},
+		},
+		{
+			name:          "Will fall back to regular sidecars on older clusters",
+			serverVersion: "v1.28.5",
+			vaultConfig: VaultConfig{
+				AgentConfigMap:             "config-map-test",
+				ConfigfilePath:             "/vault/secrets",
+				NativeSidecars:             true,
+				NativeSidecarsStartupProbe: true,
+			},
+			wantInitContainers: []string{"my-init"},
+			wantContainers:     []string{"vault-agent", "MyContainer"},
+		},
+		{
+			name:          "Will inject native sidecars without a startup probe for images without a shell",
+			serverVersion: "v1.30.2",
+			vaultConfig: VaultConfig{
+				AgentConfigMap: "config-map-test",
+				ConfigfilePath: "/vault/secrets",
+				NativeSidecars: true,
+			},
+			wantInitContainers: []string{"vault-agent", "my-init"},
+			wantContainers:     []string{"MyContainer"},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			t.Parallel()
+
+			k8sClient := fake.NewClientset()
+			k8sClient.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: ttp.serverVersion}
+
+			mw := &MutatingWebhook{
+				k8sClient: k8sClient,
+				registry:  &MockRegistry{Image: v1.Config{}},
+				logger:    slog.Default(),
+			}
+
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "my-init", Image: "myimage"}},
+					Containers:     []corev1.Container{{Name: "MyContainer", Image: "myimage"}},
+				},
+			}
+
+			if err := mw.MutatePod(context.Background(), pod, ttp.vaultConfig, true); err != nil {
+				t.Fatalf("MutatingWebhook.MutatePod() error = %v", err)
+			}
+
+			if diff := cmp.Diff(ttp.wantInitContainers, containerNames(pod.Spec.InitContainers)); diff != "" {
+				t.Errorf("unexpected init containers, diff %v", diff)
+			}
+			if diff := cmp.Diff(ttp.wantContainers, containerNames(pod.Spec.Containers)); diff != "" {
+				t.Errorf("unexpected containers, diff %v", diff)
+			}
+
+			for _, container := range pod.Spec.InitContainers {
+				isSidecar := container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+				wantSidecar := container.Name == "consul-template" || (container.Name == "vault-agent" && ttp.vaultConfig.AgentConfigMap != "")
+				if isSidecar != wantSidecar {
+					t.Errorf("init container %s: native sidecar = %v, want %v", container.Name, isSidecar, wantSidecar)
+				}
+				if isSidecar && (container.StartupProbe != nil) != ttp.vaultConfig.NativeSidecarsStartupProbe {
+					t.Errorf("native sidecar %s: startup probe = %v, want %v", container.Name, container.StartupProbe != nil, ttp.vaultConfig.NativeSidecarsStartupProbe)
+				}
+			}
+		})
+	}
+}
+
+func containerNames(containers []corev1.Container) []string {
+	names := make([]string, 0, len(containers))
+	for _, container := range containers {
+		names = append(names, container.Name)
+	}
+
+	return names
+}
+
+func Test_mutatingWebhook_mutatePodTLSClientCert(t *testing.T) {
+	tests := []struct {
+		name          string
+		tlsClientCert bool
+		wantEnv       []corev1.EnvVar
+		wantItems     []corev1.KeyToPath
+	}{
+		{
+			name: "Will mount only the CA of the Vault TLS Secret",
+			wantEnv: []corev1.EnvVar{
+				{Name: "VAULT_CACERT", Value: "/vault/tls/ca.crt"},
+			},
+			wantItems: []corev1.KeyToPath{
+				{Key: "ca.crt", Path: "ca.crt"},
+			},
+		},
+		{
+			name:          "Will mount the client certificate of the Vault TLS Secret",
+			tlsClientCert: true,
+			wantEnv: []corev1.EnvVar{
+				{Name: "VAULT_CACERT", Value: "/vault/tls/ca.crt"},
+				{Name: "VAULT_CLIENT_CERT", Value: "/vault/tls/tls.crt"},
+				{Name: "VAULT_CLIENT_KEY", Value: "/vault/tls/tls.key"},
+			},
+			wantItems: []corev1.KeyToPath{
+				{Key: "ca.crt", Path: "ca.crt"},
+				{Key: "tls.crt", Path: "tls.crt"},
+				{Key: "tls.key", Path: "tls.key"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			t.Parallel()
+
+			mw := &MutatingWebhook{
+				k8sClient: fake.NewClientset(),
+				registry:  &MockRegistry{Image: v1.Config{}},
+				logger:    slog.Default(),
+			}
+
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:    "MyContainer",
+							Image:   "myimage",
+							Command: []string{"/bin/bash"},
+							Env:     []corev1.EnvVar{{Name: "myvar", Value: "vault:secrets"}},
+						},
+					},
+				},
+			}
+
+			config := vaultConfig
+			config.TLSSecret = "vault-tls"
+			config.TLSClientCert = ttp.tlsClientCert
+			config.AgentConfigMap = "config-map-test"
+			config.ConfigfilePath = "/vault/secrets"
+
+			if err := mw.MutatePod(context.Background(), pod, config, true); err != nil {
+				t.Fatalf("MutatingWebhook.MutatePod() error = %v", err)
+			}
+
+			for _, container := range pod.Spec.Containers {
+				var tlsEnv []corev1.EnvVar
+				for _, env := range container.Env {
+					if env.Name == "VAULT_CACERT" || env.Name == "VAULT_CLIENT_CERT" || env.Name == "VAULT_CLIENT_KEY" {
+						tlsEnv = append(tlsEnv, env)
+					}
+				}
+
+				if diff := cmp.Diff(ttp.wantEnv, tlsEnv); diff != "" {
+					t.Errorf("unexpected TLS env vars in container %s, diff %v", container.Name, diff)
+				}
+			}
+
+			var items []corev1.KeyToPath
+			for _, volume := range pod.Spec.Volumes {
+				if volume.Name == "vault-tls" {
+					items = volume.Projected.Sources[0].Secret.Items
+				}
+			}
+
+			if diff := cmp.Diff(ttp.wantItems, items); diff != "" {
+				t.Errorf("unexpected vault-tls volume items, diff %v", diff)
+			}
+		})
+	}
+}
+
+func Test_mutatingWebhook_mutatePodVaultTokenSecret(t *testing.T) {
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault-token", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("root")},
+	}
+
+	tests := []struct {
+		name        string
+		secrets     []runtime.Object
+		tokenSecret string
+		create      bool
+		asFile      bool
+		dryRun      bool
+		wantEnv     []corev1.EnvVar
+		wantVolume  bool
+		wantCreated bool
+		wantErr     bool
+	}{
+		{
+			name: "Will set the token in plain text without token Secret",
+			wantEnv: []corev1.EnvVar{
+				{Name: "VAULT_TOKEN", Value: "root"},
+			},
+		},
+		{
+			name:        "Will reference the token from the token Secret",
+			secrets:     []runtime.Object{tokenSecret},
+			tokenSecret: "vault-token",
+			wantEnv: []corev1.EnvVar{
+				{Name: "VAULT_TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "vault-token"},
+					Key:                  "token",
+				}}},
+			},
+		},
+		{
+			name:        "Will mount the token from the token Secret as file",
+			secrets:     []runtime.Object{tokenSecret},
+			tokenSecret: "vault-token",
+			asFile:      true,
+			wantEnv: []corev1.EnvVar{
+				{Name: "VAULT_TOKEN_FILE", Value: "/vault/token/token"},
+			},
+			wantVolume: true,
+		},
+		{
+			name:        "Will fail if the token Secret is missing",
+			tokenSecret: "vault-token",
+			wantErr:     true,
+		},
+		{
+			name:        "Will create the missing token Secret",
+			tokenSecret: "vault-token",
+			create:      true,
+			wantEnv: []corev1.EnvVar{
+				{Name: "VAULT_TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "vault-token"},
+					Key:                  "token",
+				}}},
+			},
+			wantCreated: true,
+		},
+		{
+			name:        "Will not create the missing token Secret on dry run",
+			tokenSecret: "vault-token",
+			create:      true,
+			dryRun:      true,
+			wantEnv: []corev1.EnvVar{
+				{Name: "VAULT_TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "vault-token"},
+					Key:                  "token",
+				}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ttp := tt
+		t.Run(ttp.name, func(t *testing.T) {
+			t.Parallel()
+
+			k8sClient := fake.NewClientset(ttp.secrets...)
+			mw := &MutatingWebhook{
+				k8sClient: k8sClient,
+				registry:  &MockRegistry{Image: v1.Config{}},
+				logger:    slog.Default(),
+			}
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pod", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:    "MyContainer",
+							Image:   "myimage",
+							Command: []string{"/bin/bash"},
+							Env:     []corev1.EnvVar{{Name: "myvar", Value: "vault:secrets"}},
+						},
+					},
+				},
+			}
+
+			config := vaultConfig
+			config.ObjectNamespace = "default"
+			config.Token = "root"
+			config.TokenSecret = ttp.tokenSecret
+			config.TokenSecretKey = "token"
+			config.TokenSecretCreate = ttp.create
+			config.TokenAsFile = ttp.asFile
+
+			err := mw.MutatePod(context.Background(), pod, config, ttp.dryRun)
+			if (err != nil) != ttp.wantErr {
+				t.Fatalf("MutatingWebhook.MutatePod() error = %v, wantErr %v", err, ttp.wantErr)
+			}
+			if ttp.wantErr {
+				return
+			}
+
+			container := pod.Spec.Containers[0]
+			var tokenEnv []corev1.EnvVar
+			for _, env := range container.Env {
+				if env.Name == "VAULT_TOKEN" || env.Name == "VAULT_TOKEN_FILE" {
+					tokenEnv = append(tokenEnv, env)
+				}
+			}
+
+			if diff := cmp.Diff(ttp.wantEnv, tokenEnv); diff != "" {
+				t.Errorf("unexpected token env vars, diff %v", diff)
+			}
+
+			hasVolume := slices.ContainsFunc(pod.Spec.Volumes, func(volume corev1.Volume) bool {
+				return volume.Name == "vault-token"
+			})
+			hasMount := slices.ContainsFunc(container.VolumeMounts, func(mount corev1.VolumeMount) bool {
+				return mount.Name == "vault-token"
+			})
+			if hasVolume != ttp.wantVolume || hasMount != ttp.wantVolume {
+				t.Errorf("unexpected token volume %v and mount %v, want %v", hasVolume, hasMount, ttp.wantVolume)
+			}
+
+			_, err = k8sClient.CoreV1().Secrets("default").Get(context.Background(), "vault-token", metav1.GetOptions{})
+			if created := err == nil && len(ttp.secrets) == 0; created != ttp.wantCreated {
+				t.Errorf("unexpected token Secret creation %v, want %v", created, ttp.wantCreated)
+			}
+		})
+	}
+}
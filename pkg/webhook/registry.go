@@ -22,13 +22,14 @@ import (
 	"net/http"
 	"os"
 	"slices"
+	"time"
 
 	"emperror.dev/errors"
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/patrickmn/go-cache"
 	slogmulti "github.com/samber/slog-multi"
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
@@ -85,16 +86,71 @@ type ImageRegistry interface {
 
 // Registry impl
 type Registry struct {
-	imageCache *cache.Cache
+	imageCache *imageConfigCache
+	imageTTL   time.Duration
+	// mirrors are looked up instead of the registries they mirror, with the
+	// Secrets of their CA and credentials read from the namespace of the webhook
+	mirrors   []registryMirror
+	namespace string
 }
 
-// NewRegistry creates and initializes registry
+// NewRegistry creates and initializes registry. It looks images up in their own
+// registries only, use NewRegistryInNamespace to look them up in registry_mirrors.
 func NewRegistry() ImageRegistry {
+	return newImageCacheRegistry()
+}
+
+// NewRegistryInNamespace returns a registry looking images up in the mirrors of the
+// registry_mirrors setting, reading the Secrets of the mirrors from the namespace.
+func NewRegistryInNamespace(namespace string) (ImageRegistry, error) {
+	return newRegistry(namespace)
+}
+
+func newRegistry(namespace string) (*Registry, error) {
+	mirrors, err := parseRegistryMirrors(viper.GetString("registry_mirrors"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid registry mirrors")
+	}
+
+	if namespace == "" {
+		for _, mirror := range mirrors {
+			if mirror.CASecret != "" || mirror.CredentialsSecret != "" {
+				return nil, errors.Errorf("invalid registry mirrors: mirror %s of registry %s reads Secrets, but no namespace is given to read them from", mirror.Mirror, mirror.Registry)
+			}
+		}
+	}
+
+	registry := newImageCacheRegistry()
+	registry.mirrors = mirrors
+	registry.namespace = namespace
+
+	return registry, nil
+}
+
+// newImageCacheRegistry returns a Registry without mirrors, caching the image configs
+// as set by image_cache_size and image_cache_ttl.
+func newImageCacheRegistry() *Registry {
+	size := viper.GetInt("image_cache_size")
+	if size <= 0 {
+		size = 1000
+	}
+
+	ttl, err := time.ParseDuration(viper.GetString("image_cache_ttl"))
+	if err != nil || ttl <= 0 {
+		ttl = time.Hour
+	}
+
 	return &Registry{
-		imageCache: cache.New(cache.NoExpiration, cache.NoExpiration),
+		imageCache: newImageConfigCache(size),
+		imageTTL:   ttl,
 	}
 }
 
+// FlushImageCache removes every cached image config and returns how many there were.
+func (r *Registry) FlushImageCache() int {
+	return r.imageCache.Flush()
+}
+
 // IsAllowedToCache checks that information about Docker image can be cached
 // base on image name and container PullPolicy
 func IsAllowedToCache(container *corev1.Container) bool {
@@ -110,7 +166,21 @@ func IsAllowedToCache(container *corev1.Container) bool {
 	return reference.Identifier() != "latest"
 }
 
+// imageCacheKey returns the cache key of the config of an image reference on a platform.
+func imageCacheKey(reference name.Reference, platform *v1.Platform) string {
+	key := reference.Name()
+	if platform != nil {
+		key += "@" + platform.String()
+	}
+
+	return key
+}
+
 // GetImageConfig returns entrypoint and command of container
+//
+// Configs are cached by image reference and platform. The content of a digest never
+// changes, so configs are also cached by the digest a tag was resolved to, and only
+// the configs cached by tag expire after the image_cache_ttl.
 func (r *Registry) GetImageConfig(
 	ctx context.Context,
 	client kubernetes.Interface,
@@ -119,19 +189,39 @@ func (r *Registry) GetImageConfig(
 	container *corev1.Container,
 	podSpec *corev1.PodSpec,
 ) (*v1.Config, error) {
+	start := time.Now()
+	platform := imagePlatform(podSpec)
+
 	allowToCache := IsAllowedToCache(container)
+
+	var reference name.Reference
 	if allowToCache {
-		if imageConfig, cacheHit := r.imageCache.Get(container.Image); cacheHit {
+		// The reference is valid if it is allowed to be cached
+		reference, _ = name.ParseReference(container.Image)
+
+		if imageConfig, cacheHit := r.imageCache.Get(imageCacheKey(reference, platform)); cacheHit {
 			logger.Info(fmt.Sprintf("found image %s in cache", container.Image))
+			imageCacheHitsCount.Inc()
+			imageCacheLookupDuration.WithLabelValues("hit").Observe(time.Since(start).Seconds())
 
-			return imageConfig.(*v1.Config), nil
+			return imageConfig, nil
 		}
 	}
 
+	imageCacheMissesCount.Inc()
+	defer func() {
+		imageCacheLookupDuration.WithLabelValues("miss").Observe(time.Since(start).Seconds())
+	}()
+
 	containerInfo := containerInfo{
 		Namespace:          namespace,
 		ServiceAccountName: podSpec.ServiceAccountName,
 		Image:              container.Image,
+		Platform:           platform,
+	}
+	if reference, err := name.ParseReference(container.Image); err == nil {
+		containerInfo.Mirror = mirrorFor(r.mirrors, reference)
+		containerInfo.MirrorNamespace = r.namespace
 	}
 	for _, imagePullSecret := range podSpec.ImagePullSecrets {
 		containerInfo.ImagePullSecrets = append(containerInfo.ImagePullSecrets, imagePullSecret.Name)
@@ -150,88 +240,234 @@ func (r *Registry) GetImageConfig(
 		containerInfo.ImagePullSecrets = []string{defaultImagePullSecret}
 	}
 
-	imageConfig, err := getImageConfig(ctx, client, containerInfo, isDisabled)
+	imageConfig, digest, err := getImageConfig(ctx, client, containerInfo, isDisabled)
 	if imageConfig != nil && allowToCache {
-		r.imageCache.Set(container.Image, imageConfig, cache.DefaultExpiration)
+		r.cacheImageConfig(reference, digest, platform, imageConfig)
 	}
 
 	return imageConfig, err
 }
 
-// getImageConfig download image blob from registry
-func getImageConfig(ctx context.Context, client kubernetes.Interface, container containerInfo, isDisabled bool) (*v1.Config, error) {
-	registrySkipVerify := isDisabled
+func (r *Registry) cacheImageConfig(reference name.Reference, digest v1.Hash, platform *v1.Platform, imageConfig *v1.Config) {
+	if _, pinned := reference.(name.Digest); pinned {
+		r.imageCache.Set(imageCacheKey(reference, platform), imageConfig, 0)
 
-	chainOpts := k8schain.Options{
-		Namespace:          container.Namespace,
-		ServiceAccountName: container.ServiceAccountName,
-		ImagePullSecrets:   container.ImagePullSecrets,
+		return
 	}
 
-	authChain, err := k8schain.New(
-		ctx,
-		client,
-		chainOpts,
-	)
+	r.imageCache.Set(imageCacheKey(reference, platform), imageConfig, r.imageTTL)
+	if digest != (v1.Hash{}) {
+		r.imageCache.Set(imageCacheKey(reference.Context().Digest(digest.String()), platform), imageConfig, 0)
+	}
+}
+
+// getImageConfig download image blob from registry, along with the digest the image reference resolved to
+func getImageConfig(ctx context.Context, client kubernetes.Interface, container containerInfo, isDisabled bool) (*v1.Config, v1.Hash, error) {
+	registrySkipVerify := isDisabled
+
+	ref, err := name.ParseReference(container.Image)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create k8schain authentication, opts: %+v", chainOpts)
+		return nil, v1.Hash{}, errors.Wrap(err, "failed to parse image reference")
+	}
+
+	var transport *http.Transport
+	if registrySkipVerify {
+		transport = remote.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	}
+
+	var authenticator authn.Authenticator
+	if container.Mirror != nil {
+		mirrored, ok := container.Mirror.rewrite(ref)
+		if !ok {
+			return nil, v1.Hash{}, errors.Errorf("image %s is not mirrored by %s", container.Image, container.Mirror.Mirror)
+		}
+		ref = mirrored
+
+		authenticator, err = container.Mirror.authenticator(ctx, client, container.MirrorNamespace)
+		if err != nil {
+			return nil, v1.Hash{}, err
+		}
+
+		rootCAs, err := container.Mirror.rootCAs(ctx, client, container.MirrorNamespace)
+		if err != nil {
+			return nil, v1.Hash{}, err
+		}
+		if rootCAs != nil {
+			if transport == nil {
+				transport = remote.DefaultTransport.(*http.Transport).Clone()
+			}
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			transport.TLSClientConfig.RootCAs = rootCAs
+		}
 	}
 
 	options := []remote.Option{
 		remote.WithContext(ctx),
-		remote.WithAuthFromKeychain(authChain),
 	}
 
-	if registrySkipVerify {
-		tr := remote.DefaultTransport.(*http.Transport).Clone()
-		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
-		options = append(options, remote.WithTransport(tr))
+	// The credentials of a mirror replace the pull secrets of the pod, which are
+	// meant for the registry it mirrors
+	if authenticator != nil {
+		options = append(options, remote.WithAuth(authenticator))
+	} else {
+		chainOpts := k8schain.Options{
+			Namespace:          container.Namespace,
+			ServiceAccountName: container.ServiceAccountName,
+			ImagePullSecrets:   container.ImagePullSecrets,
+		}
+
+		authChain, err := k8schain.New(
+			ctx,
+			client,
+			chainOpts,
+		)
+		if err != nil {
+			return nil, v1.Hash{}, errors.Wrapf(err, "failed to create k8schain authentication, opts: %+v", chainOpts)
+		}
+
+		options = append(options, remote.WithAuthFromKeychain(authChain))
 	}
 
-	ref, err := name.ParseReference(container.Image)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse image reference")
+	if transport != nil {
+		options = append(options, remote.WithTransport(transport))
 	}
 
 	descriptor, err := remote.Get(ref, options...)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot fetch image descriptor")
+		return nil, v1.Hash{}, errors.Wrap(err, "cannot fetch image descriptor")
 	}
 
 	var image v1.Image
 	if descriptor.MediaType.IsIndex() {
 		index, err := descriptor.ImageIndex()
 		if err != nil {
-			return nil, errors.Wrap(err, "cannot get image index")
+			return nil, v1.Hash{}, errors.Wrap(err, "cannot get image index")
 		}
 
 		manifest, err := index.IndexManifest()
 		if err != nil {
-			return nil, errors.Wrap(err, "cannot get index manifest")
+			return nil, v1.Hash{}, errors.Wrap(err, "cannot get index manifest")
 		}
 
-		if len(manifest.Manifests) == 0 {
-			return nil, errors.New("no manifests found in the image index")
+		imageManifest, err := selectImageManifest(manifest, container.Platform)
+		if err != nil {
+			return nil, v1.Hash{}, err
 		}
 
-		// Get the first available image
-		image, err = index.Image(manifest.Manifests[0].Digest)
+		image, err = index.Image(imageManifest.Digest)
 		if err != nil {
-			return nil, errors.Wrap(err, "cannot get image from manifest")
+			return nil, v1.Hash{}, errors.Wrap(err, "cannot get image from manifest")
 		}
 	} else {
 		image, err = descriptor.Image()
 		if err != nil {
-			return nil, errors.Wrap(err, "cannot convert image descriptor to v1.Image")
+			return nil, v1.Hash{}, errors.Wrap(err, "cannot convert image descriptor to v1.Image")
 		}
 	}
 
 	configFile, err := image.ConfigFile()
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot extract config file of image")
+		return nil, v1.Hash{}, errors.Wrap(err, "cannot extract config file of image")
 	}
 
-	return &configFile.Config, nil
+	return &configFile.Config, descriptor.Digest, nil
+}
+
+// selectImageManifest returns the manifest of the image index matching the platform,
+// or the first image manifest if the platform is nil. Attestation manifests are skipped.
+func selectImageManifest(manifest *v1.IndexManifest, platform *v1.Platform) (v1.Descriptor, error) {
+	for _, descriptor := range manifest.Manifests {
+		if isAttestationManifest(descriptor) {
+			continue
+		}
+
+		if platform == nil || (descriptor.Platform != nil && descriptor.Platform.Satisfies(*platform)) {
+			return descriptor, nil
+		}
+	}
+
+	if platform != nil {
+		return v1.Descriptor{}, errors.Errorf("no image found for platform %s in the image index", platform)
+	}
+
+	return v1.Descriptor{}, errors.New("no manifests found in the image index")
+}
+
+// isAttestationManifest reports whether an index entry holds the attestations of an
+// image, like the provenance and SBOM attached by BuildKit, rather than an image.
+func isAttestationManifest(descriptor v1.Descriptor) bool {
+	if descriptor.Annotations["vnd.docker.reference.type"] == "attestation-manifest" {
+		return true
+	}
+
+	return descriptor.Platform != nil && descriptor.Platform.OS == "unknown" && descriptor.Platform.Architecture == "unknown"
+}
+
+// imagePlatform returns the platform of the nodes a pod is scheduled to, as constrained by
+// the kubernetes.io/os and kubernetes.io/arch labels in its node selector or required node
+// affinity. The default_image_platform is used when the pod does not pin an architecture,
+// and a nil platform is returned if it is not set either.
+func imagePlatform(podSpec *corev1.PodSpec) *v1.Platform {
+	var defaultPlatform *v1.Platform
+	if value := viper.GetString("default_image_platform"); value != "" {
+		platform, err := v1.ParsePlatform(value)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("invalid default image platform %q: %s", value, err))
+		} else {
+			defaultPlatform = platform
+		}
+	}
+
+	architectures := nodeLabelValues(podSpec, corev1.LabelArchStable)
+	if len(architectures) == 0 || (defaultPlatform != nil && slices.Contains(architectures, defaultPlatform.Architecture)) {
+		return defaultPlatform
+	}
+
+	operatingSystem := "linux"
+	if operatingSystems := nodeLabelValues(podSpec, corev1.LabelOSStable); len(operatingSystems) > 0 {
+		operatingSystem = operatingSystems[0]
+	} else if defaultPlatform != nil {
+		operatingSystem = defaultPlatform.OS
+	}
+
+	// The entrypoint of an image rarely differs between platforms, any of them would do
+	return &v1.Platform{OS: operatingSystem, Architecture: architectures[0]}
+}
+
+// nodeLabelValues returns the sorted values a pod allows for a node label, or nil if it
+// does not constrain it. Values of the node selector take precedence over node affinity.
+func nodeLabelValues(podSpec *corev1.PodSpec, label string) []string {
+	if value, ok := podSpec.NodeSelector[label]; ok {
+		return []string{value}
+	}
+
+	if podSpec.Affinity == nil || podSpec.Affinity.NodeAffinity == nil ||
+		podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+
+	// Node selector terms are ORed, so every term must constrain the label
+	var values []string
+	for _, term := range podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		var termValues []string
+		for _, expression := range term.MatchExpressions {
+			if expression.Key == label && expression.Operator == corev1.NodeSelectorOpIn {
+				termValues = append(termValues, expression.Values...)
+			}
+		}
+		if len(termValues) == 0 {
+			return nil
+		}
+
+		values = append(values, termValues...)
+	}
+
+	slices.Sort(values)
+
+	return slices.Compact(values)
 }
 
 // containerInfo keeps information retrieved from POD based container definition
@@ -240,4 +476,8 @@ type containerInfo struct {
 	ImagePullSecrets   []string
 	ServiceAccountName string
 	Image              string
+	Platform           *v1.Platform
+	// Mirror is looked up instead of the registry of the image, if set
+	Mirror          *registryMirror
+	MirrorNamespace string
 }
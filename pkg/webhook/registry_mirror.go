@@ -0,0 +1,154 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// registryMirror rewrites the images of a registry, or of a repository prefix in it,
+// to a mirror of it, like docker.io to harbor.internal/dockerhub. Mirrors are only used
+// to look up image configs, the images are still pulled as named in the pod.
+type registryMirror struct {
+	// Registry is the registry host, optionally followed by a repository prefix
+	Registry string `json:"registry"`
+	// Mirror is the registry host of the mirror, optionally followed by a repository prefix
+	Mirror string `json:"mirror"`
+	// CASecret names a Secret in the webhook namespace with the ca.crt of the mirror
+	CASecret string `json:"caSecret,omitempty"`
+	// CredentialsSecret names a kubernetes.io/basic-auth Secret in the webhook namespace
+	// with the credentials of the mirror
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
+	registry   string
+	repository string
+}
+
+// parseRegistryMirrors parses the JSON list of mirrors of the registry_mirrors setting.
+func parseRegistryMirrors(value string) ([]registryMirror, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var mirrors []registryMirror
+	if err := json.Unmarshal([]byte(value), &mirrors); err != nil {
+		return nil, errors.Wrap(err, "must be a JSON list of registry mirrors")
+	}
+
+	for i, mirror := range mirrors {
+		host, repository, _ := strings.Cut(mirror.Registry, "/")
+		registry, err := name.NewRegistry(host)
+		if err != nil || host == "" {
+			return nil, errors.Errorf("invalid registry %q of mirror %d", mirror.Registry, i)
+		}
+		if _, err := name.NewRepository(mirror.Mirror + "/mirrored"); err != nil {
+			return nil, errors.Errorf("invalid mirror %q of registry %s", mirror.Mirror, mirror.Registry)
+		}
+
+		mirrors[i].registry = registry.RegistryStr()
+		mirrors[i].repository = repository
+	}
+
+	return mirrors, nil
+}
+
+// mirrorFor returns the first mirror of the registry of an image reference.
+func mirrorFor(mirrors []registryMirror, reference name.Reference) *registryMirror {
+	for i := range mirrors {
+		if _, ok := mirrors[i].rewrite(reference); ok {
+			return &mirrors[i]
+		}
+	}
+
+	return nil
+}
+
+// rewrite returns the image reference rewritten to the mirror, keeping its tag or digest,
+// if the mirror is one of its registry.
+func (m *registryMirror) rewrite(reference name.Reference) (name.Reference, bool) {
+	if reference.Context().RegistryStr() != m.registry {
+		return nil, false
+	}
+
+	repository := reference.Context().RepositoryStr()
+	if m.repository != "" {
+		if repository != m.repository && !strings.HasPrefix(repository, m.repository+"/") {
+			return nil, false
+		}
+		repository = strings.TrimPrefix(strings.TrimPrefix(repository, m.repository), "/")
+	}
+
+	image := m.Mirror + "/" + repository
+	if _, pinned := reference.(name.Digest); pinned {
+		image += "@" + reference.Identifier()
+	} else {
+		image += ":" + reference.Identifier()
+	}
+
+	mirrored, err := name.ParseReference(image)
+	if err != nil {
+		return nil, false
+	}
+
+	return mirrored, true
+}
+
+// authenticator returns the credentials of the credentials Secret of the mirror in the
+// given namespace, or nil if it has none.
+func (m *registryMirror) authenticator(ctx context.Context, client kubernetes.Interface, namespace string) (authn.Authenticator, error) {
+	if m.CredentialsSecret == "" {
+		return nil, nil
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, m.CredentialsSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the credentials Secret of mirror %s", m.Mirror)
+	}
+
+	return &authn.Basic{
+		Username: string(secret.Data[corev1.BasicAuthUsernameKey]),
+		Password: string(secret.Data[corev1.BasicAuthPasswordKey]),
+	}, nil
+}
+
+// rootCAs returns the CA of the CA Secret of the mirror in the given namespace, or nil
+// if it has none.
+func (m *registryMirror) rootCAs(ctx context.Context, client kubernetes.Interface, namespace string) (*x509.CertPool, error) {
+	if m.CASecret == "" {
+		return nil, nil
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, m.CASecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the CA Secret of mirror %s", m.Mirror)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(secret.Data["ca.crt"]) {
+		return nil, errors.Errorf("error loading the CA PEM of mirror %s from Secret: %s", m.Mirror, secret.Name)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,167 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRegistryMirrorRewrite(t *testing.T) {
+	mirrors, err := parseRegistryMirrors(`[
+		{"registry": "docker.io", "mirror": "harbor.internal/dockerhub"},
+		{"registry": "ghcr.io/example", "mirror": "harbor.internal/example"}
+	]`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{image: "nginx:1.27", expected: "harbor.internal/dockerhub/library/nginx:1.27"},
+		{image: "docker.io/bitnami/redis", expected: "harbor.internal/dockerhub/bitnami/redis:latest"},
+		{
+			image:    "ghcr.io/example/app@sha256:3f7a3e0a2c5e1d4b8f9a6c7d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f",
+			expected: "harbor.internal/example/app@sha256:3f7a3e0a2c5e1d4b8f9a6c7d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f",
+		},
+		{image: "ghcr.io/examples/app:v1"},
+		{image: "quay.io/example/app:v1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			reference, err := name.ParseReference(test.image)
+			require.NoError(t, err)
+
+			mirror := mirrorFor(mirrors, reference)
+			if test.expected == "" {
+				assert.Nil(t, mirror)
+
+				return
+			}
+
+			require.NotNil(t, mirror)
+			mirrored, ok := mirror.rewrite(reference)
+			require.True(t, ok)
+			assert.Equal(t, test.expected, mirrored.Name())
+		})
+	}
+
+	_, err = parseRegistryMirrors(`{"docker.io": "harbor.internal/dockerhub"}`)
+	require.ErrorContains(t, err, "must be a JSON list of registry mirrors")
+
+	_, err = parseRegistryMirrors(`[{"registry": "", "mirror": "harbor.internal/dockerhub"}]`)
+	require.ErrorContains(t, err, "invalid registry")
+
+	_, err = parseRegistryMirrors(`[{"registry": "docker.io", "mirror": "Harbor.internal/DockerHub"}]`)
+	require.ErrorContains(t, err, "invalid mirror")
+}
+
+func TestGetImageConfigFromMirror(t *testing.T) {
+	mirror := httptest.NewTLSServer(basicAuth("mirror", "s3cr3t", ggcrregistry.New(ggcrregistry.Logger(log.New(io.Discard, "", 0)))))
+	t.Cleanup(mirror.Close)
+	host := strings.TrimPrefix(mirror.URL, "https://")
+
+	image, err := random.Image(64, 1)
+	require.NoError(t, err)
+	image, err = mutate.Config(image, v1.Config{Entrypoint: []string{"/app"}, Cmd: []string{"serve"}})
+	require.NoError(t, err)
+
+	mirrored, err := name.ParseReference(host + "/dockerhub/example/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(mirrored, image,
+		remote.WithTransport(mirror.Client().Transport),
+		remote.WithAuth(&authn.Basic{Username: "mirror", Password: "s3cr3t"}),
+	))
+
+	k8sClient := fake.NewClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror-ca", Namespace: "vault-infra"},
+			Data: map[string][]byte{
+				"ca.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mirror.Certificate().Raw}),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirror-credentials", Namespace: "vault-infra"},
+			Type:       corev1.SecretTypeBasicAuth,
+			Data: map[string][]byte{
+				corev1.BasicAuthUsernameKey: []byte("mirror"),
+				corev1.BasicAuthPasswordKey: []byte("s3cr3t"),
+			},
+		},
+	)
+
+	t.Cleanup(viper.Reset)
+	viper.Set("registry_mirrors", `{"registry": "docker.io"}`)
+	_, err = newRegistry("vault-infra")
+	require.ErrorContains(t, err, "invalid registry mirrors")
+
+	viper.Set("registry_mirrors", `[{"registry": "docker.io", "mirror": "`+host+`/dockerhub", "caSecret": "mirror-ca", "credentialsSecret": "mirror-credentials"}]`)
+	_, err = NewRegistryInNamespace("")
+	require.ErrorContains(t, err, "no namespace is given to read them from")
+
+	registry, err := newRegistry("vault-infra")
+	require.NoError(t, err)
+	container := &corev1.Container{Name: "app", Image: "example/app:v1"}
+
+	// The image is only in the mirror, served with a CA the system does not trust
+	imageConfig, err := registry.GetImageConfig(t.Context(), k8sClient, "default", false, container, &corev1.PodSpec{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/app"}, imageConfig.Entrypoint)
+	assert.Equal(t, []string{"serve"}, imageConfig.Cmd)
+
+	// The config is cached by the image of the container
+	reference, err := name.ParseReference(container.Image)
+	require.NoError(t, err)
+	_, ok := registry.imageCache.Get(imageCacheKey(reference, nil))
+	assert.True(t, ok)
+
+	registry.FlushImageCache()
+	registry.mirrors[0].CASecret = ""
+	_, err = registry.GetImageConfig(t.Context(), k8sClient, "default", false, container, &corev1.PodSpec{})
+	require.Error(t, err)
+}
+
+func basicAuth(username, password string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="mirror"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -17,6 +17,10 @@ package webhook
 import (
 	"testing"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -65,3 +69,107 @@ func TestIsAllowedToCache(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectImageManifest(t *testing.T) {
+	t.Parallel()
+
+	attestation := v1.Descriptor{
+		Digest:      v1.Hash{Algorithm: "sha256", Hex: "attestation"},
+		Platform:    &v1.Platform{OS: "unknown", Architecture: "unknown"},
+		Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest"},
+	}
+	arm64 := v1.Descriptor{
+		Digest:   v1.Hash{Algorithm: "sha256", Hex: "arm64"},
+		Platform: &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+	}
+	amd64 := v1.Descriptor{
+		Digest:   v1.Hash{Algorithm: "sha256", Hex: "amd64"},
+		Platform: &v1.Platform{OS: "linux", Architecture: "amd64"},
+	}
+	manifest := &v1.IndexManifest{Manifests: []v1.Descriptor{attestation, arm64, amd64}}
+
+	selected, err := selectImageManifest(manifest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, arm64.Digest, selected.Digest)
+
+	selected, err = selectImageManifest(manifest, &v1.Platform{OS: "linux", Architecture: "amd64"})
+	require.NoError(t, err)
+	assert.Equal(t, amd64.Digest, selected.Digest)
+
+	selected, err = selectImageManifest(manifest, &v1.Platform{OS: "linux", Architecture: "arm64"})
+	require.NoError(t, err)
+	assert.Equal(t, arm64.Digest, selected.Digest)
+
+	_, err = selectImageManifest(manifest, &v1.Platform{OS: "linux", Architecture: "s390x"})
+	require.EqualError(t, err, "no image found for platform linux/s390x in the image index")
+
+	_, err = selectImageManifest(&v1.IndexManifest{Manifests: []v1.Descriptor{attestation}}, nil)
+	require.EqualError(t, err, "no manifests found in the image index")
+}
+
+func TestImagePlatform(t *testing.T) {
+	archAffinity := func(terms ...[]string) *corev1.Affinity {
+		var nodeSelectorTerms []corev1.NodeSelectorTerm
+		for _, values := range terms {
+			term := corev1.NodeSelectorTerm{}
+			if values != nil {
+				term.MatchExpressions = []corev1.NodeSelectorRequirement{
+					{Key: corev1.LabelArchStable, Operator: corev1.NodeSelectorOpIn, Values: values},
+				}
+			}
+			nodeSelectorTerms = append(nodeSelectorTerms, term)
+		}
+
+		return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: nodeSelectorTerms},
+		}}
+	}
+
+	tests := []struct {
+		name            string
+		defaultPlatform string
+		podSpec         corev1.PodSpec
+		want            *v1.Platform
+	}{
+		{
+			name: "unconstrained pod without default",
+		},
+		{
+			name:            "unconstrained pod with default",
+			defaultPlatform: "linux/arm64",
+			want:            &v1.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:            "node selector",
+			defaultPlatform: "linux/amd64",
+			podSpec:         corev1.PodSpec{NodeSelector: map[string]string{corev1.LabelArchStable: "arm64"}},
+			want:            &v1.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:    "node affinity",
+			podSpec: corev1.PodSpec{Affinity: archAffinity([]string{"arm64"})},
+			want:    &v1.Platform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:            "node affinity allowing the default",
+			defaultPlatform: "linux/amd64",
+			podSpec:         corev1.PodSpec{Affinity: archAffinity([]string{"arm64"}, []string{"amd64"})},
+			want:            &v1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+		{
+			name:            "node affinity term without architecture",
+			defaultPlatform: "linux/amd64",
+			podSpec:         corev1.PodSpec{Affinity: archAffinity([]string{"arm64"}, nil)},
+			want:            &v1.Platform{OS: "linux", Architecture: "amd64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.Set("default_image_platform", tt.defaultPlatform)
+
+			assert.Equal(t, tt.want, imagePlatform(&tt.podSpec))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"emperror.dev/errors"
+	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	"github.com/bank-vaults/vault-sdk/vault"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// SecretResolver resolves the Vault references in the values of Secrets,
+// ConfigMaps and other objects. Values without references are returned unchanged.
+// It is implemented by the secret injector of the Vault SDK.
+type SecretResolver interface {
+	GetDataFromVaultWithContext(ctx context.Context, data map[string]string) (map[string]string, error)
+}
+
+// PlaceholderSecretResolver resolves every Vault reference to a placeholder
+// naming the reference, without reading anything from Vault.
+type PlaceholderSecretResolver struct{}
+
+func (PlaceholderSecretResolver) GetDataFromVaultWithContext(_ context.Context, data map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(data))
+
+	for key, value := range data {
+		if injector.HasInlineVaultDelimiters(value) {
+			for _, reference := range injector.FindInlineVaultDelimiters(value) {
+				value = strings.ReplaceAll(value, reference[0], placeholder(reference[1]))
+			}
+		} else if common.HasVaultPrefix(value) {
+			value = placeholder(value)
+		}
+
+		resolved[key] = value
+	}
+
+	return resolved, nil
+}
+
+func placeholder(reference string) string {
+	return "<" + strings.TrimPrefix(reference, ">>") + ">"
+}
+
+// SetSecretResolver makes the webhook resolve Vault references with the given
+// resolver instead of reading them from Vault.
+func (mw *MutatingWebhook) SetSecretResolver(resolver SecretResolver) {
+	mw.secretResolver = resolver
+}
+
+// secretResolverFor returns the SecretResolver of the given config. The returned
+// release function must be called once the resolver is no longer needed.
+func (mw *MutatingWebhook) secretResolverFor(ctx context.Context, vaultConfig VaultConfig) (SecretResolver, func(), error) {
+	if mw.secretResolver != nil {
+		return mw.secretResolver, func() {}, nil
+	}
+
+	vaultClient, release, err := mw.vaultClientFor(ctx, vaultConfig)
+	if err != nil {
+		return nil, nil, withReason(ReasonVaultAuthFailed, errors.Wrap(err, "failed to create vault client"))
+	}
+
+	return newVaultSecretResolver(vaultConfig, vaultClient, nil), release, nil
+}
+
+// newVaultSecretResolver returns a SecretResolver reading secrets with the given client,
+// handing the secrets read from Vault with a lease to the renewer, if not nil.
+func newVaultSecretResolver(vaultConfig VaultConfig, vaultClient *vault.Client, renewer injector.SecretRenewer) SecretResolver {
+	config := injector.Config{
+		TransitKeyID:     vaultConfig.TransitKeyID,
+		TransitPath:      vaultConfig.TransitPath,
+		TransitBatchSize: vaultConfig.TransitBatchSize,
+		DaemonMode:       renewer != nil,
+	}
+	secretInjector := injector.NewSecretInjector(config, vaultClient, renewer, logger)
+
+	return vaultSecretResolver{&secretInjector}
+}
+
+// vaultSecretResolver attaches the reason of the Event recorded about a failed
+// mutation to the errors of reading secrets from Vault.
+type vaultSecretResolver struct {
+	resolver SecretResolver
+}
+
+func (r vaultSecretResolver) GetDataFromVaultWithContext(ctx context.Context, data map[string]string) (map[string]string, error) {
+	resolved, err := r.resolver.GetDataFromVaultWithContext(ctx, data)
+
+	return resolved, vaultReadError(err)
+}
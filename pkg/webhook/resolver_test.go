@@ -0,0 +1,84 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPlaceholderSecretResolver(t *testing.T) {
+	data, err := PlaceholderSecretResolver{}.GetDataFromVaultWithContext(t.Context(), map[string]string{
+		"plain":    "value",
+		"secret":   "vault:secret/data/account#password",
+		"dynamic":  ">>vault:database/creds/my-role#username",
+		"inline":   "user=${vault:secret/data/account#username} pass=${vault:secret/data/account#password}",
+		"template": "${vault:secret/data/account#password}",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"plain":    "value",
+		"secret":   "<vault:secret/data/account#password>",
+		"dynamic":  "<vault:database/creds/my-role#username>",
+		"inline":   "user=<vault:secret/data/account#username> pass=<vault:secret/data/account#password>",
+		"template": "<vault:secret/data/account#password>",
+	}, data)
+}
+
+func TestMutateWithSecretResolver(t *testing.T) {
+	mw, err := NewMutatingWebhookInNamespace(slog.Default(), fake.NewClientset(), "vault-infra")
+	require.NoError(t, err)
+	mw.SetSecretResolver(PlaceholderSecretResolver{})
+
+	configMap := &corev1.ConfigMap{
+		Data: map[string]string{
+			"password": "vault:secret/data/account#password",
+		},
+	}
+
+	err = mw.MutateConfigMap(t.Context(), configMap, VaultConfig{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "<vault:secret/data/account#password>", configMap.Data["password"])
+
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte("vault:secret/data/account#password"),
+		},
+	}
+
+	err = mw.MutateSecret(t.Context(), secret, VaultConfig{}, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("<vault:secret/data/account#password>"), secret.Data["password"])
+
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"url": "https://${vault:secret/data/account#host}/api",
+		},
+	}}
+
+	err = mw.MutateObject(t.Context(), object, VaultConfig{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://<vault:secret/data/account#host>/api", object.Object["spec"].(map[string]interface{})["url"])
+}
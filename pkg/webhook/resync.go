@@ -0,0 +1,330 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"emperror.dev/errors"
+	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// secretTemplates are the values of a Secret or ConfigMap referencing Vault, as
+// they were before their resolution. They never contain resolved values.
+type secretTemplates struct {
+	Data       map[string]string `json:"data,omitempty"`
+	BinaryData map[string]string `json:"binaryData,omitempty"`
+}
+
+// secretDataTemplates returns the values of a Secret resolved by its mutation.
+func secretDataTemplates(secret *corev1.Secret) secretTemplates {
+	templates := secretTemplates{Data: map[string]string{}}
+	for key, value := range secret.Data {
+		if key == corev1.DockerConfigJsonKey || common.HasVaultPrefix(string(value)) || injector.HasInlineVaultDelimiters(string(value)) {
+			templates.Data[key] = string(value)
+		}
+	}
+
+	return templates
+}
+
+// configMapDataTemplates returns the values of a ConfigMap resolved by its mutation.
+func configMapDataTemplates(configMap *corev1.ConfigMap) secretTemplates {
+	templates := secretTemplates{Data: map[string]string{}, BinaryData: map[string]string{}}
+	for key, value := range configMap.Data {
+		if common.HasVaultPrefix(value) || injector.HasInlineVaultDelimiters(value) {
+			templates.Data[key] = value
+		}
+	}
+	for key, value := range configMap.BinaryData {
+		if common.HasVaultPrefix(string(value)) {
+			templates.BinaryData[key] = string(value)
+		}
+	}
+
+	return templates
+}
+
+// recordSecretTemplates records the templates of a Secret or ConfigMap in its
+// templates annotation, signed for the namespace of the object, and labels it,
+// so that it is resynced with Vault.
+func (mw *MutatingWebhook) recordSecretTemplates(ctx context.Context, obj metav1.Object, namespace string, templates secretTemplates) error {
+	templatesJSON, err := json.Marshal(templates)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal secret templates")
+	}
+
+	signature, err := mw.templatesSignature(ctx, obj, namespace, string(templatesJSON))
+	if err != nil {
+		return errors.Wrap(err, "failed to sign secret templates")
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.VaultSecretTemplatesAnnotation] = string(templatesJSON)
+	annotations[common.VaultSecretTemplatesSignatureAnnotation] = signature
+	obj.SetAnnotations(annotations)
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[common.SecretResyncLabel] = "true"
+	obj.SetLabels(labels)
+
+	return nil
+}
+
+// templatesSignature returns the signature of the templates of a Secret or ConfigMap
+// in the given namespace. Templates are only resynced with their signature, so that
+// labeling an object, or copying the annotation to an object the webhook did not
+// mutate, does not get it resynced.
+func (mw *MutatingWebhook) templatesSignature(ctx context.Context, obj metav1.Object, namespace, templatesJSON string) (string, error) {
+	var kind string
+	switch obj.(type) {
+	case *corev1.Secret:
+		kind = "Secret"
+	case *corev1.ConfigMap:
+		kind = "ConfigMap"
+	default:
+		return "", errors.Errorf("unsupported object %T", obj)
+	}
+
+	return mw.sign(ctx, []byte(kind), []byte(namespace), []byte(templatesJSON))
+}
+
+// forgetSecretTemplates removes the templates annotation, the resync label and the
+// annotations recorded about the resolved data of a Secret or ConfigMap without Vault
+// references, so that the values it was updated with are not overwritten by its next
+// resync. Objects whose data is still the one resolved by the webhook, like when the
+// webhook itself updates them, keep them.
+func (mw *MutatingWebhook) forgetSecretTemplates(ctx context.Context, obj metav1.Object, data map[string][]byte) {
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[common.VaultSecretTemplatesAnnotation]; !ok {
+		if _, ok := obj.GetLabels()[common.SecretResyncLabel]; !ok {
+			return
+		}
+	}
+
+	if recorded, ok := annotations[common.VaultSecretsHashAnnotation]; ok {
+		if hash, err := mw.contentHash(ctx, data); err == nil && hash == recorded {
+			return
+		}
+	}
+
+	for _, annotation := range []string{
+		common.VaultSecretTemplatesAnnotation,
+		common.VaultSecretTemplatesSignatureAnnotation,
+		common.VaultSecretPathsAnnotation,
+		common.VaultSecretVersionsAnnotation,
+		common.VaultSecretsHashAnnotation,
+	} {
+		delete(annotations, annotation)
+	}
+	obj.SetAnnotations(annotations)
+
+	labels := obj.GetLabels()
+	delete(labels, common.SecretResyncLabel)
+	obj.SetLabels(labels)
+}
+
+// StartSecretResync starts watching the Secrets and ConfigMaps labeled for resync and
+// blocks until the caches are synced. Once it returns, their templates are resolved again
+// every secret_resync_interval until the context is done, and the objects are updated in
+// place if their resolved data changed. Objects only referencing KV version 2 secrets are
+// resolved again only if the version of one of these secrets changed. Only the replica of
+// the webhook holding the secret_resync_lease Lease resolves them.
+func (mw *MutatingWebhook) StartSecretResync(ctx context.Context) error {
+	interval, err := time.ParseDuration(viper.GetString("secret_resync_interval"))
+	if err != nil || interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(mw.k8sClient, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = common.SecretResyncLabel + "=true"
+		}),
+	)
+	resyncInformers := []cache.SharedIndexInformer{
+		factory.Core().V1().Secrets().Informer(),
+		factory.Core().V1().ConfigMaps().Informer(),
+	}
+
+	factory.Start(ctx.Done())
+
+	for _, informer := range resyncInformers {
+		if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+			return errors.New("failed to sync resync cache")
+		}
+	}
+
+	return mw.runAsLeader(ctx, viper.GetString("secret_resync_lease"), func(ctx context.Context) {
+		wait.UntilWithContext(ctx, func(ctx context.Context) {
+			for _, informer := range resyncInformers {
+				for _, item := range informer.GetStore().List() {
+					obj, ok := item.(metav1.Object)
+					if !ok {
+						continue
+					}
+
+					if err := mw.resyncSecrets(ctx, obj); err != nil {
+						mw.logger.Warn("failed to resync Vault secrets", slog.String("namespace", obj.GetNamespace()), slog.String("name", obj.GetName()), slog.Any("error", err))
+					}
+				}
+			}
+		}, interval)
+	})
+}
+
+// resyncSecrets resolves the templates of a Secret or ConfigMap again and updates it
+// if its resolved data changed. Only the templates signed by the webhook are resolved,
+// and they are authorized like at admission time, as the configuration of the object
+// may have been edited since then.
+func (mw *MutatingWebhook) resyncSecrets(ctx context.Context, obj metav1.Object) error {
+	templatesJSON, ok := obj.GetAnnotations()[common.VaultSecretTemplatesAnnotation]
+	if !ok {
+		return nil
+	}
+
+	signature, err := mw.templatesSignature(ctx, obj, obj.GetNamespace(), templatesJSON)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(signature), []byte(obj.GetAnnotations()[common.VaultSecretTemplatesSignatureAnnotation])) {
+		mw.logger.Debug("skipping the resync of secret templates not signed by the webhook", slog.String("namespace", obj.GetNamespace()), slog.String("name", obj.GetName()))
+
+		return nil
+	}
+
+	// Dynamic secrets are renewed rather than read again, which would lease new ones
+	if _, ok := obj.GetAnnotations()[common.VaultLeaseIDsAnnotation]; ok {
+		return nil
+	}
+
+	var templates secretTemplates
+	if err := json.Unmarshal([]byte(templatesJSON), &templates); err != nil {
+		return errors.Wrap(err, "failed to parse secret templates")
+	}
+
+	restored := obj.(runtime.Object).DeepCopyObject().(metav1.Object)
+	switch v := restored.(type) {
+	case *corev1.Secret:
+		if v.Data == nil {
+			v.Data = map[string][]byte{}
+		}
+		for key, value := range templates.Data {
+			v.Data[key] = []byte(value)
+		}
+	case *corev1.ConfigMap:
+		if v.Data == nil {
+			v.Data = map[string]string{}
+		}
+		if v.BinaryData == nil {
+			v.BinaryData = map[string][]byte{}
+		}
+		for key, value := range templates.Data {
+			v.Data[key] = value
+		}
+		for key, value := range templates.BinaryData {
+			v.BinaryData[key] = []byte(value)
+		}
+	default:
+		return errors.Errorf("unsupported object %T", obj)
+	}
+
+	vaultConfig, _, err := mw.vaultConfigFor(&model.AdmissionReview{Namespace: obj.GetNamespace()}, restored)
+	if err != nil {
+		return err
+	}
+	if vaultConfig.Skip {
+		return nil
+	}
+
+	references := resolvedReferences(restored)
+	if mw.secretVersionsUnchanged(ctx, obj, references, vaultConfig) {
+		return nil
+	}
+
+	if err := mw.authorizeObject(restored, vaultConfig); err != nil {
+		return err
+	}
+
+	switch v := restored.(type) {
+	case *corev1.Secret:
+		if err := mw.MutateSecret(ctx, v, vaultConfig, false); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(obj, v) {
+			return nil
+		}
+
+		_, err = mw.k8sClient.CoreV1().Secrets(v.Namespace).Update(ctx, v, metav1.UpdateOptions{})
+	case *corev1.ConfigMap:
+		if err := mw.MutateConfigMap(ctx, v, vaultConfig); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(obj, v) {
+			return nil
+		}
+
+		_, err = mw.k8sClient.CoreV1().ConfigMaps(v.Namespace).Update(ctx, v, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update resynced object")
+	}
+
+	mw.logger.Info("Vault secrets resynced", slog.String("namespace", obj.GetNamespace()), slog.String("name", obj.GetName()))
+
+	return nil
+}
+
+// secretVersionsUnchanged reports whether every reference of an object is a KV version 2
+// secret whose version is the one recorded at its last resolution.
+func (mw *MutatingWebhook) secretVersionsUnchanged(ctx context.Context, obj metav1.Object, references []vaultReference, vaultConfig VaultConfig) bool {
+	recorded, ok := obj.GetAnnotations()[common.VaultSecretVersionsAnnotation]
+	if !ok {
+		return false
+	}
+
+	for _, reference := range references {
+		if _, ok := kvMetadataPath(reference.Path); !ok {
+			return false
+		}
+	}
+
+	versions, err := mw.referenceVersions(ctx, references, vaultConfig)
+	if err != nil {
+		return false
+	}
+
+	return formatSecretVersions(versions) == recorded
+}
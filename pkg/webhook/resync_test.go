@@ -0,0 +1,208 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// staticSecretResolver resolves the vault:path#key values to the values it maps them to.
+type staticSecretResolver map[string]string
+
+func (r staticSecretResolver) GetDataFromVaultWithContext(_ context.Context, data map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(data))
+	for key, value := range data {
+		if resolvedValue, ok := r[value]; ok {
+			value = resolvedValue
+		}
+		resolved[key] = value
+	}
+
+	return resolved, nil
+}
+
+func TestResyncSecrets(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+	viper.Set("enable_secret_resync", true)
+
+	resolver := staticSecretResolver{
+		"vault:secret/data/db#password": "password-1",
+		"vault:kv1/app#token":           "token-1",
+	}
+	versions := staticSecretVersions{"secret/data/db": "1"}
+
+	mw := &MutatingWebhook{
+		k8sClient:      fake.NewClientset(),
+		logger:         slog.New(slog.DiscardHandler),
+		secretResolver: resolver,
+		secretVersions: versions,
+	}
+
+	newSecret := func(name string, data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Data: map[string][]byte{}}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+		require.NoError(t, mw.MutateSecret(context.Background(), secret, VaultConfig{ObjectNamespace: "default"}, false))
+
+		return secret
+	}
+
+	kvSecret := newSecret("db", map[string]string{"password": "vault:secret/data/db#password", "user": "app"})
+	kv1Secret := newSecret("app", map[string]string{"token": "vault:kv1/app#token"})
+
+	assert.Equal(t, "true", kvSecret.Labels[common.SecretResyncLabel])
+	assert.JSONEq(t, `{"data":{"password":"vault:secret/data/db#password"}}`, kvSecret.Annotations[common.VaultSecretTemplatesAnnotation])
+	assert.Equal(t, "password-1", string(kvSecret.Data["password"]))
+
+	mw.k8sClient = fake.NewClientset(kvSecret, kv1Secret)
+	get := func(name string) *corev1.Secret {
+		secret, err := mw.k8sClient.CoreV1().Secrets("default").Get(context.Background(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		return secret
+	}
+
+	resolver["vault:secret/data/db#password"] = "password-2"
+	resolver["vault:kv1/app#token"] = "token-2"
+
+	// KV version 2 secrets are resolved again only if their version changed
+	require.NoError(t, mw.resyncSecrets(context.Background(), get("db")))
+	assert.Equal(t, "password-1", string(get("db").Data["password"]))
+
+	require.NoError(t, mw.resyncSecrets(context.Background(), get("app")))
+	assert.Equal(t, "token-2", string(get("app").Data["token"]))
+
+	versions["secret/data/db"] = "2"
+
+	require.NoError(t, mw.resyncSecrets(context.Background(), get("db")))
+	resynced := get("db")
+	assert.Equal(t, "password-2", string(resynced.Data["password"]))
+	assert.Equal(t, "app", string(resynced.Data["user"]))
+	assert.Equal(t, "secret/data/db=2", resynced.Annotations[common.VaultSecretVersionsAnnotation])
+	assert.Equal(t, kvSecret.Annotations[common.VaultSecretTemplatesAnnotation], resynced.Annotations[common.VaultSecretTemplatesAnnotation])
+
+	// Templates are only resynced with the signature of the webhook, for the namespace it signed them for
+	unsigned := get("app")
+	unsigned.Annotations[common.VaultSecretTemplatesAnnotation] = `{"data":{"token":"vault:secret/data/other#token"}}`
+	require.NoError(t, mw.resyncSecrets(context.Background(), unsigned))
+
+	labeled := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:        "copy",
+		Namespace:   "other",
+		Labels:      map[string]string{common.SecretResyncLabel: "true"},
+		Annotations: get("app").Annotations,
+	}}
+	require.NoError(t, mw.resyncSecrets(context.Background(), labeled))
+
+	var updates int
+	for _, action := range mw.k8sClient.(*fake.Clientset).Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	assert.Equal(t, 2, updates)
+}
+
+func TestForgetSecretTemplates(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+	viper.Set("enable_secret_resync", true)
+
+	mw := &MutatingWebhook{
+		k8sClient:      fake.NewClientset(),
+		logger:         slog.New(slog.DiscardHandler),
+		secretResolver: staticSecretResolver{"vault:secret/data/db#password": "password-1"},
+		secretVersions: staticSecretVersions{"secret/data/db": "1"},
+	}
+
+	t.Run("Secret", func(t *testing.T) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("vault:secret/data/db#password")},
+		}
+		require.NoError(t, mw.MutateSecret(context.Background(), secret, VaultConfig{}, false))
+
+		// Updates keeping the resolved data, like resyncs, keep the templates
+		updated := secret.DeepCopy()
+		updated.Labels["team"] = "payments"
+		require.NoError(t, mw.MutateSecret(context.Background(), updated, VaultConfig{}, false))
+		assert.Equal(t, secret.Annotations, updated.Annotations)
+		assert.Equal(t, "true", updated.Labels[common.SecretResyncLabel])
+
+		// Values applied by the user are not overwritten by the next resync
+		applied := secret.DeepCopy()
+		applied.StringData = map[string]string{"password": "plain"}
+		require.NoError(t, mw.MutateSecret(context.Background(), applied, VaultConfig{}, false))
+		assert.Empty(t, applied.Annotations)
+		assert.NotContains(t, applied.Labels, common.SecretResyncLabel)
+		assert.NoError(t, mw.resyncSecrets(context.Background(), applied))
+	})
+
+	t.Run("ConfigMap", func(t *testing.T) {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Data:       map[string]string{"password": "vault:secret/data/db#password"},
+		}
+		require.NoError(t, mw.MutateConfigMap(context.Background(), configMap, VaultConfig{}))
+
+		updated := configMap.DeepCopy()
+		require.NoError(t, mw.MutateConfigMap(context.Background(), updated, VaultConfig{}))
+		assert.Equal(t, configMap.Annotations, updated.Annotations)
+		assert.Equal(t, "true", updated.Labels[common.SecretResyncLabel])
+
+		applied := configMap.DeepCopy()
+		applied.Data["password"] = "plain"
+		require.NoError(t, mw.MutateConfigMap(context.Background(), applied, VaultConfig{}))
+		assert.Empty(t, applied.Annotations)
+		assert.NotContains(t, applied.Labels, common.SecretResyncLabel)
+	})
+}
+
+func TestSecretResyncLeaderElection(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	k8sClient := fake.NewClientset()
+	mw := &MutatingWebhook{
+		k8sClient: k8sClient,
+		namespace: "vault-infra",
+		logger:    slog.New(slog.DiscardHandler),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, mw.StartSecretResync(ctx))
+
+	// Only the replica holding the Lease resyncs the objects
+	assert.Eventually(t, func() bool {
+		lease, err := k8sClient.CoordinationV1().Leases("vault-infra").Get(ctx, "vault-secrets-webhook-secret-resync", metav1.GetOptions{})
+
+		return err == nil && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != ""
+	}, 10*time.Second, 100*time.Millisecond)
+}
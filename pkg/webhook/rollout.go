@@ -0,0 +1,278 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// secretVersionReader reads the current versions of KV version 2 secrets.
+// Secrets missing from Vault are missing from the returned versions.
+type secretVersionReader interface {
+	SecretVersions(ctx context.Context, vaultConfig VaultConfig, paths []string) (map[string]string, error)
+}
+
+// kvMetadataPath returns the metadata path of a KV version 2 secret path,
+// e.g. secret/metadata/db for secret/data/db. Other paths are not versioned.
+func kvMetadataPath(secretPath string) (string, bool) {
+	mount, name, ok := strings.Cut(secretPath, "/data/")
+	if !ok || mount == "" || name == "" {
+		return "", false
+	}
+
+	return mount + "/metadata/" + name, true
+}
+
+// recordSecretPaths records the KV version 2 secret paths referenced by the pod
+// template of a workload in its secret paths annotation, for secret rollouts.
+func recordSecretPaths(workload metav1.Object, references []vaultReference) {
+	// Pinned versions never change
+	var latest []vaultReference
+	for _, reference := range references {
+		if _, ok := kvMetadataPath(reference.Path); ok && reference.Version == "" {
+			latest = append(latest, reference)
+		}
+	}
+	paths := referencedPaths(latest)
+
+	annotations := workload.GetAnnotations()
+	if len(paths) == 0 {
+		delete(annotations, common.VaultSecretPathsAnnotation)
+
+		return
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.VaultSecretPathsAnnotation] = strings.Join(paths, ",")
+	workload.SetAnnotations(annotations)
+}
+
+// StartSecretRollouts starts watching Deployments, StatefulSets and DaemonSets and blocks
+// until the caches are synced. Once it returns, the workloads with a secret paths annotation
+// are checked every secret_rollouts_interval until the context is done: if the version of a
+// referenced secret changed since the last check, the pod template of the workload is
+// annotated with the time of the change, which rolls out new pods reading the new version.
+// Only the replica of the webhook holding the secret_rollouts_lease Lease checks them.
+func (mw *MutatingWebhook) StartSecretRollouts(ctx context.Context) error {
+	interval, err := time.ParseDuration(viper.GetString("secret_rollouts_interval"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+
+	factory := informers.NewSharedInformerFactory(mw.k8sClient, 0)
+	workloadInformers := []cache.SharedIndexInformer{
+		factory.Apps().V1().Deployments().Informer(),
+		factory.Apps().V1().StatefulSets().Informer(),
+		factory.Apps().V1().DaemonSets().Informer(),
+	}
+
+	factory.Start(ctx.Done())
+
+	for _, informer := range workloadInformers {
+		if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+			return errors.New("failed to sync workload cache")
+		}
+	}
+
+	return mw.runAsLeader(ctx, viper.GetString("secret_rollouts_lease"), func(ctx context.Context) {
+		wait.UntilWithContext(ctx, func(ctx context.Context) {
+			for _, informer := range workloadInformers {
+				for _, item := range informer.GetStore().List() {
+					workload, ok := item.(metav1.Object)
+					if !ok {
+						continue
+					}
+
+					if err := mw.rolloutSecrets(ctx, workload); err != nil {
+						mw.logger.Warn("failed to check the Vault secrets of workload", slog.String("namespace", workload.GetNamespace()), slog.String("name", workload.GetName()), slog.Any("error", err))
+					}
+				}
+			}
+		}, interval)
+	})
+}
+
+// rolloutSecrets compares the versions of the secrets referenced by a workload with
+// the versions recorded at the last check, and restarts its pods if any of them changed.
+// Secrets checked for the first time are recorded without restarting the pods.
+// Workloads are updated, not patched, so that a replica of the webhook losing its Lease
+// while checking a workload conflicts with the new leader instead of restarting its pods twice.
+// The paths are authorized like at admission time, as the annotation holding them
+// may have been edited since then.
+func (mw *MutatingWebhook) rolloutSecrets(ctx context.Context, workload metav1.Object) error {
+	annotations := workload.GetAnnotations()
+
+	paths := common.SplitAndTrim(annotations[common.VaultSecretPathsAnnotation])
+	template := podTemplateSpec(workload)
+	if len(paths) == 0 || template == nil {
+		return nil
+	}
+
+	pod := templatePod(workload, template)
+	ar := &model.AdmissionReview{Namespace: workload.GetNamespace()}
+	vaultConfig, _, err := mw.vaultConfigFor(ar, pod)
+	if err != nil {
+		return err
+	}
+	if vaultConfig.Skip {
+		return nil
+	}
+
+	// Only the paths the pod template references are checked
+	references := objectVaultReferences(pod, vaultConfig)
+	paths = slices.DeleteFunc(paths, func(path string) bool {
+		return !slices.ContainsFunc(references, func(reference vaultReference) bool { return reference.Path == path })
+	})
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if err := mw.authorizeObject(pod, vaultConfig); err != nil {
+		return err
+	}
+
+	versions, err := mw.secretVersionsOf(ctx, vaultConfig, paths)
+	if err != nil {
+		return err
+	}
+
+	recorded := parseSecretVersions(annotations[common.VaultSecretVersionsAnnotation])
+	if maps.Equal(recorded, versions) {
+		return nil
+	}
+
+	var rotated []string
+	for _, path := range paths {
+		if version, ok := recorded[path]; ok && versions[path] != version {
+			rotated = append(rotated, path)
+		}
+	}
+
+	updated := workload.(runtime.Object).DeepCopyObject().(metav1.Object)
+
+	updatedAnnotations := updated.GetAnnotations()
+	updatedAnnotations[common.VaultSecretVersionsAnnotation] = formatSecretVersions(versions)
+	updated.SetAnnotations(updatedAnnotations)
+
+	if len(rotated) > 0 {
+		mw.logger.Info("Vault secrets of workload changed, rolling out new pods",
+			slog.String("namespace", workload.GetNamespace()), slog.String("name", workload.GetName()), slog.String("paths", strings.Join(rotated, ",")))
+
+		updatedTemplate := podTemplateSpec(updated)
+		if updatedTemplate.Annotations == nil {
+			updatedTemplate.Annotations = map[string]string{}
+		}
+		updatedTemplate.Annotations[common.VaultSecretsRotatedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	return mw.updateWorkload(ctx, updated)
+}
+
+// secretVersionsOf returns the current versions of the given KV version 2 secrets,
+// read from their metadata with the Vault client of the given config.
+func (mw *MutatingWebhook) secretVersionsOf(ctx context.Context, vaultConfig VaultConfig, paths []string) (map[string]string, error) {
+	if mw.secretVersions != nil {
+		return mw.secretVersions.SecretVersions(ctx, vaultConfig, paths)
+	}
+
+	// Secrets resolved without Vault have no versions
+	if mw.secretResolver != nil {
+		return map[string]string{}, nil
+	}
+
+	vaultClient, release, err := mw.vaultClientFor(ctx, vaultConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create vault client")
+	}
+	defer release()
+
+	versions := map[string]string{}
+	for _, path := range paths {
+		metadataPath, ok := kvMetadataPath(path)
+		if !ok {
+			continue
+		}
+
+		secret, err := vaultClient.RawClient().Logical().ReadWithContext(ctx, metadataPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the metadata of secret %s", path)
+		}
+		if secret == nil || secret.Data["current_version"] == nil {
+			continue
+		}
+
+		versions[path] = fmt.Sprint(secret.Data["current_version"])
+	}
+
+	return versions, nil
+}
+
+func (mw *MutatingWebhook) updateWorkload(ctx context.Context, workload metav1.Object) error {
+	var err error
+
+	switch v := workload.(type) {
+	case *appsv1.Deployment:
+		_, err = mw.k8sClient.AppsV1().Deployments(v.Namespace).Update(ctx, v, metav1.UpdateOptions{})
+	case *appsv1.StatefulSet:
+		_, err = mw.k8sClient.AppsV1().StatefulSets(v.Namespace).Update(ctx, v, metav1.UpdateOptions{})
+	case *appsv1.DaemonSet:
+		_, err = mw.k8sClient.AppsV1().DaemonSets(v.Namespace).Update(ctx, v, metav1.UpdateOptions{})
+	default:
+		return errors.Errorf("unsupported workload %T", workload)
+	}
+
+	return errors.Wrap(err, "failed to update workload")
+}
+
+// parseSecretVersions parses the path=version pairs of the secret versions annotation.
+func parseSecretVersions(value string) map[string]string {
+	versions := map[string]string{}
+	for _, pair := range common.SplitAndTrim(value) {
+		if path, version, ok := strings.Cut(pair, "="); ok {
+			versions[path] = version
+		}
+	}
+
+	return versions
+}
+
+func formatSecretVersions(versions map[string]string) string {
+	var pairs []string
+	for _, path := range slices.Sorted(maps.Keys(versions)) {
+		pairs = append(pairs, path+"="+versions[path])
+	}
+
+	return strings.Join(pairs, ",")
+}
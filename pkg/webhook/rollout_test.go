@@ -0,0 +1,261 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+type staticSecretVersions map[string]string
+
+func (v staticSecretVersions) SecretVersions(_ context.Context, _ VaultConfig, paths []string) (map[string]string, error) {
+	versions := map[string]string{}
+	for _, path := range paths {
+		if version, ok := v[path]; ok {
+			versions[path] = version
+		}
+	}
+
+	return versions, nil
+}
+
+func TestKVMetadataPath(t *testing.T) {
+	path, ok := kvMetadataPath("secret/data/db")
+	assert.True(t, ok)
+	assert.Equal(t, "secret/metadata/db", path)
+
+	path, ok = kvMetadataPath("teams/kv/data/app/db")
+	assert.True(t, ok)
+	assert.Equal(t, "teams/kv/metadata/app/db", path)
+
+	_, ok = kvMetadataPath("kv1/db")
+	assert.False(t, ok)
+}
+
+func TestRecordSecretPaths(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+	viper.Set("enable_secret_rollouts", true)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				common.VaultEnvFromPathAnnotation: "secret/data/app",
+			}},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:    "app",
+					Image:   "myimage",
+					Command: []string{"/bin/app"},
+					Env: []corev1.EnvVar{
+						{Name: "DB_PASSWORD", Value: "vault:secret/data/db#password"},
+						{Name: "DB_USER", Value: "vault:secret/data/db#user"},
+						{Name: "LEGACY", Value: "vault:kv1/legacy#value"},
+					},
+				}},
+			},
+		}},
+	}
+
+	mw := &MutatingWebhook{
+		k8sClient: fake.NewClientset(),
+		registry:  failingRegistry{},
+		logger:    slog.New(slog.DiscardHandler),
+	}
+
+	ar := &model.AdmissionReview{
+		Namespace:  "default",
+		RequestGVK: &metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+	}
+	_, err := mw.VaultSecretsMutator(context.Background(), ar, deployment)
+	require.NoError(t, err)
+
+	assert.Equal(t, "secret/data/app,secret/data/db", deployment.Annotations[common.VaultSecretPathsAnnotation])
+	assert.NotContains(t, deployment.Spec.Template.Annotations, common.VaultSecretPathsAnnotation)
+}
+
+func TestRolloutSecrets(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	newDeployment := func(versions string) *appsv1.Deployment {
+		// The secret/data/other path was added to the annotation, but is not referenced
+		annotations := map[string]string{common.VaultSecretPathsAnnotation: "secret/data/app,secret/data/db,secret/data/other"}
+		if versions != "" {
+			annotations[common.VaultSecretVersionsAnnotation] = versions
+		}
+
+		return newRolloutDeployment(annotations)
+	}
+
+	tests := []struct {
+		name         string
+		versions     string
+		wantVersions string
+		wantUpdated  bool
+		wantRollout  bool
+	}{
+		{
+			name:         "first check records the versions",
+			wantVersions: "secret/data/app=1,secret/data/db=3",
+			wantUpdated:  true,
+		},
+		{
+			name:     "unchanged versions",
+			versions: "secret/data/app=1,secret/data/db=3",
+		},
+		{
+			name:         "changed version rolls out new pods",
+			versions:     "secret/data/app=1,secret/data/db=2",
+			wantVersions: "secret/data/app=1,secret/data/db=3",
+			wantUpdated:  true,
+			wantRollout:  true,
+		},
+		{
+			name:         "newly referenced secret is only recorded",
+			versions:     "secret/data/db=3",
+			wantVersions: "secret/data/app=1,secret/data/db=3",
+			wantUpdated:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sClient := fake.NewClientset(newDeployment(tt.versions))
+
+			mw := &MutatingWebhook{
+				k8sClient:      k8sClient,
+				logger:         slog.New(slog.DiscardHandler),
+				secretVersions: staticSecretVersions{"secret/data/app": "1", "secret/data/db": "3", "secret/data/other": "7"},
+			}
+
+			require.NoError(t, mw.rolloutSecrets(context.Background(), newDeployment(tt.versions)))
+
+			var updates int
+			for _, action := range k8sClient.Actions() {
+				if action.GetVerb() == "update" {
+					updates++
+				}
+			}
+
+			if !tt.wantUpdated {
+				assert.Zero(t, updates)
+
+				return
+			}
+			require.Equal(t, 1, updates)
+
+			deployment, err := k8sClient.AppsV1().Deployments("default").Get(context.Background(), "my-app", metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantVersions, deployment.Annotations[common.VaultSecretVersionsAnnotation])
+			if tt.wantRollout {
+				assert.NotEmpty(t, deployment.Spec.Template.Annotations[common.VaultSecretsRotatedAtAnnotation])
+			} else {
+				assert.NotContains(t, deployment.Spec.Template.Annotations, common.VaultSecretsRotatedAtAnnotation)
+			}
+		})
+	}
+}
+
+func TestRolloutSecretsAccessPolicies(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	deployment := newRolloutDeployment(map[string]string{common.VaultSecretPathsAnnotation: "secret/data/app,secret/data/db"})
+	deployment.Spec.Template.Annotations[common.VaultRoleAnnotation] = "app"
+	k8sClient := fake.NewClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, deployment)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{VaultAccessPolicyResource: "VaultAccessPolicyList"},
+		newAccessPolicy(t, "app", nil, VaultAccessPolicySpec{
+			Roles:       []string{"app"},
+			AuthPaths:   []string{"kubernetes"},
+			SecretPaths: []string{"secret/data/app"},
+		}),
+	)
+
+	mw := &MutatingWebhook{
+		k8sClient:      k8sClient,
+		logger:         slog.New(slog.DiscardHandler),
+		secretVersions: staticSecretVersions{"secret/data/app": "1", "secret/data/db": "3"},
+	}
+	require.NoError(t, mw.WatchAccessPolicies(context.Background(), dynamicClient))
+
+	err := mw.rolloutSecrets(context.Background(), deployment)
+	require.ErrorContains(t, err, `Vault secret path "secret/data/db"`)
+	assert.Equal(t, ReasonVaultAccessDenied, eventReason(err))
+}
+
+func TestSecretRolloutsLeaderElection(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	k8sClient := fake.NewClientset()
+	mw := &MutatingWebhook{
+		k8sClient: k8sClient,
+		namespace: "vault-infra",
+		logger:    slog.New(slog.DiscardHandler),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	require.NoError(t, mw.StartSecretRollouts(ctx))
+
+	// Only the replica holding the Lease checks the workloads
+	assert.Eventually(t, func() bool {
+		lease, err := k8sClient.CoordinationV1().Leases("vault-infra").Get(ctx, "vault-secrets-webhook-secret-rollouts", metav1.GetOptions{})
+
+		return err == nil && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != ""
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+// newRolloutDeployment returns a Deployment whose pod template references
+// secret/data/app and secret/data/db.
+func newRolloutDeployment(annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "default", Annotations: annotations},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{common.MutatedAnnotation: "true"}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "myimage",
+				Env: []corev1.EnvVar{
+					{Name: "API_KEY", Value: "vault:secret/data/app#key"},
+					{Name: "DB_PASSWORD", Value: "vault:secret/data/db#password"},
+				},
+			}}},
+		}},
+	}
+}
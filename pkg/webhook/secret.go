@@ -19,10 +19,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"maps"
 	"strings"
 
 	"emperror.dev/errors"
 	injector "github.com/bank-vaults/vault-sdk/injector/vault"
+	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
@@ -53,8 +56,24 @@ type dockerAuthConfig struct {
 	RegistryToken string `json:"registrytoken,omitempty"`
 }
 
+// secretData returns the Data of a Secret merged with its StringData, like the API
+// server merges them on write: StringData takes precedence over Data for the same key.
+func secretData(secret *corev1.Secret) map[string][]byte {
+	if len(secret.StringData) == 0 {
+		return secret.Data
+	}
+
+	data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	maps.Copy(data, secret.Data)
+	for key, value := range secret.StringData {
+		data[key] = []byte(value)
+	}
+
+	return data
+}
+
 func secretNeedsMutation(secret *corev1.Secret) (bool, error) {
-	for key, value := range secret.Data {
+	for key, value := range secretData(secret) {
 		if key == corev1.DockerConfigJsonKey {
 			var dc dockerCredentials
 			err := json.Unmarshal(value, &dc)
@@ -82,7 +101,20 @@ func secretNeedsMutation(secret *corev1.Secret) (bool, error) {
 	return false, nil
 }
 
-func (mw *MutatingWebhook) MutateSecret(ctx context.Context, secret *corev1.Secret, vaultConfig VaultConfig) error {
+func (mw *MutatingWebhook) MutateSecret(ctx context.Context, secret *corev1.Secret, vaultConfig VaultConfig, dryRun bool) error {
+	if _, ok := secret.Annotations[common.VaultSecretSourceAnnotation]; ok {
+		if err := mw.populateSecretFromSource(ctx, secret, vaultConfig, dryRun); err != nil {
+			return errors.Wrap(err, "failed to populate secret from its Vault source")
+		}
+
+		// Resynced to renew its certificate or to read its KV secret again
+		if viper.GetBool("enable_secret_resync") {
+			if err := mw.recordSecretTemplates(ctx, secret, vaultConfig.ObjectNamespace, secretTemplates{}); err != nil {
+				return err
+			}
+		}
+	}
+
 	// do an early exit and don't construct the Vault client if not needed
 	requiredToMutate, err := secretNeedsMutation(secret)
 	if err != nil {
@@ -90,22 +122,66 @@ func (mw *MutatingWebhook) MutateSecret(ctx context.Context, secret *corev1.Secr
 	}
 
 	if !requiredToMutate {
+		if _, ok := secret.Annotations[common.VaultSecretSourceAnnotation]; !ok {
+			mw.forgetSecretTemplates(ctx, secret, secretData(secret))
+		}
+
 		return nil
 	}
 
-	vaultClient, err := mw.newVaultClient(ctx, vaultConfig)
-	if err != nil {
-		return errors.Wrap(err, "failed to create vault client")
+	// Resolved values are written to Data only, StringData is write-only anyway
+	secret.Data = secretData(secret)
+	secret.StringData = nil
+
+	references := resolvedReferences(secret)
+
+	if viper.GetBool("enable_secret_resync") {
+		if err := mw.recordSecretTemplates(ctx, secret, vaultConfig.ObjectNamespace, secretDataTemplates(secret)); err != nil {
+			return err
+		}
+	}
+
+	var versions map[string]string
+	if recordsSecretVersions() {
+		versions = mw.resolvedSecretVersions(ctx, secret, references, vaultConfig)
 	}
 
-	defer vaultClient.Close()
+	// The leases of dynamic secrets are tracked on the Secret instead of being left to expire
+	dynamicSecrets := viper.GetBool("enable_dynamic_secrets")
+	leases := &leaseRecorder{}
+	var token leaseToken
+	var tokenRecorded bool
+
+	var secretResolver SecretResolver
+	release := func() {}
+	switch {
+	case dynamicSecrets && dryRun:
+		// Reading a dynamic secret leases it, dry runs have no side effects
+		secretResolver = PlaceholderSecretResolver{}
+	case dynamicSecrets:
+		secretResolver, token, err = mw.leaseTokenSecretResolver(ctx, vaultConfig, secret, leases)
+		if err != nil {
+			return err
+		}
+		release = token.close
 
-	config := injector.Config{
-		TransitKeyID:     vaultConfig.TransitKeyID,
-		TransitPath:      vaultConfig.TransitPath,
-		TransitBatchSize: vaultConfig.TransitBatchSize,
+		// The token is only kept if it owns leases recorded on the Secret
+		defer func() {
+			if tokenRecorded {
+				return
+			}
+			if err := token.revoke(context.WithoutCancel(ctx)); err != nil {
+				mw.logger.Warn("failed to revoke unused Vault lease token", slog.String("namespace", secret.Namespace), slog.String("name", secret.Name), slog.Any("error", err))
+			}
+		}()
+	default:
+		secretResolver, release, err = mw.secretResolverFor(ctx, vaultConfig)
+		if err != nil {
+			return err
+		}
 	}
-	secretInjector := injector.NewSecretInjector(config, vaultClient, nil, logger)
+
+	defer release()
 
 	if value, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
 		var dc dockerCredentials
@@ -113,21 +189,32 @@ func (mw *MutatingWebhook) MutateSecret(ctx context.Context, secret *corev1.Secr
 		if err != nil {
 			return errors.Wrap(err, "unmarshal dockerconfig json failed")
 		}
-		err = mw.mutateDockerCreds(ctx, secret, &dc, &secretInjector)
+		err = mw.mutateDockerCreds(ctx, secret, &dc, secretResolver)
 		if err != nil {
 			return errors.Wrap(err, "mutate dockerconfig json failed")
 		}
 	}
 
-	err = mw.mutateSecretData(ctx, secret, &secretInjector)
+	err = mw.mutateSecretData(ctx, secret, secretResolver)
 	if err != nil {
 		return errors.Wrap(err, "mutate generic secret failed")
 	}
 
+	if dynamicSecrets && !dryRun {
+		if err := mw.recordSecretLeases(ctx, secret, vaultConfig.ObjectNamespace, token, leases.leases); err != nil {
+			return err
+		}
+		tokenRecorded = len(leases.leases) > 0
+	}
+
+	if recordsSecretVersions() {
+		mw.annotateResolvedSecrets(ctx, secret, references, versions, secret.Data)
+	}
+
 	return nil
 }
 
-func (mw *MutatingWebhook) mutateDockerCreds(ctx context.Context, secret *corev1.Secret, dc *dockerCredentials, secretInjector *injector.SecretInjector) error {
+func (mw *MutatingWebhook) mutateDockerCreds(ctx context.Context, secret *corev1.Secret, dc *dockerCredentials, secretResolver SecretResolver) error {
 	assembled := dockerCredentials{Auths: map[string]dockerAuthConfig{}}
 
 	for key, creds := range dc.Auths {
@@ -150,7 +237,7 @@ func (mw *MutatingWebhook) mutateDockerCreds(ctx context.Context, secret *corev1
 				"password": password,
 			}
 
-			dcCreds, err := secretInjector.GetDataFromVaultWithContext(ctx, credentialData)
+			dcCreds, err := secretResolver.GetDataFromVaultWithContext(ctx, credentialData)
 			if err != nil {
 				return err
 			}
@@ -176,14 +263,14 @@ func (mw *MutatingWebhook) mutateDockerCreds(ctx context.Context, secret *corev1
 	return nil
 }
 
-func (mw *MutatingWebhook) mutateSecretData(ctx context.Context, secret *corev1.Secret, secretInjector *injector.SecretInjector) error {
+func (mw *MutatingWebhook) mutateSecretData(ctx context.Context, secret *corev1.Secret, secretResolver SecretResolver) error {
 	convertedData := make(map[string]string, len(secret.Data))
 
 	for k := range secret.Data {
 		convertedData[k] = string(secret.Data[k])
 	}
 
-	convertedData, err := secretInjector.GetDataFromVaultWithContext(ctx, convertedData)
+	convertedData, err := secretResolver.GetDataFromVaultWithContext(ctx, convertedData)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,258 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// Ways of populating a Secret from its Vault source.
+const (
+	secretSourceIssue = "issue"
+	secretSourceSign  = "sign"
+	secretSourceKV    = "kv"
+)
+
+// tlsCAKey is the key of the CA certificate in kubernetes.io/tls Secrets.
+const tlsCAKey = "ca.crt"
+
+// secretSourceKeys are the keys populated from a Vault source by Secret type,
+// read from a KV secret under the same names.
+var secretSourceKeys = map[corev1.SecretType][]string{
+	corev1.SecretTypeTLS:     {corev1.TLSCertKey, corev1.TLSPrivateKeyKey},
+	corev1.SecretTypeSSHAuth: {corev1.SSHAuthPrivateKey},
+}
+
+// secretSourceOptionalKeys are populated from a KV secret only if it has them.
+var secretSourceOptionalKeys = map[corev1.SecretType][]string{
+	corev1.SecretTypeTLS: {tlsCAKey},
+}
+
+// secretSourceKind returns how a Secret is populated from its Vault source: the
+// <mount>/issue/<role> and <mount>/sign/<role> endpoints of the PKI secrets engine
+// issue a certificate, any other path is read as a KV secret. KV version 2 paths
+// are told apart by their data segment.
+func secretSourceKind(sourcePath string) string {
+	segments := strings.Split(strings.Trim(sourcePath, "/"), "/")
+	if len(segments) < 3 || slices.Contains(segments, "data") || segments[len(segments)-1] == "" {
+		return secretSourceKV
+	}
+
+	switch segments[len(segments)-2] {
+	case secretSourceIssue, secretSourceSign:
+		return segments[len(segments)-2]
+	default:
+		return secretSourceKV
+	}
+}
+
+// secretSourceCommonName returns the common name of the certificates issued to a
+// Secret: the common name annotation, or the <name>.<namespace>.svc name of the
+// Service the Secret is named after, without its -tls suffix.
+func secretSourceCommonName(secret *corev1.Secret, namespace string) string {
+	if commonName := secret.Annotations[common.VaultSecretSourceCommonNameAnnotation]; commonName != "" {
+		return commonName
+	}
+
+	return fmt.Sprintf("%s.%s.svc", strings.TrimSuffix(secret.Name, "-tls"), namespace)
+}
+
+// populateSecretFromSource populates the keys of a kubernetes.io/tls or kubernetes.io/ssh-auth
+// Secret from the Vault source of its annotation. KV secrets are read on every mutation, while
+// certificates are only issued if the Secret has none yet, the common name changed or two thirds
+// of the lifetime of its certificate passed, so that updates of the Secret do not renew it.
+// Certificates are never issued on dry runs.
+func (mw *MutatingWebhook) populateSecretFromSource(ctx context.Context, secret *corev1.Secret, vaultConfig VaultConfig, dryRun bool) error {
+	source := secret.Annotations[common.VaultSecretSourceAnnotation]
+
+	if _, ok := secretSourceKeys[secret.Type]; !ok {
+		return errors.Errorf("Secrets of type %q cannot be populated from %s, only %s and %s Secrets", secret.Type, common.VaultSecretSourceAnnotation, corev1.SecretTypeTLS, corev1.SecretTypeSSHAuth)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	kind := secretSourceKind(source)
+	if kind == secretSourceKV {
+		return mw.populateSecretFromKV(ctx, secret, source, vaultConfig)
+	}
+
+	if secret.Type != corev1.SecretTypeTLS {
+		return errors.Errorf("certificates can only be issued to %s Secrets", corev1.SecretTypeTLS)
+	}
+
+	ttl := secret.Annotations[common.VaultSecretSourceTTLAnnotation]
+	if ttl != "" {
+		if _, err := time.ParseDuration(ttl); err != nil {
+			return errors.Wrapf(err, "invalid %s annotation", common.VaultSecretSourceTTLAnnotation)
+		}
+	}
+
+	commonName := secretSourceCommonName(secret, vaultConfig.ObjectNamespace)
+	if err := mw.authorizeCommonName(vaultConfig.ObjectNamespace, commonName); err != nil {
+		return err
+	}
+
+	if dryRun || !certificateRenewalDue(secret.Data[corev1.TLSCertKey], commonName, time.Now()) {
+		return nil
+	}
+
+	// Vault is not used by the resolver, neither is its PKI
+	if mw.secretResolver != nil {
+		secret.Data[corev1.TLSCertKey] = []byte(placeholder("vault:" + source + "#certificate"))
+		secret.Data[corev1.TLSPrivateKeyKey] = []byte(placeholder("vault:" + source + "#private_key"))
+		secret.Data[tlsCAKey] = []byte(placeholder("vault:" + source + "#issuing_ca"))
+
+		return nil
+	}
+
+	vaultClient, release, err := mw.vaultClientFor(ctx, vaultConfig)
+	if err != nil {
+		return withReason(ReasonVaultAuthFailed, errors.Wrap(err, "failed to create vault client"))
+	}
+	defer release()
+
+	params := map[string]any{"common_name": commonName, "format": "pem"}
+	if ttl != "" {
+		params["ttl"] = ttl
+	}
+
+	// Signed certificates are requested for a key that never leaves the webhook
+	var privateKey []byte
+	if kind == secretSourceSign {
+		var csr []byte
+		privateKey, csr, err = newCertificateRequest(commonName)
+		if err != nil {
+			return err
+		}
+		params["csr"] = string(csr)
+	}
+
+	issued, err := vaultClient.RawClient().Logical().WriteWithContext(ctx, source, params)
+	if err != nil {
+		return withReason(ReasonVaultCertificateIssueFailed, errors.Wrapf(err, "failed to %s a certificate at %s", kind, source))
+	}
+	if issued == nil || issued.Data == nil {
+		return withReason(ReasonVaultCertificateIssueFailed, errors.Errorf("no certificate returned by %s", source))
+	}
+
+	certificate, _ := issued.Data["certificate"].(string)
+	issuingCA, _ := issued.Data["issuing_ca"].(string)
+	if kind == secretSourceIssue {
+		key, _ := issued.Data["private_key"].(string)
+		privateKey = []byte(key)
+	}
+	if certificate == "" || len(privateKey) == 0 {
+		return withReason(ReasonVaultCertificateIssueFailed, errors.Errorf("incomplete certificate returned by %s", source))
+	}
+
+	secret.Data[corev1.TLSCertKey] = []byte(certificate)
+	secret.Data[corev1.TLSPrivateKeyKey] = privateKey
+	if issuingCA != "" {
+		secret.Data[tlsCAKey] = []byte(issuingCA)
+	}
+
+	return nil
+}
+
+// populateSecretFromKV populates the keys of a Secret from the same keys of a KV secret.
+func (mw *MutatingWebhook) populateSecretFromKV(ctx context.Context, secret *corev1.Secret, source string, vaultConfig VaultConfig) error {
+	secretResolver, release, err := mw.secretResolverFor(ctx, vaultConfig)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	references := map[string]string{}
+	for _, key := range secretSourceKeys[secret.Type] {
+		references[key] = "vault:" + source + "#" + key
+	}
+
+	resolved, err := secretResolver.GetDataFromVaultWithContext(ctx, references)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range secretSourceOptionalKeys[secret.Type] {
+		optional, err := secretResolver.GetDataFromVaultWithContext(ctx, map[string]string{key: "vault:" + source + "#" + key})
+		if eventReason(err) == ReasonVaultSecretNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		resolved[key] = optional[key]
+	}
+
+	for key, value := range resolved {
+		secret.Data[key] = []byte(value)
+	}
+
+	return nil
+}
+
+// certificateRenewalDue reports whether the PEM certificate is missing, invalid, issued
+// to another common name or past two thirds of its lifetime.
+func certificateRenewalDue(certificatePEM []byte, commonName string, now time.Time) bool {
+	block, _ := pem.Decode(certificatePEM)
+	if block == nil {
+		return true
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+
+	lifetime := certificate.NotAfter.Sub(certificate.NotBefore)
+
+	return certificate.Subject.CommonName != commonName || now.After(certificate.NotBefore.Add(lifetime*2/3))
+}
+
+// newCertificateRequest returns a new RSA private key and a certificate signing
+// request for it, both in PEM format. RSA keys are accepted by the default PKI roles.
+func newCertificateRequest(commonName string) ([]byte, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate private key")
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create certificate signing request")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+		nil
+}
@@ -0,0 +1,285 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestSecretSourceKind(t *testing.T) {
+	assert.Equal(t, secretSourceIssue, secretSourceKind("pki/issue/web"))
+	assert.Equal(t, secretSourceSign, secretSourceKind("teams/pki/sign/web"))
+	assert.Equal(t, secretSourceKV, secretSourceKind("secret/data/tls/web"))
+	assert.Equal(t, secretSourceKV, secretSourceKind("secret/data/issue/web"))
+	assert.Equal(t, secretSourceKV, secretSourceKind("kv/ssh"))
+}
+
+func TestCertificateRenewalDue(t *testing.T) {
+	newCertificate := func(commonName string, notBefore, notAfter time.Time) []byte {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: commonName},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	now := time.Now()
+	certificate := newCertificate("web.default.svc", now.Add(-time.Hour), now.Add(2*time.Hour))
+
+	assert.True(t, certificateRenewalDue(nil, "web.default.svc", now))
+	assert.True(t, certificateRenewalDue([]byte("<vault:pki/issue/web#certificate>"), "web.default.svc", now))
+	assert.False(t, certificateRenewalDue(certificate, "web.default.svc", now))
+	assert.True(t, certificateRenewalDue(certificate, "api.default.svc", now))
+	assert.True(t, certificateRenewalDue(certificate, "web.default.svc", now.Add(time.Hour+time.Minute)))
+}
+
+func TestPopulateSecretFromSource(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Vault CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer}))
+
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/approle/login" {
+			fmt.Fprint(w, `{"auth": {"client_token": "approle-token", "lease_duration": 3600}}`)
+
+			return
+		}
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+
+		switch r.URL.Path {
+		case "/v1/pki/issue/web":
+			fmt.Fprintf(w, `{"data": {"certificate": "issued-certificate", "private_key": "issued-key", "issuing_ca": %q}}`, caPEM)
+
+		case "/v1/pki/sign/web":
+			block, _ := pem.Decode([]byte(body["csr"].(string)))
+			require.NotNil(t, block)
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			require.NoError(t, err)
+
+			template := &x509.Certificate{
+				SerialNumber: big.NewInt(2),
+				Subject:      csr.Subject,
+				NotBefore:    time.Now().Add(-time.Minute),
+				NotAfter:     time.Now().Add(time.Hour),
+			}
+			der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, csr.PublicKey, caKey)
+			require.NoError(t, err)
+
+			fmt.Fprintf(w, `{"data": {"certificate": %q, "issuing_ca": %q}}`, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), caPEM)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	newMutatingWebhook := func() *MutatingWebhook {
+		return &MutatingWebhook{
+			k8sClient: fake.NewClientset(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault-approle", Namespace: "vault-infra"},
+				Data: map[string][]byte{
+					AppRoleRoleIDKey:   []byte("my-role-id"),
+					AppRoleSecretIDKey: []byte("my-secret-id"),
+				},
+			}),
+			namespace: "vault-infra",
+			logger:    slog.New(slog.DiscardHandler),
+		}
+	}
+	vaultConfig := VaultConfig{Addr: server.URL, AppRoleSecret: "vault-approle", AppRolePath: "approle", ObjectNamespace: "default"}
+
+	newSecret := func(secretType corev1.SecretType, annotations map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default", Annotations: annotations},
+			Type:       secretType,
+		}
+	}
+
+	t.Run("issued certificate", func(t *testing.T) {
+		requests = nil
+		secret := newSecret(corev1.SecretTypeTLS, map[string]string{
+			common.VaultSecretSourceAnnotation:    "pki/issue/web",
+			common.VaultSecretSourceTTLAnnotation: "24h",
+		})
+
+		require.NoError(t, newMutatingWebhook().MutateSecret(t.Context(), secret, vaultConfig, false))
+
+		assert.Equal(t, "issued-certificate", string(secret.Data[corev1.TLSCertKey]))
+		assert.Equal(t, "issued-key", string(secret.Data[corev1.TLSPrivateKeyKey]))
+		assert.Equal(t, caPEM, string(secret.Data[tlsCAKey]))
+
+		require.Len(t, requests, 1)
+		assert.Equal(t, "web.default.svc", requests[0]["common_name"])
+		assert.Equal(t, "24h", requests[0]["ttl"])
+	})
+
+	t.Run("signed certificate is not renewed until due", func(t *testing.T) {
+		requests = nil
+		secret := newSecret(corev1.SecretTypeTLS, map[string]string{
+			common.VaultSecretSourceAnnotation:           "pki/sign/web",
+			common.VaultSecretSourceCommonNameAnnotation: "api.default.svc",
+		})
+
+		mw := newMutatingWebhook()
+		require.NoError(t, mw.MutateSecret(t.Context(), secret, vaultConfig, false))
+		require.NoError(t, mw.MutateSecret(t.Context(), secret, vaultConfig, false))
+		require.Len(t, requests, 1)
+		assert.Equal(t, "api.default.svc", requests[0]["common_name"])
+		assert.NotContains(t, requests[0], "private_key")
+
+		block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+		require.NotNil(t, block)
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		assert.Equal(t, "api.default.svc", certificate.Subject.CommonName)
+
+		keyBlock, _ := pem.Decode(secret.Data[corev1.TLSPrivateKeyKey])
+		require.NotNil(t, keyBlock)
+		key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+		require.NoError(t, err)
+		assert.True(t, key.PublicKey.Equal(certificate.PublicKey), "the certificate is issued for the generated key")
+	})
+
+	t.Run("common names", func(t *testing.T) {
+		requests = nil
+		secret := newSecret(corev1.SecretTypeTLS, map[string]string{
+			common.VaultSecretSourceAnnotation:           "pki/issue/web",
+			common.VaultSecretSourceCommonNameAnnotation: "web.example.com",
+		})
+
+		// Names outside the namespace need to be allowed by a policy
+		mw := newMutatingWebhook()
+		err := mw.MutateSecret(t.Context(), secret.DeepCopy(), vaultConfig, false)
+		require.ErrorContains(t, err, `common name "web.example.com" is not under default.svc`)
+		assert.Equal(t, ReasonVaultAccessDenied, eventReason(err))
+
+		err = mw.MutateSecret(t.Context(), newSecret(corev1.SecretTypeTLS, map[string]string{
+			common.VaultSecretSourceAnnotation:           "pki/issue/web",
+			common.VaultSecretSourceCommonNameAnnotation: "web.payments.svc",
+		}), vaultConfig, false)
+		require.ErrorContains(t, err, `common name "web.payments.svc" is not under default.svc`)
+
+		_, err = mw.k8sClient.CoreV1().Namespaces().Create(t.Context(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, metav1.CreateOptions{})
+		require.NoError(t, err)
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+			runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{VaultAccessPolicyResource: "VaultAccessPolicyList"},
+			newAccessPolicy(t, "web", nil, VaultAccessPolicySpec{CommonNames: []string{"*.example.com"}}),
+		)
+		require.NoError(t, mw.WatchAccessPolicies(t.Context(), dynamicClient))
+
+		require.NoError(t, mw.populateSecretFromSource(t.Context(), secret, vaultConfig, false))
+		require.Len(t, requests, 1)
+		assert.Equal(t, "web.example.com", requests[0]["common_name"])
+	})
+
+	t.Run("dry runs issue no certificates", func(t *testing.T) {
+		requests = nil
+		secret := newSecret(corev1.SecretTypeTLS, map[string]string{common.VaultSecretSourceAnnotation: "pki/issue/web"})
+
+		require.NoError(t, newMutatingWebhook().MutateSecret(t.Context(), secret, vaultConfig, true))
+		assert.Empty(t, requests)
+		assert.Empty(t, secret.Data)
+	})
+
+	t.Run("KV sources", func(t *testing.T) {
+		mw := newMutatingWebhook()
+		mw.SetSecretResolver(PlaceholderSecretResolver{})
+
+		secret := newSecret(corev1.SecretTypeSSHAuth, map[string]string{common.VaultSecretSourceAnnotation: "secret/data/ssh"})
+		require.NoError(t, mw.MutateSecret(t.Context(), secret, vaultConfig, false))
+		assert.Equal(t, map[string][]byte{
+			corev1.SSHAuthPrivateKey: []byte("<vault:secret/data/ssh#ssh-privatekey>"),
+		}, secret.Data)
+
+		secret = newSecret(corev1.SecretTypeTLS, map[string]string{common.VaultSecretSourceAnnotation: "secret/data/tls"})
+		require.NoError(t, mw.MutateSecret(t.Context(), secret, vaultConfig, false))
+		assert.Equal(t, map[string][]byte{
+			corev1.TLSCertKey:       []byte("<vault:secret/data/tls#tls.crt>"),
+			corev1.TLSPrivateKeyKey: []byte("<vault:secret/data/tls#tls.key>"),
+			tlsCAKey:                []byte("<vault:secret/data/tls#ca.crt>"),
+		}, secret.Data)
+	})
+
+	t.Run("unsupported sources", func(t *testing.T) {
+		mw := newMutatingWebhook()
+
+		err := mw.MutateSecret(t.Context(), newSecret(corev1.SecretTypeOpaque, map[string]string{common.VaultSecretSourceAnnotation: "pki/issue/web"}), vaultConfig, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be populated")
+
+		err = mw.MutateSecret(t.Context(), newSecret(corev1.SecretTypeSSHAuth, map[string]string{common.VaultSecretSourceAnnotation: "pki/issue/web"}), vaultConfig, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "certificates can only be issued")
+
+		err = mw.MutateSecret(t.Context(), newSecret(corev1.SecretTypeTLS, map[string]string{
+			common.VaultSecretSourceAnnotation:    "pki/issue/web",
+			common.VaultSecretSourceTTLAnnotation: "1 day",
+		}), vaultConfig, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), common.VaultSecretSourceTTLAnnotation)
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSecretNeedsMutation(t *testing.T) {
+	dockerAuth := base64.StdEncoding.EncodeToString([]byte("vault:secret/data/registry#username:vault:secret/data/registry#password"))
+
+	tests := []struct {
+		name   string
+		secret *corev1.Secret
+		want   bool
+	}{
+		{
+			name:   "plain data",
+			secret: &corev1.Secret{Data: map[string][]byte{"password": []byte("s3cr3t")}},
+		},
+		{
+			name:   "reference in data",
+			secret: &corev1.Secret{Data: map[string][]byte{"password": []byte("vault:secret/data/db#password")}},
+			want:   true,
+		},
+		{
+			name:   "reference in string data",
+			secret: &corev1.Secret{StringData: map[string]string{"password": "vault:secret/data/db#password"}},
+			want:   true,
+		},
+		{
+			name:   "inline reference in string data",
+			secret: &corev1.Secret{StringData: map[string]string{"url": "postgres://${vault:secret/data/db#user}@db"}},
+			want:   true,
+		},
+		{
+			name:   "docker config in string data",
+			secret: &corev1.Secret{StringData: map[string]string{corev1.DockerConfigJsonKey: `{"auths":{"registry.example.com":{"auth":"` + dockerAuth + `"}}}`}},
+			want:   true,
+		},
+		{
+			name: "string data overrides a reference in data",
+			secret: &corev1.Secret{
+				Data:       map[string][]byte{"password": []byte("vault:secret/data/db#password")},
+				StringData: map[string]string{"password": "s3cr3t"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := secretNeedsMutation(tt.secret)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMutateSecretStringData(t *testing.T) {
+	tests := []struct {
+		name           string
+		secret         *corev1.Secret
+		wantData       map[string][]byte
+		wantStringData map[string]string
+	}{
+		{
+			name: "string data is resolved and merged into data",
+			secret: &corev1.Secret{
+				Data: map[string][]byte{
+					"user":     []byte("vault:secret/data/db#user"),
+					"password": []byte("vault:secret/data/db#old-password"),
+				},
+				StringData: map[string]string{
+					"password": "vault:secret/data/db#password",
+					"url":      "postgres://${vault:secret/data/db#user}@db",
+					"host":     "db",
+				},
+			},
+			wantData: map[string][]byte{
+				"user":     []byte("<vault:secret/data/db#user>"),
+				"password": []byte("<vault:secret/data/db#password>"),
+				"url":      []byte("postgres://<vault:secret/data/db#user>@db"),
+				"host":     []byte("db"),
+			},
+		},
+		{
+			name: "secrets without references are left untouched",
+			secret: &corev1.Secret{
+				StringData: map[string]string{"password": "s3cr3t"},
+			},
+			wantStringData: map[string]string{"password": "s3cr3t"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := &MutatingWebhook{secretResolver: PlaceholderSecretResolver{}}
+
+			require.NoError(t, mw.MutateSecret(t.Context(), tt.secret, VaultConfig{}, false))
+			assert.Equal(t, tt.wantData, tt.secret.Data)
+			assert.Equal(t, tt.wantStringData, tt.secret.StringData)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// referencedPaths returns the sorted, distinct paths of the given references.
+func referencedPaths(references []vaultReference) []string {
+	var paths []string
+	for _, reference := range references {
+		if !slices.Contains(paths, reference.Path) {
+			paths = append(paths, reference.Path)
+		}
+	}
+	slices.Sort(paths)
+
+	return paths
+}
+
+// resolvedReferences returns the Vault references resolved by the mutation of
+// a Secret or ConfigMap. The vault-env-from-path annotation only applies to pods.
+func resolvedReferences(obj metav1.Object) []vaultReference {
+	return objectVaultReferences(obj, VaultConfig{})
+}
+
+// recordsSecretVersions reports whether mutated Secrets and ConfigMaps are annotated
+// with the Vault paths and versions their data was resolved from.
+func recordsSecretVersions() bool {
+	return viper.GetBool("enable_secret_version_annotations") || viper.GetBool("enable_secret_resync")
+}
+
+// referenceVersions returns the KV versions of the given references: the pinned ones, or
+// the current versions read from the KV metadata. The versions read before a failure are
+// returned with the error.
+func (mw *MutatingWebhook) referenceVersions(ctx context.Context, references []vaultReference, vaultConfig VaultConfig) (map[string]string, error) {
+	versions := map[string]string{}
+
+	var latest []vaultReference
+	for _, reference := range references {
+		if _, ok := kvMetadataPath(reference.Path); !ok {
+			continue
+		}
+
+		if reference.Version == "" {
+			latest = append(latest, reference)
+		} else {
+			versions[reference.Path] = reference.Version
+		}
+	}
+
+	if len(latest) == 0 {
+		return versions, nil
+	}
+
+	current, err := mw.secretVersionsOf(ctx, vaultConfig, referencedPaths(latest))
+	maps.Copy(versions, current)
+
+	return versions, err
+}
+
+// resolvedSecretVersions returns the KV versions of the references of an object, to be
+// recorded by annotateResolvedSecrets. They are read before the data of the object is
+// resolved, so that a secret written in between is recorded with the older version and
+// resynced again, instead of its new value being considered resolved. Versions that
+// cannot be read are left out, without failing the mutation.
+func (mw *MutatingWebhook) resolvedSecretVersions(ctx context.Context, obj metav1.Object, references []vaultReference, vaultConfig VaultConfig) map[string]string {
+	versions, err := mw.referenceVersions(ctx, references, vaultConfig)
+	if err != nil {
+		mw.logger.Warn("failed to read the versions of Vault secrets", slog.String("namespace", obj.GetNamespace()), slog.String("name", obj.GetName()), slog.Any("error", err))
+	}
+
+	return versions
+}
+
+// annotateResolvedSecrets records the Vault paths the data of an object was resolved
+// from, the KV versions of these paths and a hash of the resolved data, so that the
+// object can be audited against Vault without storing any of its values. Versions are
+// the pinned ones, or the current versions read from the KV metadata at the time of the
+// mutation.
+func (mw *MutatingWebhook) annotateResolvedSecrets(ctx context.Context, obj metav1.Object, references []vaultReference, versions map[string]string, data map[string][]byte) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[common.VaultSecretPathsAnnotation] = strings.Join(referencedPaths(references), ",")
+	if len(versions) > 0 {
+		annotations[common.VaultSecretVersionsAnnotation] = formatSecretVersions(versions)
+	} else {
+		delete(annotations, common.VaultSecretVersionsAnnotation)
+	}
+	if hash, err := mw.contentHash(ctx, data); err == nil {
+		annotations[common.VaultSecretsHashAnnotation] = hash
+	} else {
+		mw.logger.Warn("failed to hash the resolved Vault secrets", slog.String("namespace", obj.GetNamespace()), slog.String("name", obj.GetName()), slog.Any("error", err))
+		delete(annotations, common.VaultSecretsHashAnnotation)
+	}
+
+	obj.SetAnnotations(annotations)
+}
+
+// contentHash returns the HMAC-SHA256 of the keys and values of the given data, keyed
+// with the signing key of the webhook, so that the values cannot be brute forced from it.
+func (mw *MutatingWebhook) contentHash(ctx context.Context, data map[string][]byte) (string, error) {
+	var parts [][]byte
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		parts = append(parts, []byte(key), data[key])
+	}
+
+	hash, err := mw.sign(ctx, parts...)
+	if err != nil {
+		return "", err
+	}
+
+	return "hmac-sha256:" + hash, nil
+}
@@ -0,0 +1,165 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestAnnotateResolvedSecrets(t *testing.T) {
+	newMutatingWebhook := func() *MutatingWebhook {
+		return &MutatingWebhook{
+			k8sClient:      fake.NewClientset(),
+			namespace:      "vault-infra",
+			logger:         slog.New(slog.DiscardHandler),
+			secretResolver: PlaceholderSecretResolver{},
+			secretVersions: staticSecretVersions{"secret/data/db": "3", "secret/data/app": "5"},
+		}
+	}
+
+	t.Run("Secret", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		SetConfigDefaults()
+		viper.Set("enable_secret_version_annotations", true)
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Data: map[string][]byte{
+				"password": []byte("vault:secret/data/db#password"),
+				"user":     []byte("${vault:secret/data/db#user}@db"),
+				"token":    []byte("vault:secret/data/app#token#2"),
+				"legacy":   []byte("vault:kv1/legacy#value"),
+			},
+		}
+
+		mw := newMutatingWebhook()
+		require.NoError(t, mw.MutateSecret(context.Background(), secret, VaultConfig{}, false))
+
+		hash, err := mw.contentHash(context.Background(), secret.Data)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			common.VaultSecretPathsAnnotation:    "kv1/legacy,secret/data/app,secret/data/db",
+			common.VaultSecretVersionsAnnotation: "secret/data/app=2,secret/data/db=3",
+			common.VaultSecretsHashAnnotation:    hash,
+		}, secret.Annotations)
+	})
+
+	t.Run("ConfigMap", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		SetConfigDefaults()
+		viper.Set("enable_secret_version_annotations", true)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Data:       map[string]string{"url": "https://${vault:secret/data/app#host}"},
+		}
+
+		mw := newMutatingWebhook()
+		require.NoError(t, mw.MutateConfigMap(context.Background(), configMap, VaultConfig{}))
+
+		hash, err := mw.contentHash(context.Background(), map[string][]byte{"url": []byte(configMap.Data["url"])})
+		require.NoError(t, err)
+		assert.Equal(t, "secret/data/app", configMap.Annotations[common.VaultSecretPathsAnnotation])
+		assert.Equal(t, "secret/data/app=5", configMap.Annotations[common.VaultSecretVersionsAnnotation])
+		assert.Equal(t, hash, configMap.Annotations[common.VaultSecretsHashAnnotation])
+	})
+
+	t.Run("secret written while resolving", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		SetConfigDefaults()
+		viper.Set("enable_secret_version_annotations", true)
+
+		versions := staticSecretVersions{"secret/data/db": "3"}
+		mw := newMutatingWebhook()
+		mw.secretVersions = versions
+		mw.secretResolver = secretResolverFunc(func(_ context.Context, _ map[string]string) (map[string]string, error) {
+			versions["secret/data/db"] = "4"
+
+			return map[string]string{"password": "password-3"}, nil
+		})
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("vault:secret/data/db#password")},
+		}
+		require.NoError(t, mw.MutateSecret(context.Background(), secret, VaultConfig{}, false))
+
+		// The older version is recorded, so that the Secret is resynced to the newer one
+		assert.Equal(t, "secret/data/db=3", secret.Annotations[common.VaultSecretVersionsAnnotation])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		SetConfigDefaults()
+
+		secret := &corev1.Secret{Data: map[string][]byte{"password": []byte("vault:secret/data/db#password")}}
+
+		require.NoError(t, newMutatingWebhook().MutateSecret(context.Background(), secret, VaultConfig{}, false))
+		assert.Empty(t, secret.Annotations)
+	})
+}
+
+// secretResolverFunc resolves Vault references with a function.
+type secretResolverFunc func(ctx context.Context, data map[string]string) (map[string]string, error)
+
+func (f secretResolverFunc) GetDataFromVaultWithContext(ctx context.Context, data map[string]string) (map[string]string, error) {
+	return f(ctx, data)
+}
+
+func TestContentHash(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	k8sClient := fake.NewClientset()
+	newMutatingWebhook := func() *MutatingWebhook {
+		return &MutatingWebhook{k8sClient: k8sClient, namespace: "vault-infra"}
+	}
+	contentHash := func(mw *MutatingWebhook, data map[string][]byte) string {
+		hash, err := mw.contentHash(context.Background(), data)
+		require.NoError(t, err)
+
+		return hash
+	}
+
+	mw := newMutatingWebhook()
+	hash := contentHash(mw, map[string][]byte{"password": []byte("s3cr3t")})
+
+	assert.Regexp(t, "^hmac-sha256:[0-9a-f]{64}$", hash)
+	assert.NotContains(t, hash, "s3cr3t")
+	assert.Equal(t, hash, contentHash(mw, map[string][]byte{"password": []byte("s3cr3t")}))
+	assert.NotEqual(t, hash, contentHash(mw, map[string][]byte{"password": []byte("s3cr3t2")}))
+	assert.NotEqual(t, contentHash(mw, map[string][]byte{"a": []byte("bc")}), contentHash(mw, map[string][]byte{"ab": []byte("c")}))
+
+	// The key is shared by the replicas of the webhook through its Secret
+	secret, err := k8sClient.CoreV1().Secrets("vault-infra").Get(context.Background(), "vault-secrets-webhook-signing-key", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Len(t, secret.Data[signingKeySecretKey], 32)
+	assert.Equal(t, hash, contentHash(newMutatingWebhook(), map[string][]byte{"password": []byte("s3cr3t")}))
+
+	// Without the key, the hash is different
+	other := &MutatingWebhook{k8sClient: fake.NewClientset(), namespace: "vault-infra"}
+	assert.NotEqual(t, hash, contentHash(other, map[string][]byte{"password": []byte("s3cr3t")}))
+}
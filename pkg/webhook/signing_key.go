@@ -0,0 +1,100 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"emperror.dev/errors"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// signingKeySecretKey is the key of the signing key in its Secret.
+const signingKeySecretKey = "key"
+
+// getSigningKey returns the key the webhook hashes and signs the annotations it records
+// with. The key is kept in the signing_key_secret Secret of the webhook namespace, which
+// is created with a random key by the first replica of the webhook needing it.
+func (mw *MutatingWebhook) getSigningKey(ctx context.Context) ([]byte, error) {
+	mw.signingKeyLock.Lock()
+	defer mw.signingKeyLock.Unlock()
+
+	if mw.signingKey != nil {
+		return mw.signingKey, nil
+	}
+
+	name := viper.GetString("signing_key_secret")
+	secrets := mw.k8sClient.CoreV1().Secrets(mw.namespace)
+
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrap(err, "failed to generate signing key")
+		}
+
+		secret, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: mw.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "vault-secrets-webhook",
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{signingKeySecretKey: key},
+		}, metav1.CreateOptions{})
+
+		// Created by another replica in the meantime
+		if apierrors.IsAlreadyExists(err) {
+			secret, err = secrets.Get(ctx, name, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to get signing key Secret")
+	}
+
+	key := secret.Data[signingKeySecretKey]
+	if len(key) < 32 {
+		return nil, errors.Errorf("signing key Secret %s has no key %s of at least 32 bytes", name, signingKeySecretKey)
+	}
+	mw.signingKey = key
+
+	return key, nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the given parts with the signing key.
+// Parts are separated so that moving bytes between them changes the signature.
+func (mw *MutatingWebhook) sign(ctx context.Context, parts ...[]byte) (string, error) {
+	key, err := mw.getSigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	for _, part := range parts {
+		mac.Write(part)
+		mac.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
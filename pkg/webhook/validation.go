@@ -0,0 +1,180 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+const (
+	// AnnotationValidationLenient logs and counts malformed annotations, then
+	// falls back to the default value of the affected setting.
+	AnnotationValidationLenient = "lenient"
+	// AnnotationValidationStrict rejects the admission request if any
+	// annotation is malformed.
+	AnnotationValidationStrict = "strict"
+)
+
+var annotationValidationErrorsCount = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "vault",
+		Subsystem: "webhook",
+		Name:      "annotation_validation_errors_total",
+		Help:      "Count of malformed webhook annotations.",
+	},
+	[]string{"annotation", "mode"},
+)
+
+// AnnotationError describes a single malformed webhook annotation.
+type AnnotationError struct {
+	Annotation string
+	Value      string
+	Err        error
+}
+
+func (e AnnotationError) Error() string {
+	return fmt.Sprintf("%s=%q: %s", e.Annotation, e.Value, e.Err)
+}
+
+// AnnotationValidationError collects every malformed annotation found on an object.
+type AnnotationValidationError struct {
+	Errors []AnnotationError
+}
+
+func (e *AnnotationValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return "invalid webhook annotations: " + strings.Join(msgs, "; ")
+}
+
+// annotationValidator parses annotation values and remembers every failure,
+// so that all problems can be reported at once.
+type annotationValidator struct {
+	errs     []AnnotationError
+	warnings []string
+}
+
+func (v *annotationValidator) warn(format string, args ...any) {
+	v.warnings = append(v.warnings, fmt.Sprintf(format, args...))
+}
+
+func (v *annotationValidator) add(annotation, value string, err error) {
+	v.errs = append(v.errs, AnnotationError{Annotation: annotation, Value: value, Err: err})
+}
+
+func (v *annotationValidator) parseBool(annotation, value string) bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		v.add(annotation, value, errors.New("must be a boolean"))
+	}
+
+	return b
+}
+
+func (v *annotationValidator) parseDuration(annotation, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		v.add(annotation, value, errors.New("must be a duration, e.g. 10s"))
+	}
+
+	return d
+}
+
+func (v *annotationValidator) parseInt(annotation, value string, bitSize int) int64 {
+	i, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		v.add(annotation, value, errors.Errorf("must be a %d-bit integer", bitSize))
+	}
+
+	return i
+}
+
+func (v *annotationValidator) parseQuantity(annotation, value string) (resource.Quantity, bool) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		v.add(annotation, value, errors.New("must be a resource quantity, e.g. 100m or 128Mi"))
+
+		return q, false
+	}
+
+	return q, true
+}
+
+func (v *annotationValidator) parsePullPolicy(annotation, value string) corev1.PullPolicy {
+	switch value {
+	case "Never", "never", "Always", "always", "IfNotPresent", "ifnotpresent":
+	default:
+		v.add(annotation, value, errors.New("must be one of Always, IfNotPresent or Never"))
+	}
+
+	return getPullPolicy(value)
+}
+
+func (v *annotationValidator) validateTokenAuthMount(annotation, value string) bool {
+	split := strings.Split(value, ":")
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		v.add(annotation, value, errors.New("must be in the <volume>:<token file> format"))
+
+		return false
+	}
+
+	return true
+}
+
+func (v *annotationValidator) validateEnvVariables(annotation, value string) {
+	var envVars []corev1.EnvVar
+	if err := json.Unmarshal([]byte(value), &envVars); err != nil {
+		v.add(annotation, value, errors.New("must be a JSON list of environment variables"))
+	}
+}
+
+func (v *annotationValidator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	return &AnnotationValidationError{Errors: v.errs}
+}
+
+// annotationValidationMode returns the validation mode for objects in the
+// given namespace. Namespaces listed in annotation_validation_strict_namespaces
+// are always validated strictly, regardless of the global mode.
+func annotationValidationMode(namespace string) string {
+	if slices.Contains(common.SplitAndTrim(viper.GetString("annotation_validation_strict_namespaces")), namespace) {
+		return AnnotationValidationStrict
+	}
+
+	if strings.EqualFold(viper.GetString("annotation_validation"), AnnotationValidationStrict) {
+		return AnnotationValidationStrict
+	}
+
+	return AnnotationValidationLenient
+}
@@ -0,0 +1,119 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestParseVaultConfigAnnotationValidation(t *testing.T) {
+	malformed := map[string]string{
+		common.VaultClientTimeoutAnnotation:   "10",
+		common.VaultAgentCPUAnnotation:        "100mm",
+		common.VaultAgentAnnotation:           "yes please",
+		common.TokenAuthMountAnnotation:       "vault-token",
+		common.VaultImagePullPolicyAnnotation: "Sometimes",
+	}
+
+	tests := []struct {
+		name             string
+		namespace        string
+		mode             string
+		strictNamespaces string
+		wantErr          bool
+	}{
+		{name: "lenient mode falls back to defaults", namespace: "dev", mode: AnnotationValidationLenient},
+		{name: "strict mode rejects the object", namespace: "dev", mode: AnnotationValidationStrict, wantErr: true},
+		{name: "strict namespace overrides lenient global mode", namespace: "prod", mode: AnnotationValidationLenient, strictNamespaces: "prod", wantErr: true},
+		{name: "other namespaces stay lenient", namespace: "dev", mode: AnnotationValidationLenient, strictNamespaces: "prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetConfigDefaults()
+			viper.Set("annotation_validation", tt.mode)
+			viper.Set("annotation_validation_strict_namespaces", tt.strictNamespaces)
+			t.Cleanup(viper.Reset)
+			annotationValidationErrorsCount.Reset()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: malformed},
+			}
+
+			vaultConfig, _, err := parseVaultConfig(pod, &model.AdmissionReview{Namespace: tt.namespace}, nil, nil)
+
+			expectedMode := annotationValidationMode(tt.namespace)
+			assert.Equal(t, float64(1), testutil.ToFloat64(annotationValidationErrorsCount.WithLabelValues(common.VaultClientTimeoutAnnotation, expectedMode)))
+
+			if tt.wantErr {
+				var validationErr *AnnotationValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Len(t, validationErr.Errors, len(malformed))
+				for annotation := range malformed {
+					assert.Contains(t, err.Error(), annotation)
+				}
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, time.Duration(0), vaultConfig.ClientTimeout)
+			assert.Equal(t, resource.MustParse("100m"), vaultConfig.AgentCPULimit)
+			assert.False(t, vaultConfig.UseAgent)
+			assert.Empty(t, vaultConfig.TokenAuthMount)
+			assert.Equal(t, corev1.PullIfNotPresent, vaultConfig.AgentImagePullPolicy)
+		})
+	}
+}
+
+func TestParseVaultConfigValidAnnotations(t *testing.T) {
+	SetConfigDefaults()
+	viper.Set("annotation_validation", AnnotationValidationStrict)
+	t.Cleanup(viper.Reset)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				common.VaultClientTimeoutAnnotation:     "30s",
+				common.VaultAgentCPULimitAnnotation:     "250m",
+				common.VaultAgentMemoryAnnotation:       "64Mi",
+				common.TransitBatchSizeAnnotation:       "10",
+				common.TokenAuthMountAnnotation:         "token:vault-token",
+				common.VaultAgentEnvVariablesAnnotation: `[{"name":"FOO","value":"bar"}]`,
+			},
+		},
+	}
+
+	vaultConfig, _, err := parseVaultConfig(pod, &model.AdmissionReview{Namespace: "default"}, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 30*time.Second, vaultConfig.ClientTimeout)
+	assert.Equal(t, resource.MustParse("250m"), vaultConfig.AgentCPULimit)
+	assert.Equal(t, resource.MustParse("64Mi"), vaultConfig.AgentMemoryLimit)
+	assert.Equal(t, 10, vaultConfig.TransitBatchSize)
+	assert.Equal(t, "token:vault-token", vaultConfig.TokenAuthMount)
+}
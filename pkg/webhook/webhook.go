@@ -16,12 +16,14 @@ package webhook
 
 import (
 	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"text/template"
 
 	"emperror.dev/errors"
@@ -38,32 +40,128 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
 )
 
 type MutatingWebhook struct {
-	k8sClient kubernetes.Interface
-	namespace string
-	registry  ImageRegistry
-	logger    *slog.Logger
+	k8sClient                kubernetes.Interface
+	namespace                string
+	registry                 ImageRegistry
+	imageEntrypoints         map[string]imageEntrypoint
+	logger                   *slog.Logger
+	namespaces               corev1listers.NamespaceLister
+	namespaceDefaults        bool
+	namespaceFailurePolicies bool
+	injectionPolicies        cache.Store
+	accessPolicies           cache.Store
+	vaultClients             *vaultClientCache
+	secretResolver           SecretResolver
+	secretVersions           secretVersionReader
+	eventRecorder            record.EventRecorder
+	signingKeyLock           sync.Mutex
+	signingKey               []byte
 }
 
-func (mw *MutatingWebhook) VaultSecretsMutator(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (*mutating.MutatorResult, error) {
-	vaultConfig, err := parseVaultConfig(obj, ar)
+func (mw *MutatingWebhook) VaultSecretsMutator(ctx context.Context, ar *model.AdmissionReview, obj metav1.Object) (result *mutating.MutatorResult, err error) {
+	// The mutation error is only recorded by the webhook, for the latest mutation
+	if removeMutationError(obj) {
+		defer func() {
+			if result == nil {
+				result = &mutating.MutatorResult{}
+			}
+			if result.MutatedObject == nil {
+				result.MutatedObject = obj
+			}
+		}()
+	}
+
+	// Workloads are configured like the pods created from their template
+	configObj := obj
+	podTemplate := podTemplateSpec(obj)
+	if podTemplate != nil {
+		configObj = templatePod(obj, podTemplate)
+	}
+
+	vaultConfig, warnings, err := mw.vaultConfigFor(ar, configObj)
 	if err != nil {
-		return &mutating.MutatorResult{}, err
+		return &mutating.MutatorResult{Warnings: warnings}, err
 	}
 
 	if vaultConfig.Skip {
 		return &mutating.MutatorResult{}, nil
 	}
 
+	if err := mw.authorizeObject(configObj, vaultConfig); err != nil {
+		return &mutating.MutatorResult{Warnings: warnings}, err
+	}
+
+	// Kept to admit the object unmodified if the mutation fails and the failure policy allows it
+	var original runtime.Object
+	if runtimeObj, ok := obj.(runtime.Object); ok {
+		original = runtimeObj.DeepCopyObject()
+	}
+
+	if podTemplate != nil {
+		err = mw.MutatePodTemplate(ctx, configObj.(*corev1.Pod), podTemplate, vaultConfig, ar.DryRun)
+	} else {
+		switch v := obj.(type) {
+		case *corev1.Pod:
+			err = mw.MutatePod(ctx, v, vaultConfig, ar.DryRun)
+
+		case *corev1.Secret:
+			err = mw.MutateSecret(ctx, v, vaultConfig, ar.DryRun)
+
+		case *corev1.ConfigMap:
+			err = mw.MutateConfigMap(ctx, v, vaultConfig)
+
+		case *unstructured.Unstructured:
+			err = mw.MutateObject(ctx, v, vaultConfig)
+
+		default:
+			return &mutating.MutatorResult{Warnings: warnings}, nil
+		}
+	}
+
+	if err != nil {
+		if result := mw.failOpen(ar, original, warnings, err); result != nil {
+			return result, nil
+		}
+	}
+
+	if err == nil && podTemplate != nil && viper.GetBool("enable_secret_rollouts") {
+		recordSecretPaths(obj, objectVaultReferences(configObj, vaultConfig))
+	}
+
+	return &mutating.MutatorResult{MutatedObject: obj, Warnings: warnings}, err
+}
+
+// vaultConfigFor returns the VaultConfig of an object, with its Vault role templated.
+func (mw *MutatingWebhook) vaultConfigFor(ar *model.AdmissionReview, obj metav1.Object) (VaultConfig, []string, error) {
+	defaults, err := mw.configDefaultsFor(ar.Namespace)
+	if err != nil {
+		return VaultConfig{}, nil, errors.Wrap(err, "failed to resolve VaultInjectionPolicies")
+	}
+
+	namespaceAnnotations, err := mw.namespaceAnnotations(ar.Namespace)
+	if err != nil {
+		return VaultConfig{}, nil, errors.Wrap(err, "failed to get namespace annotations")
+	}
+
+	vaultConfig, warnings, err := parseVaultConfig(obj, ar, defaults, namespaceAnnotations)
+	if err != nil || vaultConfig.Skip {
+		return vaultConfig, warnings, err
+	}
+
 	// parse resulting vaultConfig.Role as potential template with fields of vaultConfig
 	tmpl, err := template.New("vaultRole").Option("missingkey=error").Parse(vaultConfig.Role)
 	if err != nil {
-		return &mutating.MutatorResult{}, errors.Wrap(err, "error parsing vault_role")
+		return vaultConfig, warnings, errors.Wrap(err, "error parsing vault_role")
 	}
 	var vRoleBuf strings.Builder
 	if err = tmpl.Execute(&vRoleBuf, map[string]string{
@@ -73,27 +171,12 @@ func (mw *MutatingWebhook) VaultSecretsMutator(ctx context.Context, ar *model.Ad
 		"path":           vaultConfig.Path,
 		"serviceaccount": vaultConfig.VaultServiceAccount,
 	}); err != nil {
-		return &mutating.MutatorResult{}, errors.Wrap(err, "error templating vault_role")
+		return vaultConfig, warnings, errors.Wrap(err, "error templating vault_role")
 	}
 	vaultConfig.Role = vRoleBuf.String()
 	mw.logger.Debug(fmt.Sprintf("vaultConfig.Role = '%s'", vaultConfig.Role))
 
-	switch v := obj.(type) {
-	case *corev1.Pod:
-		return &mutating.MutatorResult{MutatedObject: v}, mw.MutatePod(ctx, v, vaultConfig, ar.DryRun)
-
-	case *corev1.Secret:
-		return &mutating.MutatorResult{MutatedObject: v}, mw.MutateSecret(ctx, v, vaultConfig)
-
-	case *corev1.ConfigMap:
-		return &mutating.MutatorResult{MutatedObject: v}, mw.MutateConfigMap(ctx, v, vaultConfig)
-
-	case *unstructured.Unstructured:
-		return &mutating.MutatorResult{MutatedObject: v}, mw.MutateObject(ctx, v, vaultConfig)
-
-	default:
-		return &mutating.MutatorResult{}, nil
-	}
+	return vaultConfig, warnings, nil
 }
 
 func (mw *MutatingWebhook) getDataFromConfigmap(ctx context.Context, cmName string, ns string) (map[string]string, error) {
@@ -248,6 +331,37 @@ func (mw *MutatingWebhook) newVaultClient(ctx context.Context, vaultConfig Vault
 		}
 
 		clientTLSConfig.RootCAs = pool
+
+		// Present a client certificate if the Secret has one, for Vault servers
+		// that require and verify client certificates
+		certPEM, keyPEM := tlsSecret.Data[corev1.TLSCertKey], tlsSecret.Data[corev1.TLSPrivateKeyKey]
+		if len(certPEM) > 0 && len(keyPEM) > 0 {
+			certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				vaultAuthAttemptsErrorsCount.WithLabelValues("config_error").Inc()
+				return nil, errors.Wrapf(err, "error loading client certificate from TLS Secret: %s", tlsSecret.Name)
+			}
+
+			clientTLSConfig.Certificates = []tls.Certificate{certificate}
+		} else if vaultConfig.TLSClientCert {
+			vaultAuthAttemptsErrorsCount.WithLabelValues("config_error").Inc()
+			return nil, errors.Errorf("TLS Secret %s must contain %s and %s for client certificate authentication", tlsSecret.Name, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+		}
+	}
+
+	if vaultConfig.AppRoleSecret != "" && vaultConfig.CertAuthSecret != "" {
+		vaultAuthAttemptsErrorsCount.WithLabelValues("config_error").Inc()
+		return nil, errors.New("AppRole and TLS certificate auth are mutually exclusive")
+	}
+
+	if vaultConfig.CertAuthSecret != "" {
+		certificate, err := mw.getCertAuthCertificate(ctx, vaultConfig.CertAuthSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		clientTLSConfig := clientConfig.HttpClient.Transport.(*http.Transport).TLSClientConfig
+		clientTLSConfig.Certificates = []tls.Certificate{certificate}
 	}
 
 	clientConfig.HttpClient.Transport = promhttp.InstrumentRoundTripperInFlight(
@@ -265,6 +379,10 @@ func (mw *MutatingWebhook) newVaultClient(ctx context.Context, vaultConfig Vault
 		),
 	)
 
+	if vaultConfig.AppRoleSecret != "" || vaultConfig.CertAuthSecret != "" {
+		return mw.newVaultClientWithLogin(ctx, clientConfig, vaultConfig)
+	}
+
 	clientOptions := []vault.ClientOption{
 		vault.ClientRole(vaultConfig.Role),
 		vault.ClientAuthPath(vaultConfig.Path),
@@ -342,6 +460,7 @@ func (mw *MutatingWebhook) ServeMetrics(addr string, handler http.Handler) {
 
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", handler)
+	mux.Handle(ImageCacheFlushPath, mw.ImageCacheFlushHandler())
 	err := http.ListenAndServe(addr, mux)
 	if err != nil {
 		mw.logger.Error(fmt.Errorf("error serving telemetry: %w", err).Error())
@@ -359,11 +478,29 @@ func NewMutatingWebhook(logger *slog.Logger, k8sClient kubernetes.Interface) (*M
 		namespace = string(namespaceBytes)
 	}
 
+	return NewMutatingWebhookInNamespace(logger, k8sClient, namespace)
+}
+
+// NewMutatingWebhookInNamespace returns a MutatingWebhook running in the given namespace,
+// where the Secrets configuring its own Vault client are looked up.
+func NewMutatingWebhookInNamespace(logger *slog.Logger, k8sClient kubernetes.Interface, namespace string) (*MutatingWebhook, error) {
+	imageEntrypoints, err := parseImageEntrypoints(viper.GetString("image_entrypoints"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid image entrypoints")
+	}
+
+	registry, err := newRegistry(namespace)
+	if err != nil {
+		return nil, err
+	}
+
 	return &MutatingWebhook{
-		k8sClient: k8sClient,
-		namespace: namespace,
-		registry:  NewRegistry(),
-		logger:    logger,
+		k8sClient:        k8sClient,
+		namespace:        namespace,
+		registry:         registry,
+		imageEntrypoints: imageEntrypoints,
+		logger:           logger,
+		vaultClients:     newVaultClientCache(),
 	}, nil
 }
 
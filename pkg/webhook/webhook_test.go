@@ -15,10 +15,14 @@
 package webhook
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -28,6 +32,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 
@@ -181,7 +186,7 @@ func TestParseVaultConfigRejectsObjectAddr(t *testing.T) {
 				},
 			}
 
-			_, err := parseVaultConfig(pod, &model.AdmissionReview{})
+			_, _, err := parseVaultConfig(pod, &model.AdmissionReview{}, nil, nil)
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -192,3 +197,228 @@ func TestParseVaultConfigRejectsObjectAddr(t *testing.T) {
 		})
 	}
 }
+
+func TestParseVaultConfigWarnings(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantWarnings []string
+	}{
+		{
+			name: "no warnings for current annotations",
+			annotations: map[string]string{
+				common.VaultAgentCPULimitAnnotation: "250m",
+			},
+		},
+		{
+			name: "deprecated aliases",
+			annotations: map[string]string{
+				common.VaultAgentCPUAnnotation:                        "250m",
+				common.VaultConsuleTemplateSecretsMountPathAnnotation: "/vault/ct",
+				common.VaultConfigfilePathAnnotation:                  "/vault/config",
+			},
+			wantWarnings: []string{
+				"annotation " + common.VaultAgentCPUAnnotation + " is deprecated, use " + common.VaultAgentCPULimitAnnotation + " instead",
+				"annotation " + common.VaultConsuleTemplateSecretsMountPathAnnotation + " is deprecated and conflicts with " + common.VaultConfigfilePathAnnotation + ", which takes precedence, remove it",
+			},
+		},
+		{
+			name: "ignored skip verify",
+			annotations: map[string]string{
+				common.VaultSkipVerifyAnnotation: "true",
+			},
+			wantWarnings: []string{
+				"annotation " + common.VaultSkipVerifyAnnotation + " is ignored, the webhook does not allow objects to skip TLS verification",
+			},
+		},
+		{
+			name: "ignored auth secrets",
+			annotations: map[string]string{
+				common.VaultAppRoleSecretAnnotation:  "vault-approle",
+				common.VaultCertAuthSecretAnnotation: "vault-client-cert",
+			},
+			wantWarnings: []string{
+				"annotation " + common.VaultAppRoleSecretAnnotation + " is ignored, the webhook does not allow objects to select its Vault credentials",
+				"annotation " + common.VaultCertAuthSecretAnnotation + " is ignored, the webhook does not allow objects to select its Vault credentials",
+			},
+		},
+		{
+			name: "malformed annotation in lenient mode",
+			annotations: map[string]string{
+				common.VaultClientTimeoutAnnotation: "soon",
+			},
+			wantWarnings: []string{
+				"annotation " + common.VaultClientTimeoutAnnotation + " is ignored: must be a duration, e.g. 10s",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tt.annotations,
+				},
+			}
+
+			_, warnings, err := parseVaultConfig(pod, &model.AdmissionReview{}, nil, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantWarnings, warnings)
+		})
+	}
+}
+
+func TestParseVaultConfigDeprecatedPrecedence(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				common.VaultAgentCPUAnnotation:                        "250m",
+				common.VaultAgentCPULimitAnnotation:                   "500m",
+				common.VaultAgentMemoryAnnotation:                     "64Mi",
+				common.VaultAgentMemoryLimitAnnotation:                "256Mi",
+				common.VaultConsuleTemplateSecretsMountPathAnnotation: "/vault/ct",
+				common.VaultConfigfilePathAnnotation:                  "/vault/config",
+			},
+		},
+	}
+
+	// Replacements take precedence over their deprecated aliases
+	vaultConfig, _, err := parseVaultConfig(pod, &model.AdmissionReview{}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, resource.MustParse("500m"), vaultConfig.AgentCPULimit)
+	assert.Equal(t, resource.MustParse("256Mi"), vaultConfig.AgentMemoryLimit)
+	assert.Equal(t, "/vault/config", vaultConfig.ConfigfilePath)
+}
+
+func TestParseVaultConfigAuthSecrets(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{common.VaultAppRoleSecretAnnotation: "operator-approle"},
+		},
+	}
+
+	// Objects can't borrow the credentials of the webhook
+	vaultConfig, _, err := parseVaultConfig(pod, &model.AdmissionReview{}, configDefaults{"vault_approle_secret": "team-approle"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "team-approle", vaultConfig.AppRoleSecret)
+
+	viper.Set("vault_allow_object_auth_secrets", true)
+
+	vaultConfig, warnings, err := parseVaultConfig(pod, &model.AdmissionReview{}, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, "operator-approle", vaultConfig.AppRoleSecret)
+}
+
+func TestNewVaultClientMutualTLS(t *testing.T) {
+	clientCertificate, certPEM, keyPEM := newClientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCertificate)
+
+	var gotClientCert atomic.Bool
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClientCert.Store(len(r.TLS.PeerCertificates) > 0)
+		_, _ = w.Write([]byte(`{"auth": {"client_token": "test-token", "lease_duration": 3600}}`))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		name           string
+		secretData     map[string][]byte
+		tlsClientCert  bool
+		wantClientCert bool
+		wantErr        string
+	}{
+		{
+			name: "presents the client certificate from the TLS Secret",
+			secretData: map[string][]byte{
+				"ca.crt":                serverCA,
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+			},
+			tlsClientCert:  true,
+			wantClientCert: true,
+		},
+		{
+			name: "presents the client certificate even if not required",
+			secretData: map[string][]byte{
+				"ca.crt":                serverCA,
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+			},
+			wantClientCert: true,
+		},
+		{
+			name:       "CA only Secret without client certificate",
+			secretData: map[string][]byte{"ca.crt": serverCA},
+		},
+		{
+			name:          "required client certificate missing from the TLS Secret",
+			secretData:    map[string][]byte{"ca.crt": serverCA},
+			tlsClientCert: true,
+			wantErr:       "must contain tls.crt and tls.key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotClientCert.Store(false)
+
+			mw := &MutatingWebhook{
+				k8sClient: fake.NewClientset(
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "vault-tls", Namespace: "vault-infra"},
+						Data:       tt.secretData,
+					},
+					&corev1.ServiceAccount{
+						ObjectMeta: metav1.ObjectMeta{Name: "test-sa", Namespace: "test-namespace"},
+						Secrets:    []corev1.ObjectReference{{Name: "test-sa-token"}},
+					},
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "test-sa-token", Namespace: "test-namespace"},
+						Data:       map[string][]byte{"token": []byte("sa-token")},
+					},
+				),
+				namespace: "vault-infra",
+				logger:    slog.New(slog.DiscardHandler),
+			}
+
+			client, err := mw.newVaultClient(t.Context(), VaultConfig{
+				Addr:                server.URL,
+				Role:                "test-role",
+				Path:                "kubernetes",
+				TLSSecret:           "vault-tls",
+				TLSClientCert:       tt.tlsClientCert,
+				VaultServiceAccount: "test-sa",
+				ObjectNamespace:     "test-namespace",
+			})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+
+			assert.Equal(t, tt.wantClientCert, gotClientCert.Load())
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+// podTemplateSpec returns the pod template of the built-in workload
+// controllers, or nil for any other object.
+func podTemplateSpec(obj metav1.Object) *corev1.PodTemplateSpec {
+	switch v := obj.(type) {
+	case *appsv1.Deployment:
+		return &v.Spec.Template
+	case *appsv1.StatefulSet:
+		return &v.Spec.Template
+	case *appsv1.DaemonSet:
+		return &v.Spec.Template
+	case *batchv1.Job:
+		return &v.Spec.Template
+	case *batchv1.CronJob:
+		return &v.Spec.JobTemplate.Spec.Template
+	default:
+		return nil
+	}
+}
+
+// templatePod returns a pod built from the pod template of a workload, so that
+// the template is configured and mutated like the pods created from it.
+func templatePod(workload metav1.Object, template *corev1.PodTemplateSpec) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+
+	// Name the vault-agent ConfigMap after the workload
+	pod.Name = workload.GetName()
+	if pod.Name == "" {
+		pod.Name = workload.GetGenerateName()
+	}
+	pod.Namespace = workload.GetNamespace()
+
+	return pod
+}
+
+// MutatePodTemplate applies the pod mutation to the pod template of a workload.
+// Mutated templates are marked, so that the pods created from them are not mutated again.
+func (mw *MutatingWebhook) MutatePodTemplate(ctx context.Context, pod *corev1.Pod, template *corev1.PodTemplateSpec, vaultConfig VaultConfig, dryRun bool) error {
+	if isPodAlreadyMutated(pod) {
+		mw.logger.Info(fmt.Sprintf("Pod template of %s is already mutated, skipping mutation.", pod.Name))
+		return nil
+	}
+
+	if err := mw.MutatePod(ctx, pod, vaultConfig, dryRun); err != nil {
+		return err
+	}
+
+	// Nothing to inject, leave the template untouched
+	if !isPodAlreadyMutated(pod) {
+		return nil
+	}
+
+	template.Spec = pod.Spec
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[common.MutatedAnnotation] = "true"
+
+	return nil
+}
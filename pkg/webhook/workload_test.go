@@ -0,0 +1,134 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/common"
+)
+
+func TestMutateWorkloads(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	SetConfigDefaults()
+
+	newTemplate := func(value string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "my-app"}},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: "my-app",
+				Containers: []corev1.Container{{
+					Name:    "app",
+					Image:   "myimage",
+					Command: []string{"/bin/app"},
+					Env:     []corev1.EnvVar{{Name: "DB_PASSWORD", Value: value}},
+				}},
+			},
+		}
+	}
+
+	objectMeta := metav1.ObjectMeta{Name: "my-app", Namespace: "default"}
+
+	tests := []struct {
+		name        string
+		obj         metav1.Object
+		wantMutated bool
+	}{
+		{
+			name:        "Deployment",
+			obj:         &appsv1.Deployment{ObjectMeta: objectMeta, Spec: appsv1.DeploymentSpec{Template: newTemplate("vault:secret/data/db#password")}},
+			wantMutated: true,
+		},
+		{
+			name:        "StatefulSet",
+			obj:         &appsv1.StatefulSet{ObjectMeta: objectMeta, Spec: appsv1.StatefulSetSpec{Template: newTemplate("vault:secret/data/db#password")}},
+			wantMutated: true,
+		},
+		{
+			name:        "DaemonSet",
+			obj:         &appsv1.DaemonSet{ObjectMeta: objectMeta, Spec: appsv1.DaemonSetSpec{Template: newTemplate("vault:secret/data/db#password")}},
+			wantMutated: true,
+		},
+		{
+			name:        "Job",
+			obj:         &batchv1.Job{ObjectMeta: objectMeta, Spec: batchv1.JobSpec{Template: newTemplate("vault:secret/data/db#password")}},
+			wantMutated: true,
+		},
+		{
+			name: "CronJob",
+			obj: &batchv1.CronJob{ObjectMeta: objectMeta, Spec: batchv1.CronJobSpec{
+				JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: newTemplate("vault:secret/data/db#password")}},
+			}},
+			wantMutated: true,
+		},
+		{
+			name: "Deployment without Vault references",
+			obj:  &appsv1.Deployment{ObjectMeta: objectMeta, Spec: appsv1.DeploymentSpec{Template: newTemplate("plain")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := &MutatingWebhook{
+				k8sClient: fake.NewClientset(),
+				registry:  &MockRegistry{Image: v1.Config{}},
+				logger:    slog.New(slog.DiscardHandler),
+			}
+
+			result, err := mw.VaultSecretsMutator(context.Background(), &model.AdmissionReview{Namespace: "default"}, tt.obj)
+			require.NoError(t, err)
+			assert.Same(t, tt.obj, result.MutatedObject)
+
+			template := podTemplateSpec(tt.obj)
+			require.NotNil(t, template)
+
+			if !tt.wantMutated {
+				assert.Equal(t, newTemplate("plain"), *template)
+
+				return
+			}
+
+			assert.Equal(t, "true", template.Annotations[common.MutatedAnnotation])
+			assert.Equal(t, "my-app", template.Labels["app"], "template metadata must be kept")
+			assert.Equal(t, []string{"/vault/vault-env"}, template.Spec.Containers[0].Command)
+			assert.Equal(t, []string{"copy-vault-env"}, containerNames(template.Spec.InitContainers))
+
+			// Pods created from the mutated template are left alone
+			pod := &corev1.Pod{ObjectMeta: template.ObjectMeta, Spec: *template.Spec.DeepCopy()}
+			_, err = mw.VaultSecretsMutator(context.Background(), &model.AdmissionReview{Namespace: "default"}, pod)
+			require.NoError(t, err)
+			assert.Equal(t, template.Spec, pod.Spec)
+
+			// Updating the workload doesn't mutate the template again
+			mutated := template.DeepCopy()
+			_, err = mw.VaultSecretsMutator(context.Background(), &model.AdmissionReview{Namespace: "default"}, tt.obj)
+			require.NoError(t, err)
+			assert.Equal(t, mutated, template)
+		})
+	}
+}
@@ -0,0 +1,347 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"emperror.dev/errors"
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	"gomodules.xyz/jsonpatch/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bank-vaults/vault-secrets-webhook/pkg/webhook"
+)
+
+const (
+	renderVaultStub = "stub"
+	renderVaultReal = "real"
+
+	renderOutputYAML      = "yaml"
+	renderOutputJSONPatch = "json-patch"
+)
+
+// manifest is an object decoded from a document of a manifest file.
+type manifest struct {
+	object   runtime.Object
+	file     string
+	line     int
+	document []byte
+}
+
+// renderedObject is an object of the rendered manifests, along with its state
+// before the mutation.
+type renderedObject struct {
+	original runtime.Object
+	object   runtime.Object
+}
+
+// renderedPatch is the JSON patch the webhook applies to a single object.
+type renderedPatch struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Namespace  string                `json:"namespace,omitempty"`
+	Name       string                `json:"name"`
+	Patch      []jsonpatch.Operation `json:"patch"`
+}
+
+// runRender implements the render subcommand, which mutates the objects of
+// multi-document YAML manifests the same way the webhook would, without a cluster.
+func runRender(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	var files []string
+
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: vault-secrets-webhook render [flags]")
+		fmt.Fprintln(stderr, "")
+		fmt.Fprintln(stderr, "Mutates the objects of Kubernetes manifests like the webhook would and prints the result.")
+		fmt.Fprintln(stderr, "The webhook is configured through the same environment variables as in the cluster.")
+		fmt.Fprintln(stderr, "With -vault "+renderVaultReal+" every object is resolved with the Vault token of VAULT_TOKEN, as service accounts")
+		fmt.Fprintln(stderr, "and the auth Secrets of the webhook namespace can't be used to log in to Vault without a cluster.")
+		fmt.Fprintln(stderr, "")
+		flags.PrintDefaults()
+	}
+	flags.Func("f", "manifest file to render, - for the standard input (can be repeated, defaults to the standard input)", func(file string) error {
+		files = append(files, file)
+
+		return nil
+	})
+	namespace := flags.String("namespace", "default", "namespace of the objects without one")
+	vaultBackend := flags.String("vault", renderVaultStub, "Vault backend: "+renderVaultStub+" replaces references with placeholders, "+renderVaultReal+" reads them from Vault")
+	output := flags.String("o", renderOutputYAML, "output format: "+renderOutputYAML+" or "+renderOutputJSONPatch)
+	kubeVersion := flags.String("kube-version", "v1.30.0", "Kubernetes version to mutate the objects for")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		return errors.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	if *output != renderOutputYAML && *output != renderOutputJSONPatch {
+		return errors.Errorf("unknown output format %q", *output)
+	}
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var objects []runtime.Object
+	for _, file := range files {
+		manifests, err := readManifests(file, stdin)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read manifests from %s", file)
+		}
+
+		for _, m := range manifests {
+			objects = append(objects, m.object)
+		}
+	}
+
+	// Objects referenced by the manifests, e.g. ConfigMaps used in envFrom, are looked up in the fake cluster
+	var clusterObjects []runtime.Object
+	for _, object := range objects {
+		if _, ok := object.(*unstructured.Unstructured); ok {
+			continue
+		}
+
+		clusterObject := object.DeepCopyObject()
+		if metaObject := clusterObject.(metav1.Object); metaObject.GetNamespace() == "" {
+			metaObject.SetNamespace(*namespace)
+		}
+
+		clusterObjects = append(clusterObjects, clusterObject)
+	}
+
+	k8sClient := fake.NewClientset(clusterObjects...)
+	k8sClient.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: *kubeVersion}
+
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	mutatingWebhook, err := webhook.NewMutatingWebhookInNamespace(logger, k8sClient, *namespace)
+	if err != nil {
+		return err
+	}
+
+	switch *vaultBackend {
+	case renderVaultStub:
+		mutatingWebhook.SetSecretResolver(webhook.PlaceholderSecretResolver{})
+	case renderVaultReal:
+		// Kubernetes auth would look the service account tokens up in the fake cluster
+		if os.Getenv("VAULT_TOKEN") == "" {
+			return errors.New("-vault " + renderVaultReal + " needs a Vault token in VAULT_TOKEN")
+		}
+	default:
+		return errors.Errorf("unknown Vault backend %q", *vaultBackend)
+	}
+
+	rendered := make([]renderedObject, 0, len(objects))
+	for _, object := range objects {
+		original := object.DeepCopyObject()
+		metaObject := object.(metav1.Object)
+
+		objectNamespace := metaObject.GetNamespace()
+		if objectNamespace == "" {
+			objectNamespace = *namespace
+		}
+
+		result, err := mutatingWebhook.VaultSecretsMutator(context.Background(), &model.AdmissionReview{
+			Namespace: objectNamespace,
+			DryRun:    true,
+		}, metaObject)
+		if err != nil {
+			return errors.Wrapf(err, "failed to mutate %s", objectName(object))
+		}
+
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(stderr, "Warning: %s: %s\n", objectName(object), warning)
+		}
+
+		rendered = append(rendered, renderedObject{original: original, object: object})
+	}
+
+	if *output == renderOutputJSONPatch {
+		return writePatches(stdout, rendered)
+	}
+
+	return writeManifests(stdout, rendered)
+}
+
+// readManifests decodes the objects of a multi-document YAML file, kinds unknown
+// to client-go are decoded as unstructured objects.
+func readManifests(file string, stdin io.Reader) ([]manifest, error) {
+	var reader io.Reader = stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		reader = f
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []manifest
+
+	// Documents are verbatim parts of the file, their position gives their line
+	offset := 0
+	documents := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		document, err := documents.Read()
+		if errors.Is(err, io.EOF) {
+			return manifests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if i := bytes.Index(content[offset:], document); i >= 0 {
+			offset += i
+		}
+		documentLine := bytes.Count(content[:offset], []byte("\n")) + 1
+		offset += len(document)
+
+		data, err := yaml.YAMLToJSON(document)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+			continue
+		}
+
+		object, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+		if runtime.IsNotRegisteredError(err) {
+			unstructuredObject := &unstructured.Unstructured{}
+			if err := unstructuredObject.UnmarshalJSON(data); err != nil {
+				return nil, err
+			}
+
+			manifests = append(manifests, manifest{object: unstructuredObject, file: file, line: documentLine, document: document})
+
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := object.(metav1.Object); !ok {
+			return nil, errors.Errorf("unsupported kind %s", gvk.Kind)
+		}
+
+		object.GetObjectKind().SetGroupVersionKind(*gvk)
+		manifests = append(manifests, manifest{object: object, file: file, line: documentLine, document: document})
+	}
+}
+
+func writeManifests(w io.Writer, rendered []renderedObject) error {
+	for i, r := range rendered {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.object)
+		if err != nil {
+			return err
+		}
+
+		// Drop the empty fields typed objects are serialized with
+		if creationTimestamp, ok, _ := unstructured.NestedFieldNoCopy(content, "metadata", "creationTimestamp"); ok && creationTimestamp == nil {
+			unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+		}
+		if status, ok, _ := unstructured.NestedMap(content, "status"); ok && len(status) == 0 {
+			unstructured.RemoveNestedField(content, "status")
+		}
+
+		data, err := yaml.Marshal(content)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writePatches writes the JSON patches of the mutated objects, unchanged objects are left out.
+func writePatches(w io.Writer, rendered []renderedObject) error {
+	patches := []renderedPatch{}
+	for _, r := range rendered {
+		original, err := json.Marshal(r.original)
+		if err != nil {
+			return err
+		}
+
+		mutated, err := json.Marshal(r.object)
+		if err != nil {
+			return err
+		}
+
+		patch, err := jsonpatch.CreatePatch(original, mutated)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create patch for %s", objectName(r.object))
+		}
+
+		if len(patch) == 0 {
+			continue
+		}
+
+		gvk := r.object.GetObjectKind().GroupVersionKind()
+		metaObject := r.object.(metav1.Object)
+		patches = append(patches, renderedPatch{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  metaObject.GetNamespace(),
+			Name:       metaObject.GetName(),
+			Patch:      patch,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(patches)
+}
+
+func objectName(object runtime.Object) string {
+	return fmt.Sprintf("%s %s", object.GetObjectKind().GroupVersionKind().Kind, object.(metav1.Object).GetName())
+}
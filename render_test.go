@@ -0,0 +1,225 @@
+// Copyright © 2026 Bank-Vaults Maintainers
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const renderManifests = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: payments
+stringData:
+  password: vault:secret/data/db#password
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: plain
+data:
+  greeting: hello
+---
+# A kind unknown to client-go
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  token: vault:secret/data/widget#token
+`
+
+func TestRunRender(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		env        map[string]string
+		wantOutput []string
+		wantErr    string
+	}{
+		{
+			name:  "renders manifests from the standard input with placeholders",
+			stdin: renderManifests,
+			wantOutput: []string{
+				// base64 of <vault:secret/data/db#password>
+				"password: PHZhdWx0OnNlY3JldC9kYXRhL2RiI3Bhc3N3b3JkPg==",
+				"greeting: hello",
+				"token: <vault:secret/data/widget#token>",
+			},
+		},
+		{
+			name:  "writes the JSON patches of the mutated objects",
+			args:  []string{"-o", "json-patch"},
+			stdin: renderManifests,
+			wantOutput: []string{
+				`"kind": "Secret"`,
+				`"namespace": "payments"`,
+				`"kind": "Widget"`,
+				`"path": "/spec/token"`,
+			},
+		},
+		{
+			name:    "rejects an unknown output format",
+			args:    []string{"-o", "xml"},
+			wantErr: `unknown output format "xml"`,
+		},
+		{
+			name:    "rejects an unknown Vault backend",
+			args:    []string{"-vault", "mock"},
+			stdin:   renderManifests,
+			wantErr: `unknown Vault backend "mock"`,
+		},
+		{
+			name:    "rejects a real Vault without a token",
+			args:    []string{"-vault", "real"},
+			stdin:   renderManifests,
+			env:     map[string]string{"VAULT_TOKEN": ""},
+			wantErr: "needs a Vault token in VAULT_TOKEN",
+		},
+		{
+			name: "uses the configured image entrypoints instead of the registry",
+			stdin: `apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - name: app
+    image: registry.invalid/app:1.0
+    env:
+    - name: PASSWORD
+      value: vault:secret/data/app#password
+`,
+			env: map[string]string{"IMAGE_ENTRYPOINTS": `{"registry.invalid/app:1.0": {"entrypoint": ["/app"], "cmd": ["serve"]}}`},
+			wantOutput: []string{
+				"- /app",
+				"- serve",
+			},
+		},
+		{
+			name:    "rejects invalid image entrypoints",
+			stdin:   renderManifests,
+			env:     map[string]string{"IMAGE_ENTRYPOINTS": "/app"},
+			wantErr: "invalid image entrypoints",
+		},
+		{
+			name:    "rejects invalid registry mirrors",
+			stdin:   renderManifests,
+			env:     map[string]string{"REGISTRY_MIRRORS": "docker.io=harbor.internal"},
+			wantErr: "invalid registry mirrors",
+		},
+		{
+			name:    "rejects arguments",
+			args:    []string{"manifests.yaml"},
+			wantErr: "unexpected arguments: [manifests.yaml]",
+		},
+		{
+			name:    "reports the file it failed to read",
+			args:    []string{"-f", filepath.Join(t.TempDir(), "missing.yaml")},
+			wantErr: "failed to read manifests from",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var stdout, stderr bytes.Buffer
+			err := runRender(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+
+				return
+			}
+			require.NoError(t, err)
+
+			for _, want := range tt.wantOutput {
+				assert.Contains(t, stdout.String(), want)
+			}
+		})
+	}
+}
+
+func TestRunRenderJSONPatch(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, runRender([]string{"-o", "json-patch"}, strings.NewReader(renderManifests), &stdout, &stderr))
+
+	var patches []renderedPatch
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &patches))
+
+	// The ConfigMap without Vault references is left out
+	require.Len(t, patches, 2)
+	assert.Equal(t, "Secret", patches[0].Kind)
+	assert.Equal(t, "db", patches[0].Name)
+	assert.NotEmpty(t, patches[0].Patch)
+	assert.Equal(t, "Widget", patches[1].Kind)
+	assert.Equal(t, "example.com/v1", patches[1].APIVersion)
+	assert.Equal(t, "<vault:secret/data/widget#token>", patches[1].Patch[0].Value)
+}
+
+func TestReadManifests(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "manifests.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("---\n"+renderManifests+"---\n"), 0o600))
+
+	manifests, err := readManifests(file, nil)
+	require.NoError(t, err)
+	require.Len(t, manifests, 3)
+
+	// Lines are those of the documents, including their separator and comments
+	assert.IsType(t, &corev1.Secret{}, manifests[0].object)
+	assert.Equal(t, 1, manifests[0].line)
+	assert.IsType(t, &corev1.ConfigMap{}, manifests[1].object)
+	assert.Equal(t, 10, manifests[1].line)
+	assert.IsType(t, &unstructured.Unstructured{}, manifests[2].object)
+	assert.Equal(t, 17, manifests[2].line)
+	assert.Equal(t, file, manifests[2].file)
+
+	_, err = readManifests("-", strings.NewReader("apiVersion: v1\nkind: Status\n"))
+	require.ErrorContains(t, err, "unsupported kind Status")
+}
+
+func TestWriteManifests(t *testing.T) {
+	manifests, err := readManifests("-", strings.NewReader(renderManifests))
+	require.NoError(t, err)
+
+	rendered := make([]renderedObject, 0, len(manifests))
+	for _, m := range manifests {
+		rendered = append(rendered, renderedObject{original: m.object.DeepCopyObject(), object: m.object})
+	}
+
+	var manifestsOutput bytes.Buffer
+	require.NoError(t, writeManifests(&manifestsOutput, rendered))
+	assert.Equal(t, 2, strings.Count(manifestsOutput.String(), "---\n"))
+	assert.NotContains(t, manifestsOutput.String(), "creationTimestamp")
+
+	// Objects the webhook did not change have no patch
+	var patchesOutput bytes.Buffer
+	require.NoError(t, writePatches(&patchesOutput, rendered))
+	assert.JSONEq(t, "[]", patchesOutput.String())
+}